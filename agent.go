@@ -1,6 +1,6 @@
 package nimbusec
 
-import "io/ioutil"
+import "context"
 
 type Agent struct {
 	OS      string `json:"os"`
@@ -13,18 +13,19 @@ type Agent struct {
 }
 
 func (a *API) DownloadAgent(agent Agent) ([]byte, error) {
-	url := a.BuildURL("/v2/agent/download/nimbusagent-%s-%s-v%d.%s", agent.OS, agent.Arch, agent.Version, agent.Format)
-	res, err := a.client.Get(url, Params{}, a.token)
-	if err != nil {
-		return []byte{}, err
-	}
-
-	defer res.Body.Close()
-	return ioutil.ReadAll(res.Body)
+	return a.DownloadAgentContext(context.Background(), agent)
+}
 
+func (a *API) DownloadAgentContext(ctx context.Context, agent Agent) ([]byte, error) {
+	url := a.BuildURL("/v2/agent/download/nimbusagent-%s-%s-v%d.%s", agent.OS, agent.Arch, agent.Version, agent.Format)
+	return a.getBytes(ctx, url, Params{})
 }
 
 func (a *API) FindAgents(filter string) ([]Agent, error) {
+	return a.FindAgentsContext(context.Background(), filter)
+}
+
+func (a *API) FindAgentsContext(ctx context.Context, filter string) ([]Agent, error) {
 	params := Params{}
 	if filter != EmptyFilter {
 		params["q"] = filter
@@ -32,6 +33,6 @@ func (a *API) FindAgents(filter string) ([]Agent, error) {
 
 	dst := make([]Agent, 0)
 	url := a.BuildURL("/v2/agent/download")
-	err := a.Get(url, params, &dst)
+	err := a.GetContext(ctx, url, params, &dst)
 	return dst, err
 }
@@ -0,0 +1,158 @@
+package nimbusec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// AllDomains selects the results of all domains in a BulkUpdate.
+const AllDomains = 0
+
+// DefaultBulkConcurrency is the number of parallel requests used by
+// UpdateResults, unless configured otherwise.
+const DefaultBulkConcurrency = 8
+
+// BulkUpdate describes a status change of many results at once. Results are
+// selected either by IDs or by Filter.
+type BulkUpdate struct {
+	Domain      int          // domain of the results or AllDomains
+	IDs         []int        // IDs of the results to update; requires a single domain
+	Filter      string       // filter selecting the results, if no IDs are given
+	Status      ResultStatus // target status of all selected results
+	Concurrency int          // maximum number of parallel requests
+}
+
+// BulkResult is the outcome of the update of a single result. If the results
+// of a domain could not be listed, Result is 0 and Err contains the reason.
+type BulkResult struct {
+	Domain int
+	Result int
+	Err    error
+}
+
+// BulkReport contains the outcome of every result touched by UpdateResults.
+type BulkReport struct {
+	Results []BulkResult
+}
+
+// Succeeded returns the number of successfully updated results.
+func (r *BulkReport) Succeeded() int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns all failed updates.
+func (r *BulkReport) Failed() []BulkResult {
+	failed := make([]BulkResult, 0)
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// UpdateResults sets the status of all selected results with parallel
+// UpdateResult calls. The nimbusec API has no batch endpoint for results,
+// so every result is updated with a request of its own; Concurrency bounds
+// how many of them are in flight.
+func (a *API) UpdateResults(update BulkUpdate) (*BulkReport, error) {
+	return a.UpdateResultsContext(context.Background(), update)
+}
+
+// UpdateResultsContext is like UpdateResults but with a context.
+func (a *API) UpdateResultsContext(ctx context.Context, update BulkUpdate) (*BulkReport, error) {
+	if !update.Status.Valid() {
+		return nil, fmt.Errorf("nimbusec: invalid result status %d", update.Status)
+	}
+
+	if len(update.IDs) > 0 && update.Domain == AllDomains {
+		return nil, errors.New("nimbusec: result IDs require a single domain")
+	}
+
+	domains := []int{update.Domain}
+	if update.Domain == AllDomains {
+		all, err := a.FindDomainsContext(ctx, EmptyFilter)
+		if err != nil {
+			return nil, err
+		}
+
+		domains = domains[:0]
+		for _, domain := range all {
+			domains = append(domains, domain.Id)
+		}
+	}
+
+	concurrency := update.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+
+	report := &BulkReport{Results: make([]BulkResult, 0)}
+	for _, domain := range domains {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		ids := update.IDs
+		if len(ids) == 0 {
+			results, err := a.FindResultsContext(ctx, domain, update.Filter)
+			if err != nil {
+				report.Results = append(report.Results, BulkResult{Domain: domain, Err: err})
+				continue
+			}
+
+			for _, result := range results {
+				ids = append(ids, result.Id)
+			}
+		}
+
+		if len(ids) == 0 {
+			continue
+		}
+
+		report.Results = append(report.Results, a.updateDomainResults(ctx, domain, ids, update.Status, concurrency)...)
+	}
+
+	return report, nil
+}
+
+// updateDomainResults updates the given results of one domain in parallel.
+// Once ctx is done, no further updates are started and the remaining results
+// are reported with the error of ctx.
+func (a *API) updateDomainResults(ctx context.Context, domain int, ids []int, status ResultStatus, concurrency int) []BulkResult {
+	results := make([]BulkResult, len(ids))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, id := range ids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(ids); j++ {
+				results[j] = BulkResult{Domain: domain, Result: ids[j], Err: ctx.Err()}
+			}
+			wg.Wait()
+			return results
+		}
+
+		wg.Add(1)
+		go func(i, id int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			_, err := a.UpdateResultContext(ctx, domain, &Result{Id: id, Status: status})
+			results[i] = BulkResult{Domain: domain, Result: id, Err: err}
+		}(i, id)
+	}
+
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,129 @@
+package nimbusec_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestUpdateResults(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	first := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	second := srv.AddDomain(nimbusec.Domain{Name: "example.org"})
+	for _, domain := range []int{first.Id, second.Id} {
+		for i := 0; i < 5; i++ {
+			srv.AddResult(domain, nimbusec.Result{Status: nimbusec.StatusPending})
+		}
+	}
+
+	ids := func(domain int) []int {
+		var ids []int
+		for _, result := range srv.Results(domain) {
+			ids = append(ids, result.Id)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name    string
+		update  nimbusec.BulkUpdate
+		updated int
+		failed  int
+		err     bool
+	}{
+		{"by ids", nimbusec.BulkUpdate{Domain: first.Id, IDs: ids(first.Id)[:2], Status: nimbusec.StatusAcknowledged}, 2, 0, false},
+		{"all results", nimbusec.BulkUpdate{Domain: first.Id, Status: nimbusec.StatusAcknowledged, Concurrency: 1}, 5, 0, false},
+		{"all domains", nimbusec.BulkUpdate{Domain: nimbusec.AllDomains, Status: nimbusec.StatusFalsePositive}, 10, 0, false},
+		{"unknown result", nimbusec.BulkUpdate{Domain: first.Id, IDs: []int{ids(first.Id)[0], 9999}, Status: nimbusec.StatusRemoved}, 1, 1, false},
+		{"invalid status", nimbusec.BulkUpdate{Domain: first.Id, Status: 0}, 0, 0, true},
+		{"ids without domain", nimbusec.BulkUpdate{IDs: []int{1}, Status: nimbusec.StatusRemoved}, 0, 0, true},
+	}
+
+	api := srv.API()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := api.UpdateResults(tt.update)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n := report.Succeeded(); n != tt.updated {
+				t.Errorf("succeeded = %d, want %d", n, tt.updated)
+			}
+			if n := len(report.Failed()); n != tt.failed {
+				t.Errorf("failed = %d, want %d", n, tt.failed)
+			}
+
+			for _, result := range report.Results {
+				if result.Err != nil {
+					continue
+				}
+				for _, stored := range srv.Results(result.Domain) {
+					if stored.Id == result.Result && stored.Status != tt.update.Status {
+						t.Errorf("result %d has status %v, want %v", stored.Id, stored.Status, tt.update.Status)
+					}
+				}
+			}
+		})
+	}
+}
+
+// cancelTransport cancels a context once the first request completed.
+type cancelTransport struct {
+	cancel context.CancelFunc
+}
+
+func (t cancelTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	t.cancel()
+	return resp, err
+}
+
+func TestUpdateResultsCancelled(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	var ids []int
+	for i := 0; i < 5; i++ {
+		ids = append(ids, srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending}).Id)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	api := srv.API(nimbusec.WithTransport(cancelTransport{cancel}))
+	report, err := api.UpdateResultsContext(ctx, nimbusec.BulkUpdate{
+		Domain:      domain.Id,
+		IDs:         ids,
+		Status:      nimbusec.StatusAcknowledged,
+		Concurrency: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Results) != len(ids) {
+		t.Fatalf("got %d results, want %d", len(report.Results), len(ids))
+	}
+	if n := report.Succeeded(); n != 1 {
+		t.Errorf("succeeded = %d, want 1", n)
+	}
+	for _, result := range report.Failed() {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("result %d: error = %v, want context.Canceled", result.Result, result.Err)
+		}
+	}
+}
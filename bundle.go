@@ -1,5 +1,7 @@
 package nimbusec
 
+import "context"
+
 type Bundle struct {
 	Id         string    `json:"id,omitempty"`
 	Name       string    `json:"name"`
@@ -17,13 +19,21 @@ type Bundle struct {
 }
 
 func (a *API) GetBundle(bundle string) (*Bundle, error) {
+	return a.GetBundleContext(context.Background(), bundle)
+}
+
+func (a *API) GetBundleContext(ctx context.Context, bundle string) (*Bundle, error) {
 	dst := new(Bundle)
 	url := a.BuildURL("/v2/bundle/%s", bundle)
-	err := a.Get(url, Params{}, dst)
+	err := a.GetContext(ctx, url, Params{}, dst)
 	return dst, err
 }
 
 func (a *API) FindBundles(filter string) ([]Bundle, error) {
+	return a.FindBundlesContext(context.Background(), filter)
+}
+
+func (a *API) FindBundlesContext(ctx context.Context, filter string) ([]Bundle, error) {
 	params := Params{}
 	if filter != EmptyFilter {
 		params["q"] = filter
@@ -31,6 +41,6 @@ func (a *API) FindBundles(filter string) ([]Bundle, error) {
 
 	dst := make([]Bundle, 0)
 	url := a.BuildURL("/v2/bundle")
-	err := a.Get(url, params, &dst)
+	err := a.GetContext(ctx, url, params, &dst)
 	return dst, err
 }
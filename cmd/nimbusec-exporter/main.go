@@ -0,0 +1,77 @@
+// Command nimbusec-exporter serves the metrics of a nimbusec account for
+// prometheus. The API credentials are read from the NIMBUSEC_KEY and
+// NIMBUSEC_SECRET environment variables unless given as flags.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func main() {
+	var (
+		url      = flag.String("url", nimbusec.DefaultAPI, "url of the nimbusec API")
+		key      = flag.String("key", os.Getenv("NIMBUSEC_KEY"), "API key")
+		secret   = flag.String("secret", os.Getenv("NIMBUSEC_SECRET"), "API secret")
+		listen   = flag.String("listen", ":9573", "address to serve the metrics on")
+		path     = flag.String("path", "/metrics", "path of the metrics endpoint")
+		interval = flag.Duration("interval", 5*time.Minute, "time between refreshes of the metrics")
+		timeout  = flag.Duration("timeout", 30*time.Second, "timeout of a single API request")
+	)
+	flag.Parse()
+
+	if *key == "" || *secret == "" {
+		log.Fatal("nimbusec-exporter: API key and secret are required")
+	}
+
+	client := exporter.NewClientMetrics()
+	api, err := nimbusec.NewAPIWithOptions(*url, *key, *secret,
+		nimbusec.WithTransport(client.RoundTripper(nil)),
+		nimbusec.WithTimeout(*timeout),
+		nimbusec.WithUserAgent("nimbusec-exporter"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	e := exporter.New(api)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		client,
+		e,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go e.Run(ctx, *interval)
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{Addr: *listen, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	log.Printf("serving metrics on %s%s", *listen, *path)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
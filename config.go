@@ -0,0 +1,356 @@
+package nimbusec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigType is the type of the value of a configuration key.
+type ConfigType int
+
+const (
+	ConfigString   ConfigType = iota // arbitrary text
+	ConfigBool                       // "true" or "false"
+	ConfigInt                        // decimal integer
+	ConfigDuration                   // whole seconds, durations like "90s" or "1h30m" are accepted as well
+	ConfigList                       // comma separated list of strings
+	ConfigEnum                       // one of ConfigKey.Values
+)
+
+var configTypeNames = []string{"string", "bool", "int", "duration", "list", "enum"}
+
+func (t ConfigType) String() string {
+	if t >= 0 && int(t) < len(configTypeNames) {
+		return configTypeNames[t]
+	}
+	return "ConfigType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// ErrConfigNotSet is returned by DomainConfig.Get for keys that are neither
+// set on the domain nor have a default.
+var ErrConfigNotSet = errors.New("not set")
+
+// ConfigKey describes a known configuration key.
+type ConfigKey struct {
+	Name        string
+	Type        ConfigType
+	Default     string   // raw value used if the key is not set
+	Description string   // human readable description
+	Values      []string // allowed values of an enum
+}
+
+// Parse converts a raw value into the Go type of the key: string, bool,
+// int, time.Duration or []string.
+func (k ConfigKey) Parse(raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+	switch k.Type {
+	case ConfigString:
+		return raw, nil
+
+	case ConfigBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, k.errorf("invalid bool %q", raw)
+		}
+		return v, nil
+
+	case ConfigInt:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, k.errorf("invalid int %q", raw)
+		}
+		return v, nil
+
+	case ConfigDuration:
+		if secs, err := strconv.Atoi(raw); err == nil {
+			return time.Duration(secs) * time.Second, nil
+		}
+		v, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, k.errorf("invalid duration %q", raw)
+		}
+		return v, nil
+
+	case ConfigList:
+		list := make([]string, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+
+	case ConfigEnum:
+		for _, v := range k.Values {
+			if v == raw {
+				return raw, nil
+			}
+		}
+		return nil, k.errorf("invalid value %q, must be one of %s", raw, strings.Join(k.Values, ", "))
+	}
+
+	return nil, k.errorf("unknown type %v", k.Type)
+}
+
+// Format converts a Go value into the raw value of the key. The value must
+// have the type returned by Parse. Durations are written as whole seconds.
+func (k ConfigKey) Format(value interface{}) (string, error) {
+	var raw string
+	ok := false
+	switch k.Type {
+	case ConfigString, ConfigEnum:
+		raw, ok = value.(string)
+	case ConfigBool:
+		var v bool
+		v, ok = value.(bool)
+		raw = strconv.FormatBool(v)
+	case ConfigInt:
+		var v int
+		v, ok = value.(int)
+		raw = strconv.Itoa(v)
+	case ConfigDuration:
+		var v time.Duration
+		v, ok = value.(time.Duration)
+		if ok && v%time.Second != 0 {
+			return "", k.errorf("duration %v is not a whole number of seconds", v)
+		}
+		raw = strconv.FormatInt(int64(v/time.Second), 10)
+	case ConfigList:
+		var v []string
+		v, ok = value.([]string)
+		raw = strings.Join(v, ",")
+	}
+	if !ok {
+		return "", k.errorf("value of type %T for %v key", value, k.Type)
+	}
+
+	// parsing checks enum values and list items.
+	if _, err := k.Parse(raw); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (k ConfigKey) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("nimbusec: config %s: %s", k.Name, fmt.Sprintf(format, args...))
+}
+
+// ConfigRegistry is a set of known configuration keys.
+type ConfigRegistry struct {
+	keys map[string]ConfigKey
+}
+
+// NewConfigRegistry creates a registry of the given keys.
+func NewConfigRegistry(keys ...ConfigKey) (*ConfigRegistry, error) {
+	r := &ConfigRegistry{keys: make(map[string]ConfigKey, len(keys))}
+	for _, key := range keys {
+		if err := r.Register(key); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds a key to the registry. The default must be a valid value.
+func (r *ConfigRegistry) Register(key ConfigKey) error {
+	if key.Name == "" {
+		return fmt.Errorf("nimbusec: config key without name")
+	}
+	if _, ok := r.keys[key.Name]; ok {
+		return key.errorf("registered twice")
+	}
+	if key.Type == ConfigEnum && len(key.Values) == 0 {
+		return key.errorf("enum without values")
+	}
+	if key.Default != "" {
+		if _, err := key.Parse(key.Default); err != nil {
+			return err
+		}
+	}
+
+	r.keys[key.Name] = key
+	return nil
+}
+
+// Lookup returns the key with the given name.
+func (r *ConfigRegistry) Lookup(name string) (ConfigKey, bool) {
+	key, ok := r.keys[name]
+	return key, ok
+}
+
+// Keys returns all registered keys sorted by name.
+func (r *ConfigRegistry) Keys() []ConfigKey {
+	keys := make([]ConfigKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+func (r *ConfigRegistry) key(name string) (ConfigKey, error) {
+	key, ok := r.keys[name]
+	if !ok {
+		return key, fmt.Errorf("nimbusec: unknown config key %q", name)
+	}
+	return key, nil
+}
+
+// Values parses raw configuration values, e.g. from GetAllDomainConfigs, and
+// fills in the defaults of registered keys that are not set. Unknown keys
+// are kept as strings.
+func (r *ConfigRegistry) Values(raw map[string]string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(r.keys))
+	for name, key := range r.keys {
+		if _, ok := raw[name]; ok || key.Default == "" {
+			continue
+		}
+		v, err := key.Parse(key.Default)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = v
+	}
+
+	for name, value := range raw {
+		key, ok := r.keys[name]
+		if !ok {
+			values[name] = value
+			continue
+		}
+
+		v, err := key.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// DomainConfig gives typed access to the configuration of a domain using
+// the keys of a registry. Unset keys return their default, or an error
+// matching ErrConfigNotSet if the key has none.
+type DomainConfig struct {
+	api      *API
+	domain   int
+	registry *ConfigRegistry
+}
+
+// DomainConfig returns typed access to the configuration of a domain.
+func (a *API) DomainConfig(domain int, registry *ConfigRegistry) *DomainConfig {
+	return &DomainConfig{api: a, domain: domain, registry: registry}
+}
+
+// Get fetches and parses the value of a key, see ConfigKey.Parse.
+func (c *DomainConfig) Get(ctx context.Context, name string) (interface{}, error) {
+	key, err := c.registry.key(name)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := c.api.GetDomainConfigContext(ctx, c.domain, name)
+	if IsNotFound(err) {
+		if key.Default == "" {
+			return nil, fmt.Errorf("nimbusec: config %s: %w", name, ErrConfigNotSet)
+		}
+		raw, err = key.Default, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key.Parse(raw)
+}
+
+// Set validates and stores the value of a key, see ConfigKey.Format.
+func (c *DomainConfig) Set(ctx context.Context, name string, value interface{}) error {
+	key, err := c.registry.key(name)
+	if err != nil {
+		return err
+	}
+
+	raw, err := key.Format(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.api.SetDomainConfigContext(ctx, c.domain, name, raw)
+	return err
+}
+
+// Reset deletes the key, so its default applies again.
+func (c *DomainConfig) Reset(ctx context.Context, name string) error {
+	return c.api.DeleteDomainConfigContext(ctx, c.domain, name)
+}
+
+// get fetches the value of a key and checks its type.
+func get[T any](ctx context.Context, c *DomainConfig, name string) (T, error) {
+	var zero T
+	v, err := c.Get(ctx, name)
+	if err != nil {
+		return zero, err
+	}
+
+	typed, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("nimbusec: config %s is of type %T, not %T", name, v, zero)
+	}
+	return typed, nil
+}
+
+// String returns the value of a string or enum key.
+func (c *DomainConfig) String(ctx context.Context, name string) (string, error) {
+	return get[string](ctx, c, name)
+}
+
+// Bool returns the value of a bool key.
+func (c *DomainConfig) Bool(ctx context.Context, name string) (bool, error) {
+	return get[bool](ctx, c, name)
+}
+
+// Int returns the value of an int key.
+func (c *DomainConfig) Int(ctx context.Context, name string) (int, error) {
+	return get[int](ctx, c, name)
+}
+
+// Duration returns the value of a duration key.
+func (c *DomainConfig) Duration(ctx context.Context, name string) (time.Duration, error) {
+	return get[time.Duration](ctx, c, name)
+}
+
+// List returns the value of a list key.
+func (c *DomainConfig) List(ctx context.Context, name string) ([]string, error) {
+	return get[[]string](ctx, c, name)
+}
+
+// SetString sets a string or enum key.
+func (c *DomainConfig) SetString(ctx context.Context, name string, value string) error {
+	return c.Set(ctx, name, value)
+}
+
+// SetBool sets a bool key.
+func (c *DomainConfig) SetBool(ctx context.Context, name string, value bool) error {
+	return c.Set(ctx, name, value)
+}
+
+// SetInt sets an int key.
+func (c *DomainConfig) SetInt(ctx context.Context, name string, value int) error {
+	return c.Set(ctx, name, value)
+}
+
+// SetDuration sets a duration key.
+func (c *DomainConfig) SetDuration(ctx context.Context, name string, value time.Duration) error {
+	return c.Set(ctx, name, value)
+}
+
+// SetList sets a list key.
+func (c *DomainConfig) SetList(ctx context.Context, name string, value []string) error {
+	return c.Set(ctx, name, value)
+}
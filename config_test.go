@@ -0,0 +1,124 @@
+package nimbusec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigKeyParse(t *testing.T) {
+	enum := []string{"low", "high"}
+
+	tests := []struct {
+		typ  ConfigType
+		raw  string
+		want interface{}
+		err  bool
+	}{
+		{ConfigString, " text ", "text", false},
+		{ConfigBool, "true", true, false},
+		{ConfigBool, "yes", nil, true},
+		{ConfigInt, "42", 42, false},
+		{ConfigInt, "4.2", nil, true},
+		{ConfigDuration, "90", 90 * time.Second, false},
+		{ConfigDuration, "1h30m", 90 * time.Minute, false},
+		{ConfigDuration, "soon", nil, true},
+		{ConfigList, "a, b,,c", []string{"a", "b", "c"}, false},
+		{ConfigList, "", []string{}, false},
+		{ConfigEnum, "high", "high", false},
+		{ConfigEnum, "medium", nil, true},
+		{ConfigType(42), "x", nil, true},
+	}
+
+	for _, tt := range tests {
+		key := ConfigKey{Name: "key", Type: tt.typ, Values: enum}
+		got, err := key.Parse(tt.raw)
+		if (err != nil) != tt.err {
+			t.Errorf("%v %q: error = %v, want error %v", tt.typ, tt.raw, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v %q: got %#v, want %#v", tt.typ, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestConfigKeyFormat(t *testing.T) {
+	enum := []string{"low", "high"}
+
+	tests := []struct {
+		typ   ConfigType
+		value interface{}
+		want  string
+		err   bool
+	}{
+		{ConfigString, "text", "text", false},
+		{ConfigBool, false, "false", false},
+		{ConfigInt, 7, "7", false},
+		{ConfigInt, "7", "", true},
+		{ConfigDuration, 90 * time.Second, "90", false},
+		{ConfigDuration, 2 * time.Hour, "7200", false},
+		{ConfigDuration, 1500 * time.Millisecond, "", true},
+		{ConfigDuration, 90, "", true},
+		{ConfigList, []string{"a", "b"}, "a,b", false},
+		{ConfigEnum, "low", "low", false},
+		{ConfigEnum, "medium", "", true},
+	}
+
+	for _, tt := range tests {
+		key := ConfigKey{Name: "key", Type: tt.typ, Values: enum}
+		got, err := key.Format(tt.value)
+		if (err != nil) != tt.err {
+			t.Errorf("%v %v: error = %v, want error %v", tt.typ, tt.value, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v %v: got %q, want %q", tt.typ, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConfigRegistry(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []ConfigKey
+		err  bool
+	}{
+		{"valid", []ConfigKey{{Name: "a", Type: ConfigInt, Default: "1"}, {Name: "b", Type: ConfigEnum, Values: []string{"x"}}}, false},
+		{"no name", []ConfigKey{{Type: ConfigString}}, true},
+		{"twice", []ConfigKey{{Name: "a"}, {Name: "a"}}, true},
+		{"enum without values", []ConfigKey{{Name: "a", Type: ConfigEnum}}, true},
+		{"invalid default", []ConfigKey{{Name: "a", Type: ConfigBool, Default: "maybe"}}, true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewConfigRegistry(tt.keys...)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestConfigRegistryValues(t *testing.T) {
+	registry, err := NewConfigRegistry(
+		ConfigKey{Name: "interval", Type: ConfigDuration, Default: "60"},
+		ConfigKey{Name: "retries", Type: ConfigInt, Default: "3"},
+		ConfigKey{Name: "tags", Type: ConfigList},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := registry.Values(map[string]string{"retries": "5", "custom": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"interval": time.Minute, "retries": 5, "custom": "x"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+
+	if _, err := registry.Values(map[string]string{"retries": "many"}); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
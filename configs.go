@@ -0,0 +1,221 @@
+package nimbusec
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// GetAllDomainConfigs fetches the values of all configuration keys of a
+// domain. The keys are listed with ListDomainConfigs and fetched in parallel.
+func (a *API) GetAllDomainConfigs(domain int) (map[string]string, error) {
+	return a.GetAllDomainConfigsContext(context.Background(), domain)
+}
+
+// GetAllDomainConfigsContext is like GetAllDomainConfigs but with a context.
+func (a *API) GetAllDomainConfigsContext(ctx context.Context, domain int) (map[string]string, error) {
+	keys, err := a.ListDomainConfigsContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first error cancels the remaining requests.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	values := make([]string, len(keys))
+	sem := make(chan struct{}, DefaultBulkConcurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+spawn:
+	for i, key := range keys {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			once.Do(func() { firstErr = ctx.Err() })
+			break spawn
+		}
+
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			value, err := a.GetDomainConfigContext(ctx, domain, key)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			values[i] = value
+		}(i, key)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	configs := make(map[string]string, len(keys))
+	for i, key := range keys {
+		configs[key] = values[i]
+	}
+	return configs, nil
+}
+
+// ConfigChange is a single difference between two domain configurations.
+type ConfigChange struct {
+	Key    string
+	Old    string // current value, empty if the key is not set
+	New    string // desired value, empty if the key is deleted
+	Delete bool   // the key is removed
+}
+
+// DiffConfigs returns the changes that turn the current into the desired
+// configuration, ordered by key. Keys missing in desired are only deleted
+// if prune is set.
+func DiffConfigs(current, desired map[string]string, prune bool) []ConfigChange {
+	changes := make([]ConfigChange, 0)
+	for key, value := range desired {
+		old, ok := current[key]
+		if !ok || old != value {
+			changes = append(changes, ConfigChange{Key: key, Old: old, New: value})
+		}
+	}
+
+	if prune {
+		for key, old := range current {
+			if _, ok := desired[key]; !ok {
+				changes = append(changes, ConfigChange{Key: key, Old: old, Delete: true})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// ApplyConfigChanges sets or deletes the keys of a domain as described by
+// changes. It stops at the first error.
+func (a *API) ApplyConfigChanges(domain int, changes []ConfigChange) error {
+	return a.ApplyConfigChangesContext(context.Background(), domain, changes)
+}
+
+// ApplyConfigChangesContext is like ApplyConfigChanges but with a context.
+func (a *API) ApplyConfigChangesContext(ctx context.Context, domain int, changes []ConfigChange) error {
+	for _, change := range changes {
+		var err error
+		if change.Delete {
+			err = a.DeleteDomainConfigContext(ctx, domain, change.Key)
+		} else {
+			_, err = a.SetDomainConfigContext(ctx, domain, change.Key, change.New)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfigCopy describes copying the configuration of a template domain to
+// other domains.
+type ConfigCopy struct {
+	Template    int      // domain whose configuration is copied
+	Domains     []int    // target domains
+	Keys        []string // keys to copy, all keys of the template if empty
+	Prune       bool     // delete keys of the targets the template does not have
+	DryRun      bool     // only compute the changes
+	Concurrency int      // maximum number of domains processed in parallel
+}
+
+// ConfigCopyResult is the outcome of copying the configuration to a single
+// domain.
+type ConfigCopyResult struct {
+	Domain  int
+	Changes []ConfigChange
+	Err     error
+}
+
+// CopyDomainConfigs copies the configuration of the template domain to all
+// target domains. Every target is compared with the template first and only
+// differing keys are changed. Failures of single domains are reported in
+// their result. Once ctx is done, no further domains are started and the
+// remaining ones are reported with the error of ctx.
+func (a *API) CopyDomainConfigs(cp ConfigCopy) ([]ConfigCopyResult, error) {
+	return a.CopyDomainConfigsContext(context.Background(), cp)
+}
+
+// CopyDomainConfigsContext is like CopyDomainConfigs but with a context.
+func (a *API) CopyDomainConfigsContext(ctx context.Context, cp ConfigCopy) ([]ConfigCopyResult, error) {
+	template, err := a.GetAllDomainConfigsContext(ctx, cp.Template)
+	if err != nil {
+		return nil, err
+	}
+	template = selectKeys(template, cp.Keys)
+
+	concurrency := cp.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+
+	results := make([]ConfigCopyResult, len(cp.Domains))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, domain := range cp.Domains {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(cp.Domains); j++ {
+				results[j] = ConfigCopyResult{Domain: cp.Domains[j], Err: ctx.Err()}
+			}
+			wg.Wait()
+			return results, nil
+		}
+
+		wg.Add(1)
+		go func(i, domain int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := ConfigCopyResult{Domain: domain}
+			defer func() { results[i] = result }()
+
+			current, err := a.GetAllDomainConfigsContext(ctx, domain)
+			if err != nil {
+				result.Err = err
+				return
+			}
+
+			result.Changes = DiffConfigs(selectKeys(current, cp.Keys), template, cp.Prune)
+			if !cp.DryRun {
+				result.Err = a.ApplyConfigChangesContext(ctx, domain, result.Changes)
+			}
+		}(i, domain)
+	}
+
+	wg.Wait()
+	return results, nil
+}
+
+// selectKeys returns the subset of configs with the given keys, or configs
+// if no keys are given.
+func selectKeys(configs map[string]string, keys []string) map[string]string {
+	if len(keys) == 0 {
+		return configs
+	}
+
+	selected := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, ok := configs[key]; ok {
+			selected[key] = value
+		}
+	}
+	return selected
+}
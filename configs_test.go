@@ -0,0 +1,214 @@
+package nimbusec_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestDomainConfig(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	registry, err := nimbusec.NewConfigRegistry(
+		nimbusec.ConfigKey{Name: "interval", Type: nimbusec.ConfigDuration, Default: "3600"},
+		nimbusec.ConfigKey{Name: "timeout", Type: nimbusec.ConfigDuration},
+		nimbusec.ConfigKey{Name: "tags", Type: nimbusec.ConfigList},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := t.Context()
+	config := srv.API().DomainConfig(domain.Id, registry)
+
+	if v, err := config.Duration(ctx, "interval"); err != nil || v != time.Hour {
+		t.Errorf("default interval = %v, %v, want 1h", v, err)
+	}
+	if _, err := config.Duration(ctx, "timeout"); !errors.Is(err, nimbusec.ErrConfigNotSet) {
+		t.Errorf("unset timeout: error = %v, want ErrConfigNotSet", err)
+	}
+	if _, err := config.List(ctx, "tags"); !errors.Is(err, nimbusec.ErrConfigNotSet) {
+		t.Errorf("unset tags: error = %v, want ErrConfigNotSet", err)
+	}
+	if _, err := config.Get(ctx, "unknown"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+
+	if err := config.SetDuration(ctx, "timeout", 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if raw := srv.DomainConfigs(domain.Id)["timeout"]; raw != "90" {
+		t.Errorf("stored timeout = %q, want 90", raw)
+	}
+	if v, err := config.Duration(ctx, "timeout"); err != nil || v != 90*time.Second {
+		t.Errorf("timeout = %v, %v, want 1m30s", v, err)
+	}
+	if _, err := config.String(ctx, "timeout"); err == nil {
+		t.Error("expected an error for reading a duration as string")
+	}
+
+	if err := config.Reset(ctx, "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Duration(ctx, "timeout"); !errors.Is(err, nimbusec.ErrConfigNotSet) {
+		t.Errorf("reset timeout: error = %v, want ErrConfigNotSet", err)
+	}
+}
+
+func TestGetAllDomainConfigs(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	api := srv.API()
+	want := map[string]string{"a": "1", "b": "2", "c": "3"}
+	for key, value := range want {
+		if _, err := api.SetDomainConfig(domain.Id, key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	configs, err := api.GetAllDomainConfigs(domain.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(configs, want) {
+		t.Errorf("configs = %v, want %v", configs, want)
+	}
+
+	if _, err := api.GetAllDomainConfigs(9999); !nimbusec.IsNotFound(err) {
+		t.Errorf("unknown domain: error = %v, want not found", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := api.GetAllDomainConfigsContext(ctx, domain.Id); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	current := map[string]string{"a": "1", "b": "2", "c": "3"}
+	desired := map[string]string{"a": "1", "b": "20", "d": "4"}
+
+	tests := []struct {
+		name  string
+		prune bool
+		want  []nimbusec.ConfigChange
+	}{
+		{"keep", false, []nimbusec.ConfigChange{
+			{Key: "b", Old: "2", New: "20"},
+			{Key: "d", New: "4"},
+		}},
+		{"prune", true, []nimbusec.ConfigChange{
+			{Key: "b", Old: "2", New: "20"},
+			{Key: "c", Old: "3", Delete: true},
+			{Key: "d", New: "4"},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := nimbusec.DiffConfigs(current, desired, tt.prune); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: changes = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCopyDomainConfigs(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string
+		prune  bool
+		dryRun bool
+		want   map[string]string
+	}{
+		{"all keys", nil, false, false, map[string]string{"a": "1", "b": "2", "extra": "x"}},
+		{"prune", nil, true, false, map[string]string{"a": "1", "b": "2"}},
+		{"selected keys", []string{"b", "extra"}, true, false, map[string]string{"a": "old", "b": "2"}},
+		{"dry run", nil, true, true, map[string]string{"a": "old", "extra": "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := nimbusectest.NewServer()
+			defer srv.Close()
+			api := srv.API()
+
+			template := srv.AddDomain(nimbusec.Domain{Name: "template.com"})
+			target := srv.AddDomain(nimbusec.Domain{Name: "target.com"})
+			set := func(domain int, configs map[string]string) {
+				for key, value := range configs {
+					if _, err := api.SetDomainConfig(domain, key, value); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			set(template.Id, map[string]string{"a": "1", "b": "2"})
+			set(target.Id, map[string]string{"a": "old", "extra": "x"})
+
+			results, err := api.CopyDomainConfigs(nimbusec.ConfigCopy{
+				Template: template.Id,
+				Domains:  []int{target.Id, 9999},
+				Keys:     tt.keys,
+				Prune:    tt.prune,
+				DryRun:   tt.dryRun,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if results[0].Err != nil {
+				t.Fatal(results[0].Err)
+			}
+			if len(results[0].Changes) == 0 {
+				t.Error("expected changes for the target")
+			}
+			if !nimbusec.IsNotFound(results[1].Err) {
+				t.Errorf("unknown domain: error = %v, want not found", results[1].Err)
+			}
+			if got := srv.DomainConfigs(target.Id); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("configs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyDomainConfigsCancelled(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	template := srv.AddDomain(nimbusec.Domain{Name: "template.com"})
+	var domains []int
+	for _, name := range []string{"a.com", "b.com", "c.com"} {
+		domains = append(domains, srv.AddDomain(nimbusec.Domain{Name: name}).Id)
+	}
+
+	// the transport cancels ctx after the template was read.
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	api := srv.API(nimbusec.WithTransport(cancelTransport{cancel}))
+	results, err := api.CopyDomainConfigsContext(ctx, nimbusec.ConfigCopy{Template: template.Id, Domains: domains, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(domains) {
+		t.Fatalf("got %d results, want %d", len(results), len(domains))
+	}
+	for i, result := range results {
+		if result.Domain != domains[i] {
+			t.Errorf("result %d is for domain %d, want %d", i, result.Domain, domains[i])
+		}
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("domain %d: error = %v, want context.Canceled", result.Domain, result.Err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package diff parses the content change diffs of nimbusec results
+// (Result.Diff) into a structured model, extracts injected content from added
+// lines and renders diffs as unified text, colored terminal output or HTML.
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Kind is the kind of a diff line.
+type Kind int
+
+const (
+	Context Kind = iota // line is present in both versions
+	Added               // line was added
+	Removed             // line was removed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	}
+	return "context"
+}
+
+// prefix returns the unified diff marker of the kind.
+func (k Kind) prefix() string {
+	switch k {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	}
+	return " "
+}
+
+// Line is a single line of a hunk. OldLine and NewLine are the line numbers
+// in the old and new version, or 0 if the line does not exist there.
+type Line struct {
+	Kind    Kind
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// Hunk is a contiguous region of changes.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Section  string // optional text after the hunk range, e.g. a function name
+	Lines    []Line
+}
+
+// Diff is a parsed content change.
+type Diff struct {
+	OldFile string // name from the --- header, if present
+	NewFile string // name from the +++ header, if present
+	Hunks   []Hunk
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// ParseResult parses the diff of a content change result.
+func ParseResult(result nimbusec.Result) (*Diff, error) {
+	return Parse(result.Diff)
+}
+
+// Parse parses a unified diff. Diffs without hunk headers are treated as a
+// single hunk starting at line 1.
+func Parse(s string) (*Diff, error) {
+	d := new(Diff)
+	var hunk *Hunk
+	oldLine, newLine := 0, 0
+
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+
+		switch {
+		case hunk == nil && strings.HasPrefix(text, "--- "):
+			d.OldFile = strings.TrimSpace(strings.TrimPrefix(text, "--- "))
+			continue
+
+		case hunk == nil && strings.HasPrefix(text, "+++ "):
+			d.NewFile = strings.TrimSpace(strings.TrimPrefix(text, "+++ "))
+			continue
+
+		case strings.HasPrefix(text, "@@"):
+			m := hunkHeader.FindStringSubmatch(text)
+			if m == nil {
+				return nil, fmt.Errorf("diff: line %d: malformed hunk header %q", n, text)
+			}
+
+			d.Hunks = append(d.Hunks, Hunk{
+				OldStart: atoi(m[1], 0),
+				OldLines: atoi(m[2], 1),
+				NewStart: atoi(m[3], 0),
+				NewLines: atoi(m[4], 1),
+				Section:  m[5],
+			})
+			hunk = &d.Hunks[len(d.Hunks)-1]
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+			continue
+
+		case strings.HasPrefix(text, `\ `):
+			// "\ No newline at end of file"
+			continue
+		}
+
+		if hunk == nil {
+			d.Hunks = append(d.Hunks, Hunk{OldStart: 1, NewStart: 1})
+			hunk = &d.Hunks[len(d.Hunks)-1]
+			oldLine, newLine = 1, 1
+		}
+
+		line := Line{Kind: Context}
+		if text != "" {
+			switch text[0] {
+			case '+':
+				line.Kind = Added
+			case '-':
+				line.Kind = Removed
+			}
+			if text[0] == '+' || text[0] == '-' || text[0] == ' ' {
+				text = text[1:]
+			}
+		}
+		line.Text = text
+
+		switch line.Kind {
+		case Added:
+			line.NewLine = newLine
+			newLine++
+		case Removed:
+			line.OldLine = oldLine
+			oldLine++
+		default:
+			line.OldLine, line.NewLine = oldLine, newLine
+			oldLine++
+			newLine++
+		}
+
+		hunk.Lines = append(hunk.Lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// hunks without header get their sizes from the parsed lines.
+	for i := range d.Hunks {
+		h := &d.Hunks[i]
+		if h.OldLines == 0 && h.NewLines == 0 {
+			for _, line := range h.Lines {
+				if line.Kind != Added {
+					h.OldLines++
+				}
+				if line.Kind != Removed {
+					h.NewLines++
+				}
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func atoi(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// Lines returns all lines of the given kind.
+func (d *Diff) Lines(kind Kind) []Line {
+	lines := make([]Line, 0)
+	for _, h := range d.Hunks {
+		for _, line := range h.Lines {
+			if line.Kind == kind {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// Stats returns the number of added and removed lines.
+func (d *Diff) Stats() (added, removed int) {
+	for _, h := range d.Hunks {
+		for _, line := range h.Lines {
+			switch line.Kind {
+			case Added:
+				added++
+			case Removed:
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
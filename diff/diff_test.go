@@ -0,0 +1,137 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cumulodev/nimbusec/diff"
+)
+
+const unified = `--- a/index.html
++++ b/index.html
+@@ -1,3 +1,4 @@ <head>
+ <html>
+-<title>old</title>
++<title>new</title>
++<script src="//evil.example.net/x.js"></script>
+ </html>
+\ No newline at end of file
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		hunks   int
+		added   int
+		removed int
+		err     bool
+	}{
+		{"empty", "", 0, 0, 0, false},
+		{"unified", unified, 1, 2, 1, false},
+		{"without header", "+added\n context\n-removed\n", 1, 1, 1, false},
+		{"two hunks", "@@ -1 +1 @@\n-a\n+b\n@@ -10,2 +10,2 @@\n x\n-c\n+d\n", 2, 2, 2, false},
+		{"malformed header", "@@ -a +b @@\n", 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := diff.Parse(tt.input)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(d.Hunks) != tt.hunks {
+				t.Errorf("got %d hunks, want %d", len(d.Hunks), tt.hunks)
+			}
+			added, removed := d.Stats()
+			if added != tt.added || removed != tt.removed {
+				t.Errorf("stats = +%d -%d, want +%d -%d", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+func TestParseLineNumbers(t *testing.T) {
+	d, err := diff.Parse(unified)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.OldFile != "a/index.html" || d.NewFile != "b/index.html" {
+		t.Errorf("files = %q, %q", d.OldFile, d.NewFile)
+	}
+
+	h := d.Hunks[0]
+	if h.Section != "<head>" {
+		t.Errorf("section = %q, want <head>", h.Section)
+	}
+
+	want := []diff.Line{
+		{Kind: diff.Context, Text: "<html>", OldLine: 1, NewLine: 1},
+		{Kind: diff.Removed, Text: "<title>old</title>", OldLine: 2},
+		{Kind: diff.Added, Text: "<title>new</title>", NewLine: 2},
+		{Kind: diff.Added, Text: `<script src="//evil.example.net/x.js"></script>`, NewLine: 3},
+		{Kind: diff.Context, Text: "</html>", OldLine: 3, NewLine: 4},
+	}
+	if len(h.Lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(h.Lines), len(want))
+	}
+	for i, line := range h.Lines {
+		if line != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, line, want[i])
+		}
+	}
+}
+
+func TestParseWithoutHeaderSizes(t *testing.T) {
+	d, err := diff.Parse("+a\n b\n-c\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := d.Hunks[0]
+	if h.OldStart != 1 || h.OldLines != 2 || h.NewStart != 1 || h.NewLines != 2 {
+		t.Errorf("hunk = -%d,%d +%d,%d, want -1,2 +1,2", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	}
+}
+
+func TestRender(t *testing.T) {
+	d, err := diff.Parse(unified)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the parsed diff renders back without the "no newline" marker.
+	want := strings.Replace(unified, "\\ No newline at end of file\n", "", 1)
+	if got := d.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+
+	var color strings.Builder
+	if err := d.WriteColor(&color); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(color.String(), "\x1b[32m+<title>new</title>\x1b[0m") {
+		t.Errorf("added line not colored green:\n%q", color.String())
+	}
+
+	var html strings.Builder
+	if err := d.WriteHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`<tr class="diff-removed"><td>2</td><td></td><td><pre>-&lt;title&gt;old&lt;/title&gt;</pre></td></tr>`,
+		`<tr class="diff-hunk"><td colspan="3">@@ -1,3 +1,4 @@ &lt;head&gt;</td></tr>`,
+	} {
+		if !strings.Contains(html.String(), s) {
+			t.Errorf("html does not contain %s:\n%s", s, html.String())
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package diff
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	scriptTag   = regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script\s*>|<script\b[^>]*>`)
+	iframeTag   = regexp.MustCompile(`(?is)<iframe\b[^>]*>`)
+	externalURL = regexp.MustCompile(`(?i)(?:\bhttps?:)?//[a-z0-9.-]+(?::\d+)?[^\s"'<>()\\]*`)
+)
+
+// addedBlocks returns the contiguous runs of added lines, so that tags
+// spanning multiple lines are found as well.
+func (d *Diff) addedBlocks() []string {
+	var blocks []string
+	for _, h := range d.Hunks {
+		var block []string
+		for _, line := range h.Lines {
+			if line.Kind == Added {
+				block = append(block, line.Text)
+				continue
+			}
+			if len(block) > 0 {
+				blocks = append(blocks, strings.Join(block, "\n"))
+				block = nil
+			}
+		}
+		if len(block) > 0 {
+			blocks = append(blocks, strings.Join(block, "\n"))
+		}
+	}
+	return blocks
+}
+
+func (d *Diff) findAdded(pattern *regexp.Regexp) []string {
+	found := make([]string, 0)
+	for _, block := range d.addedBlocks() {
+		found = append(found, pattern.FindAllString(block, -1)...)
+	}
+	return found
+}
+
+// Scripts returns all script elements found in added lines.
+func (d *Diff) Scripts() []string {
+	return d.findAdded(scriptTag)
+}
+
+// Iframes returns all iframe tags found in added lines.
+func (d *Diff) Iframes() []string {
+	return d.findAdded(iframeTag)
+}
+
+// ExternalURLs returns all absolute and protocol relative urls in added lines
+// which do not point to host or one of its subdomains. With an empty host,
+// all urls are returned.
+func (d *Diff) ExternalURLs(host string) []string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	seen := map[string]bool{}
+	urls := make([]string, 0)
+	for _, raw := range d.findAdded(externalURL) {
+		target := raw
+		if strings.HasPrefix(target, "//") {
+			target = "http:" + target
+		}
+
+		u, err := url.Parse(target)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		h := strings.ToLower(u.Hostname())
+		if host != "" && (h == host || strings.HasSuffix(h, "."+host)) {
+			continue
+		}
+
+		if !seen[raw] {
+			seen[raw] = true
+			urls = append(urls, raw)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,48 @@
+package diff_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cumulodev/nimbusec/diff"
+)
+
+func TestInjected(t *testing.T) {
+	const input = `@@ -1,2 +1,6 @@
+ <script src="https://old.example.net/a.js"></script>
++<script>
++document.write("x");
++</script>
++<iframe src="https://www.example.com/frame" width=0>
++<a href="https://cdn.example.com/lib.js">https://tracker.example.org/p</a> //evil.example.net/x
+ <p>
+`
+	d, err := diff.Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"scripts", d.Scripts(), []string{"<script>\ndocument.write(\"x\");\n</script>"}},
+		{"iframes", d.Iframes(), []string{`<iframe src="https://www.example.com/frame" width=0>`}},
+		{"external urls", d.ExternalURLs("Example.com."), []string{"https://tracker.example.org/p", "//evil.example.net/x"}},
+		{"all urls", d.ExternalURLs(""), []string{
+			"https://www.example.com/frame",
+			"https://cdn.example.com/lib.js",
+			"https://tracker.example.org/p",
+			"//evil.example.net/x",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// ANSI escape sequences used by WriteColor.
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+	colorBold  = "\x1b[1m"
+)
+
+// header renders the @@ line of the hunk.
+func (h Hunk) header() string {
+	header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	if h.Section != "" {
+		header += " " + h.Section
+	}
+	return header
+}
+
+// String renders the diff in unified format.
+func (d *Diff) String() string {
+	var buf strings.Builder
+	d.WriteUnified(&buf)
+	return buf.String()
+}
+
+// WriteUnified writes the diff in unified format.
+func (d *Diff) WriteUnified(w io.Writer) error {
+	return d.write(w, "", "", "", "")
+}
+
+// WriteColor writes the diff in unified format, colored with ANSI escape
+// sequences for terminal output.
+func (d *Diff) WriteColor(w io.Writer) error {
+	return d.write(w, colorBold, colorCyan, colorGreen, colorRed)
+}
+
+func (d *Diff) write(w io.Writer, file, hunk, added, removed string) error {
+	bw := bufio.NewWriter(w)
+	color := func(c, s string) {
+		if c == "" {
+			bw.WriteString(s + "\n")
+			return
+		}
+		bw.WriteString(c + s + colorReset + "\n")
+	}
+
+	if d.OldFile != "" || d.NewFile != "" {
+		color(file, "--- "+d.OldFile)
+		color(file, "+++ "+d.NewFile)
+	}
+
+	for _, h := range d.Hunks {
+		color(hunk, h.header())
+		for _, line := range h.Lines {
+			text := line.Kind.prefix() + line.Text
+			switch line.Kind {
+			case Added:
+				color(added, text)
+			case Removed:
+				color(removed, text)
+			default:
+				color("", text)
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// WriteHTML writes the diff as HTML table with line numbers. Lines carry the
+// css classes "diff-added", "diff-removed" and "diff-context", hunk headers
+// the class "diff-hunk".
+func (d *Diff) WriteHTML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<table class="diff">` + "\n")
+
+	for _, h := range d.Hunks {
+		fmt.Fprintf(bw, `<tr class="diff-hunk"><td colspan="3">%s</td></tr>`+"\n", html.EscapeString(h.header()))
+		for _, line := range h.Lines {
+			fmt.Fprintf(bw, `<tr class="diff-%s"><td>%s</td><td>%s</td><td><pre>%s%s</pre></td></tr>`+"\n",
+				line.Kind, lineNumber(line.OldLine), lineNumber(line.NewLine),
+				line.Kind.prefix(), html.EscapeString(line.Text))
+		}
+	}
+
+	bw.WriteString("</table>\n")
+	return bw.Flush()
+}
+
+func lineNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
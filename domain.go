@@ -1,10 +1,11 @@
 package nimbusec
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"strconv"
-	"time"
+
+	"github.com/cumulodev/nimbusec/filter"
 )
 
 // Domain represents a nimbusec monitored domain.
@@ -24,10 +25,6 @@ type DomainEvent struct {
 	Machine string    `json:"machine"`
 }
 
-type Timestamp struct {
-	time.Time
-}
-
 type DomainMetadata struct {
 	LastDeepScan Timestamp `json:"lastDeepScan"` // timestamp (in ms) of last external scan of the whole site
 	NextDeepScan Timestamp `json:"nextDeepScan"` // timestamp (in ms) for next external scan of the whole site
@@ -49,11 +46,11 @@ type DomainApplication struct {
 }
 
 type DomainIssues struct {
-	DomainID int    `json:"domainId,omitempty"`
-	Category string `json:"category"`
-	Issues   int    `json:"issues"`
-	Severity int    `json:"severity"`
-	Src      string `json:"src"`
+	DomainID int      `json:"domainId,omitempty"`
+	Category string   `json:"category"`
+	Issues   int      `json:"issues"`
+	Severity Severity `json:"severity"`
+	Src      string   `json:"src"`
 }
 
 type Screenshot struct {
@@ -70,31 +67,18 @@ type Screenshot struct {
 	} `json:"current"`
 }
 
-func (t Timestamp) MarshalJSON() ([]byte, error) {
-	ts := t.Unix()
-	stamp := strconv.FormatInt(ts*1000, 10)
-	return []byte(stamp), nil
-}
-
-func (t *Timestamp) UnmarshalJSON(b []byte) error {
-	if bytes.Equal(b, []byte("null")) {
-		return nil
-	}
-
-	ts, err := strconv.ParseInt(string(b), 10, 64)
-	if err != nil {
-		return err
-	}
-
-	*t = Timestamp{time.Unix(ts/1000, 0)}
-	return nil
+// CreateDomain issues the API to create the given domain. The domain is sent
+// as is; validation is opt-in, call Domain.Normalize and Domain.Validate
+// first to reject invalid domains before a request is made.
+func (a *API) CreateDomain(domain *Domain) (*Domain, error) {
+	return a.CreateDomainContext(context.Background(), domain)
 }
 
-// CreateDomain issues the API to create the given domain.
-func (a *API) CreateDomain(domain *Domain) (*Domain, error) {
+// CreateDomainContext is like CreateDomain but with a context.
+func (a *API) CreateDomainContext(ctx context.Context, domain *Domain) (*Domain, error) {
 	dst := new(Domain)
 	url := a.BuildURL("/v2/domain")
-	err := a.Post(url, Params{}, domain, dst)
+	err := a.PostContext(ctx, url, Params{}, domain, dst)
 	return dst, err
 }
 
@@ -102,9 +86,14 @@ func (a *API) CreateDomain(domain *Domain) (*Domain, error) {
 // of failing when attempting to create a duplicate domain, this method will update
 // the remote domain instead.
 func (a *API) CreateOrUpdateDomain(domain *Domain) (*Domain, error) {
+	return a.CreateOrUpdateDomainContext(context.Background(), domain)
+}
+
+// CreateOrUpdateDomainContext is like CreateOrUpdateDomain but with a context.
+func (a *API) CreateOrUpdateDomainContext(ctx context.Context, domain *Domain) (*Domain, error) {
 	dst := new(Domain)
 	url := a.BuildURL("/v2/domain")
-	err := a.Post(url, Params{"upsert": "true"}, domain, dst)
+	err := a.PostContext(ctx, url, Params{"upsert": "true"}, domain, dst)
 	return dst, err
 }
 
@@ -112,23 +101,38 @@ func (a *API) CreateOrUpdateDomain(domain *Domain) (*Domain, error) {
 // of failing when attempting to create a duplicate domain, this method will fetch
 // the remote domain instead.
 func (a *API) CreateOrGetDomain(domain *Domain) (*Domain, error) {
+	return a.CreateOrGetDomainContext(context.Background(), domain)
+}
+
+// CreateOrGetDomainContext is like CreateOrGetDomain but with a context.
+func (a *API) CreateOrGetDomainContext(ctx context.Context, domain *Domain) (*Domain, error) {
 	dst := new(Domain)
 	url := a.BuildURL("/v2/domain")
-	err := a.Post(url, Params{"upsert": "false"}, domain, dst)
+	err := a.PostContext(ctx, url, Params{"upsert": "false"}, domain, dst)
 	return dst, err
 }
 
 // GetDomain retrieves a domain from the API by its ID.
 func (a *API) GetDomain(domain int) (*Domain, error) {
+	return a.GetDomainContext(context.Background(), domain)
+}
+
+// GetDomainContext is like GetDomain but with a context.
+func (a *API) GetDomainContext(ctx context.Context, domain int) (*Domain, error) {
 	dst := new(Domain)
 	url := a.BuildURL("/v2/domain/%d", domain)
-	err := a.Get(url, Params{}, dst)
+	err := a.GetContext(ctx, url, Params{}, dst)
 	return dst, err
 }
 
 // GetDomainByName fetches an domain by its name.
 func (a *API) GetDomainByName(name string) (*Domain, error) {
-	domains, err := a.FindDomains(fmt.Sprintf("name eq \"%s\"", name))
+	return a.GetDomainByNameContext(context.Background(), name)
+}
+
+// GetDomainByNameContext is like GetDomainByName but with a context.
+func (a *API) GetDomainByNameContext(ctx context.Context, name string) (*Domain, error) {
+	domains, err := a.FindDomainsContext(ctx, filter.Eq("name", name).String())
 	if err != nil {
 		return nil, err
 	}
@@ -146,6 +150,11 @@ func (a *API) GetDomainByName(name string) (*Domain, error) {
 
 // FindDomains searches for domains that match the given filter criteria.
 func (a *API) FindDomains(filter string) ([]Domain, error) {
+	return a.FindDomainsContext(context.Background(), filter)
+}
+
+// FindDomainsContext is like FindDomains but with a context.
+func (a *API) FindDomainsContext(ctx context.Context, filter string) ([]Domain, error) {
 	params := Params{}
 	if filter != EmptyFilter {
 		params["q"] = filter
@@ -153,15 +162,20 @@ func (a *API) FindDomains(filter string) ([]Domain, error) {
 
 	dst := make([]Domain, 0)
 	url := a.BuildURL("/v2/domain")
-	err := a.Get(url, params, &dst)
+	err := a.GetContext(ctx, url, params, &dst)
 	return dst, err
 }
 
 // UpdateDOmain issues the nimbusec API to update a domain.
 func (a *API) UpdateDomain(domain *Domain) (*Domain, error) {
+	return a.UpdateDomainContext(context.Background(), domain)
+}
+
+// UpdateDomainContext is like UpdateDomain but with a context.
+func (a *API) UpdateDomainContext(ctx context.Context, domain *Domain) (*Domain, error) {
 	dst := new(Domain)
 	url := a.BuildURL("/v2/domain/%d", domain.Id)
-	err := a.Put(url, Params{}, domain, dst)
+	err := a.PutContext(ctx, url, Params{}, domain, dst)
 	return dst, err
 }
 
@@ -169,8 +183,13 @@ func (a *API) UpdateDomain(domain *Domain) (*Domain, error) {
 // all assiciated data will only be marked as deleted, whereas with clean=true the data
 // will also be removed from the nimbusec system.
 func (a *API) DeleteDomain(d *Domain, clean bool) error {
+	return a.DeleteDomainContext(context.Background(), d, clean)
+}
+
+// DeleteDomainContext is like DeleteDomain but with a context.
+func (a *API) DeleteDomainContext(ctx context.Context, d *Domain, clean bool) error {
 	url := a.BuildURL("/v2/domain/%d", d.Id)
-	return a.Delete(url, Params{
+	return a.DeleteContext(ctx, url, Params{
 		"pleaseremovealldata": fmt.Sprintf("%t", clean),
 	})
 }
@@ -178,6 +197,11 @@ func (a *API) DeleteDomain(d *Domain, clean bool) error {
 // FindInfected searches for domains that have pending Results that match the
 // given filter criteria.
 func (a *API) FindInfected(filter string) ([]Domain, error) {
+	return a.FindInfectedContext(context.Background(), filter)
+}
+
+// FindInfectedContext is like FindInfected but with a context.
+func (a *API) FindInfectedContext(ctx context.Context, filter string) ([]Domain, error) {
 	params := make(map[string]string)
 	if filter != EmptyFilter {
 		params["q"] = filter
@@ -185,40 +209,64 @@ func (a *API) FindInfected(filter string) ([]Domain, error) {
 
 	dst := make([]Domain, 0)
 	url := a.BuildURL("/v2/infected")
-	err := a.Get(url, params, &dst)
+	err := a.GetContext(ctx, url, params, &dst)
 	return dst, err
 }
 
 // ListDomainConfigs fetches the list of all available configuration keys for the
 // given domain.
 func (a *API) ListDomainConfigs(domain int) ([]string, error) {
+	return a.ListDomainConfigsContext(context.Background(), domain)
+}
+
+// ListDomainConfigsContext is like ListDomainConfigs but with a context.
+func (a *API) ListDomainConfigsContext(ctx context.Context, domain int) ([]string, error) {
 	dst := make([]string, 0)
 	url := a.BuildURL("/v2/domain/%d/config", domain)
-	err := a.Get(url, Params{}, &dst)
+	err := a.GetContext(ctx, url, Params{}, &dst)
 	return dst, err
 }
 
 // GetDomainConfig fetches the requested domain configuration.
 func (a *API) GetDomainConfig(domain int, key string) (string, error) {
+	return a.GetDomainConfigContext(context.Background(), domain, key)
+}
+
+// GetDomainConfigContext is like GetDomainConfig but with a context.
+func (a *API) GetDomainConfigContext(ctx context.Context, domain int, key string) (string, error) {
 	url := a.BuildURL("/v2/domain/%d/config/%s/", domain, key)
-	return a.getTextPlain(url, Params{})
+	return a.getTextPlain(ctx, url, Params{})
 }
 
 // SetDomainConfig sets the domain configuration `key` to the requested value.
 // This method will create the domain configuration if it does not exist yet.
 func (a *API) SetDomainConfig(domain int, key string, value string) (string, error) {
+	return a.SetDomainConfigContext(context.Background(), domain, key, value)
+}
+
+// SetDomainConfigContext is like SetDomainConfig but with a context.
+func (a *API) SetDomainConfigContext(ctx context.Context, domain int, key string, value string) (string, error) {
 	url := a.BuildURL("/v2/domain/%d/config/%s/", domain, key)
-	return a.putTextPlain(url, Params{}, value)
+	return a.putTextPlain(ctx, url, Params{}, value)
 }
 
 // DeleteDomainConfig issues the API to delete the domain configuration with
 // the provided key.
 func (a *API) DeleteDomainConfig(domain int, key string) error {
+	return a.DeleteDomainConfigContext(context.Background(), domain, key)
+}
+
+// DeleteDomainConfigContext is like DeleteDomainConfig but with a context.
+func (a *API) DeleteDomainConfigContext(ctx context.Context, domain int, key string) error {
 	url := a.BuildURL("/v2/domain/%d/config/%s/", domain, key)
-	return a.Delete(url, Params{})
+	return a.DeleteContext(ctx, url, Params{})
 }
 
 func (a *API) GetDomainEvent(domain int, filter string, limit int) ([]DomainEvent, error) {
+	return a.GetDomainEventContext(context.Background(), domain, filter, limit)
+}
+
+func (a *API) GetDomainEventContext(ctx context.Context, domain int, filter string, limit int) ([]DomainEvent, error) {
 	params := Params{
 		"limit": strconv.Itoa(limit),
 	}
@@ -228,26 +276,38 @@ func (a *API) GetDomainEvent(domain int, filter string, limit int) ([]DomainEven
 
 	dst := make([]DomainEvent, 0)
 	url := a.BuildURL("/v2/domain/%d/events", domain)
-	err := a.Get(url, params, &dst)
+	err := a.GetContext(ctx, url, params, &dst)
 	return dst, err
 }
 
 func (a *API) CreateDomainEvent(domain int, log *DomainEvent) error {
+	return a.CreateDomainEventContext(context.Background(), domain, log)
+}
+
+func (a *API) CreateDomainEventContext(ctx context.Context, domain int, log *DomainEvent) error {
 	url := a.BuildURL("/v2/domain/%d/events", domain)
-	return a.Post(url, Params{}, log, nil)
+	return a.PostContext(ctx, url, Params{}, log, nil)
 }
 
 func (a *API) GetDomainMetadata(domain int) (*DomainMetadata, error) {
+	return a.GetDomainMetadataContext(context.Background(), domain)
+}
+
+func (a *API) GetDomainMetadataContext(ctx context.Context, domain int) (*DomainMetadata, error) {
 	dst := new(DomainMetadata)
 	url := a.BuildURL("/v2/domain/%d/metadata", domain)
-	err := a.Get(url, Params{}, &dst)
+	err := a.GetContext(ctx, url, Params{}, &dst)
 	return dst, err
 }
 
 func (a *API) GetDomainApplications(domain int) ([]DomainApplication, error) {
+	return a.GetDomainApplicationsContext(context.Background(), domain)
+}
+
+func (a *API) GetDomainApplicationsContext(ctx context.Context, domain int) ([]DomainApplication, error) {
 	dst := make([]DomainApplication, 0)
 	url := a.BuildURL("/v2/domain/%d/applications", domain)
-	err := a.Get(url, Params{}, &dst)
+	err := a.GetContext(ctx, url, Params{}, &dst)
 	return dst, err
 }
 
@@ -255,21 +315,37 @@ func (a *API) GetDomainScreenshot(domain int) (*Screenshot, error) {
 	return a.GetSpecificDomainScreenshot(domain, "EU", "desktop")
 }
 
+func (a *API) GetDomainScreenshotContext(ctx context.Context, domain int) (*Screenshot, error) {
+	return a.GetSpecificDomainScreenshotContext(ctx, domain, "EU", "desktop")
+}
+
 func (a *API) GetSpecificDomainScreenshot(domain int, region, viewport string) (*Screenshot, error) {
+	return a.GetSpecificDomainScreenshotContext(context.Background(), domain, region, viewport)
+}
+
+func (a *API) GetSpecificDomainScreenshotContext(ctx context.Context, domain int, region, viewport string) (*Screenshot, error) {
 	dst := new(Screenshot)
 	url := a.BuildURL("/v2/domain/%d/screenshot/%s/%s", domain, region, viewport)
-	err := a.Get(url, Params{}, &dst)
+	err := a.GetContext(ctx, url, Params{}, &dst)
 	return dst, err
 }
 
 func (a *API) GetImage(url string) ([]byte, error) {
-	resolved := a.BuildURL(url)
-	return a.getBytes(resolved, Params{})
+	return a.GetImageContext(context.Background(), url)
+}
+
+func (a *API) GetImageContext(ctx context.Context, url string) ([]byte, error) {
+	resolved := a.BuildURL("%s", url)
+	return a.getBytes(ctx, resolved, Params{})
 }
 
 func (a *API) GetIssues() ([]DomainIssues, error) {
+	return a.GetIssuesContext(context.Background())
+}
+
+func (a *API) GetIssuesContext(ctx context.Context) ([]DomainIssues, error) {
 	dst := make([]DomainIssues, 0)
 	url := a.BuildURL("/v2/domainissues")
-	err := a.Get(url, Params{}, &dst)
+	err := a.GetContext(ctx, url, Params{}, &dst)
 	return dst, err
 }
@@ -0,0 +1,47 @@
+package nimbusec_test
+
+import (
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+// Validation is opt-in: domains created before it existed must still be
+// sent to the API unchanged.
+func TestDomainWithoutValidation(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	legacy := nimbusec.Domain{Name: "Legacy.Example.com", DeepScan: "/", FastScans: []string{"/", "/"}}
+	if legacy.Validate() == nil {
+		t.Fatal("legacy domain is expected to be invalid")
+	}
+
+	tests := []struct {
+		name string
+		send func(d *nimbusec.Domain) (*nimbusec.Domain, error)
+	}{
+		{"create", api.CreateDomain},
+		{"create or update", api.CreateOrUpdateDomain},
+		{"create or get", api.CreateOrGetDomain},
+		{"update", api.UpdateDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := legacy
+			if domains := srv.Domains(); len(domains) > 0 {
+				d.Id = domains[0].Id
+			}
+			got, err := tt.send(&d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != legacy.Name {
+				t.Errorf("name = %q, want %q", got.Name, legacy.Name)
+			}
+		})
+	}
+}
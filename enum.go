@@ -0,0 +1,42 @@
+package nimbusec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseEnum parses the name or number of an enumeration value. names maps
+// the lower case names to their numbers.
+func parseEnum(kind string, text string, names map[string]int) (int, error) {
+	if n, err := strconv.Atoi(text); err == nil {
+		return n, nil
+	}
+
+	if n, ok := names[strings.ToLower(text)]; ok {
+		return n, nil
+	}
+
+	return 0, fmt.Errorf("nimbusec: invalid %s %q", kind, text)
+}
+
+// unmarshalEnum decodes an enumeration value given either as json number or
+// as json string containing the name or number.
+func unmarshalEnum(kind string, b []byte, names map[string]int) (int, error) {
+	if bytes.Equal(b, []byte("null")) {
+		return 0, nil
+	}
+
+	var text string
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &text); err != nil {
+			return 0, err
+		}
+	} else {
+		text = string(b)
+	}
+
+	return parseEnum(kind, text, names)
+}
@@ -0,0 +1,77 @@
+package nimbusec
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEnumJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Notification
+	}{
+		{`{"transport":"mail","serverside":1,"content":2,"blacklist":3}`,
+			Notification{Transport: TransportMail, ServerSide: SeverityMedium, Content: SeverityHigh, Blacklist: SeveritySevere}},
+		{`{"transport":"SMS","serverside":"high","content":"2","blacklist":null}`,
+			Notification{Transport: TransportSMS, ServerSide: SeverityHigh, Content: SeverityHigh}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Notification
+			if err := json.Unmarshal([]byte(tt.input), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	data, err := json.Marshal(Result{Status: StatusFalsePositive, Severity: SeveritySevere})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw["status"] != 3.0 || raw["severity"] != 3.0 {
+		t.Errorf("Marshal = %s, want numeric status and severity", data)
+	}
+}
+
+func TestParseEnum(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ResultStatus
+		err   bool
+	}{
+		{"1", StatusPending, false},
+		{"pending", StatusPending, false},
+		{"FalsePositive", StatusFalsePositive, false},
+		{"removed", StatusRemoved, false},
+		{"unknown", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got ResultStatus
+			err := got.UnmarshalText([]byte(tt.input))
+			if (err != nil) != tt.err {
+				t.Fatalf("UnmarshalText(%q) error = %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalText(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if got := StatusAcknowledged.String(); got != "acknowledged" {
+		t.Errorf("String() = %q, want acknowledged", got)
+	}
+	if got := ResultStatus(9).String(); got != "9" {
+		t.Errorf("String() = %q, want 9", got)
+	}
+}
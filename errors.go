@@ -0,0 +1,159 @@
+package nimbusec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned by GetXYByName functions if the requested entity can
+	// not be found. An *APIError with status 404 matches ErrNotFound as well.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is matched by an *APIError with status 409, e.g. when creating
+	// a duplicate entity without upsert.
+	ErrConflict = errors.New("conflict")
+
+	// ErrUnauthorized is matched by an *APIError with status 401 or 403, i.e. when
+	// the credentials are invalid or lack the required privileges.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrQuotaExceeded is matched by an *APIError signaling that the contingent
+	// of the used bundle is exhausted.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// maxErrorBody limits how much of an error response body is kept in an APIError.
+const maxErrorBody = 64 * 1024
+
+// APIError is returned for every response of the nimbusec API with a status
+// code other than 2xx.
+type APIError struct {
+	StatusCode int    // HTTP status code of the response
+	Method     string // HTTP method of the failed request
+	URL        string // url of the failed request
+	Message    string // error message from the x-nimbusec-error header
+	Body       []byte // (possibly truncated) body of the response
+}
+
+// newAPIError builds an APIError from the response. The caller is responsible
+// to close the response body.
+func newAPIError(resp *http.Response) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    resp.Header.Get("x-nimbusec-error"),
+	}
+
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+
+	if resp.Body != nil {
+		e.Body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	}
+
+	return e
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+
+	return fmt.Sprintf("nimbusec: %s %s: %d %s", e.Method, e.URL, e.StatusCode, msg)
+}
+
+// Is reports whether the error matches one of the sentinel errors ErrNotFound,
+// ErrConflict, ErrUnauthorized or ErrQuotaExceeded.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUnauthorized:
+		return (e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden) && !e.isQuota()
+	case ErrQuotaExceeded:
+		return e.isQuota()
+	}
+
+	return false
+}
+
+// isQuota reports whether the error was caused by an exhausted bundle. The
+// API signals this either with 402 or with a 403 whose message mentions the
+// quota or contingent.
+func (e *APIError) isQuota() bool {
+	if e.StatusCode == http.StatusPaymentRequired {
+		return true
+	}
+
+	if e.StatusCode != http.StatusForbidden {
+		return false
+	}
+
+	msg := strings.ToLower(e.Message)
+	return strings.Contains(msg, "quota") || strings.Contains(msg, "contingent")
+}
+
+// IsNotFound reports whether err signals that the requested entity does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsConflict reports whether err signals a conflict with an existing entity.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// IsUnauthorized reports whether err signals invalid or insufficient credentials.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsQuotaExceeded reports whether err signals an exhausted bundle contingent.
+func IsQuotaExceeded(err error) bool {
+	return errors.Is(err, ErrQuotaExceeded)
+}
+
+// ValidationError describes an invalid field of an entity. It is returned
+// before a request is sent.
+type ValidationError struct {
+	Field   string // json name of the field, with index for lists, e.g. "fastScans[2]"
+	Value   string // the invalid value
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %q: %s", e.Field, e.Value, e.Message)
+}
+
+// ValidationErrors collects all invalid fields of an entity.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "nimbusec: invalid " + strings.Join(msgs, "; ")
+}
+
+// add appends a validation error for field.
+func (e *ValidationErrors) add(field, value, format string, args ...interface{}) {
+	*e = append(*e, &ValidationError{Field: field, Value: value, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns e as error or nil if there are no errors.
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,116 @@
+package nimbusec_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestAPIErrorSentinels(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com", Scheme: "https"})
+	api := srv.API()
+
+	requests := []struct {
+		method string
+		do     func() error
+	}{
+		{"GET", func() error { _, err := api.GetDomain(domain.Id); return err }},
+		{"PUT", func() error { _, err := api.UpdateDomain(&domain); return err }},
+		{"DELETE", func() error { return api.DeleteDomain(&domain, false) }},
+	}
+
+	tests := []struct {
+		status       int
+		message      string
+		notFound     bool
+		conflict     bool
+		unauthorized bool
+		quota        bool
+	}{
+		{http.StatusNotFound, "domain not found", true, false, false, false},
+		{http.StatusConflict, "domain already exists", false, true, false, false},
+		{http.StatusUnauthorized, "invalid signature", false, false, true, false},
+		{http.StatusForbidden, "insufficient privileges", false, false, true, false},
+		{http.StatusForbidden, "Quota exceeded", false, false, false, true},
+		{http.StatusForbidden, "contingent of bundle exhausted", false, false, false, true},
+		{http.StatusPaymentRequired, "", false, false, false, true},
+		{http.StatusBadRequest, "invalid filter", false, false, false, false},
+		{http.StatusInternalServerError, "", false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		srv.Fail(tt.status, tt.message)
+		for _, r := range requests {
+			err := r.do()
+
+			var apiErr *nimbusec.APIError
+			if !errors.As(err, &apiErr) {
+				t.Errorf("%s %d: error = %v, want an APIError", r.method, tt.status, err)
+				continue
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Method != r.method || apiErr.Message != tt.message {
+				t.Errorf("%s %d: error = %+v", r.method, tt.status, apiErr)
+			}
+
+			if got := nimbusec.IsNotFound(err); got != tt.notFound {
+				t.Errorf("%s %d %q: IsNotFound = %v, want %v", r.method, tt.status, tt.message, got, tt.notFound)
+			}
+			if got := nimbusec.IsConflict(err); got != tt.conflict {
+				t.Errorf("%s %d %q: IsConflict = %v, want %v", r.method, tt.status, tt.message, got, tt.conflict)
+			}
+			if got := nimbusec.IsUnauthorized(err); got != tt.unauthorized {
+				t.Errorf("%s %d %q: IsUnauthorized = %v, want %v", r.method, tt.status, tt.message, got, tt.unauthorized)
+			}
+			if got := nimbusec.IsQuotaExceeded(err); got != tt.quota {
+				t.Errorf("%s %d %q: IsQuotaExceeded = %v, want %v", r.method, tt.status, tt.message, got, tt.quota)
+			}
+		}
+	}
+
+	// the entity is untouched, so requests succeed again afterwards.
+	srv.Fail(0, "")
+	for _, r := range requests {
+		if err := r.do(); err != nil {
+			t.Errorf("%s: %v", r.method, err)
+		}
+	}
+}
+
+func TestAPIErrorFromServer(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com", Scheme: "https"})
+	api := srv.API()
+
+	wrongSecret, err := nimbusec.NewAPIWithOptions(srv.URL, srv.Key, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, notFound := api.GetDomain(9999)
+	_, conflict := api.CreateDomain(&nimbusec.Domain{Name: domain.Name, Scheme: "https"})
+	_, unauthorized := wrongSecret.GetDomain(domain.Id)
+
+	tests := []struct {
+		name  string
+		err   error
+		match error
+	}{
+		{"unknown domain", notFound, nimbusec.ErrNotFound},
+		{"duplicate domain", conflict, nimbusec.ErrConflict},
+		{"invalid credentials", unauthorized, nimbusec.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.match) {
+			t.Errorf("%s: error = %v, want %v", tt.name, tt.err, tt.match)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Column is a column of the CSV export.
+type Column struct {
+	Name  string               // header of the column
+	Value func(Finding) string // extracts the cell value
+}
+
+func timestamp(t nimbusec.Timestamp) string {
+	text, _ := t.MarshalText()
+	return string(text)
+}
+
+// columns are all predefined columns by name.
+var columns = map[string]func(Finding) string{
+	"domain":       func(f Finding) string { return f.Domain.Name },
+	"domainId":     func(f Finding) string { return strconv.Itoa(f.Domain.Id) },
+	"id":           func(f Finding) string { return strconv.Itoa(f.Result.Id) },
+	"status":       func(f Finding) string { return f.Result.Status.String() },
+	"event":        func(f Finding) string { return f.Result.Event },
+	"category":     func(f Finding) string { return f.Result.Category },
+	"severity":     func(f Finding) string { return f.Result.Severity.String() },
+	"probability":  func(f Finding) string { return strconv.FormatFloat(f.Result.Probability, 'f', -1, 64) },
+	"safeToDelete": func(f Finding) string { return strconv.FormatBool(f.Result.SafeToDelete) },
+	"createDate":   func(f Finding) string { return timestamp(f.Result.CreateDate) },
+	"lastDate":     func(f Finding) string { return timestamp(f.Result.LastDate) },
+	"threatname":   func(f Finding) string { return f.Result.Threatname },
+	"resource":     func(f Finding) string { return f.Result.Resource },
+	"md5":          func(f Finding) string { return f.Result.MD5 },
+	"filesize":     func(f Finding) string { return strconv.Itoa(f.Result.Filesize) },
+	"owner":        func(f Finding) string { return f.Result.Owner },
+	"group":        func(f Finding) string { return f.Result.Group },
+	"permission":   func(f Finding) string { return strconv.Itoa(f.Result.Permission) },
+	"reason":       func(f Finding) string { return f.Result.Reason },
+}
+
+// DefaultColumns are the column names used by NewCSV without columns.
+var DefaultColumns = []string{
+	"domain", "id", "status", "severity", "category", "event",
+	"threatname", "resource", "md5", "createDate", "lastDate",
+}
+
+// Columns returns the predefined columns with the given names, which are the
+// json names of the Result fields plus "domain" and "domainId".
+func Columns(names ...string) ([]Column, error) {
+	cols := make([]Column, len(names))
+	for i, name := range names {
+		value, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("export: unknown column %q", name)
+		}
+		cols[i] = Column{Name: name, Value: value}
+	}
+	return cols, nil
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	header  bool
+}
+
+// NewCSV returns a writer emitting a CSV row per finding, preceded by a
+// header row. Without columns, DefaultColumns are used. Cells starting with
+// a character that spreadsheets interpret as formula are prefixed with a
+// single quote.
+func NewCSV(w io.Writer, cols ...Column) Writer {
+	if len(cols) == 0 {
+		cols, _ = Columns(DefaultColumns...)
+	}
+	return &csvWriter{w: csv.NewWriter(w), columns: cols}
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+
+	names := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		names[i] = col.Name
+	}
+	return w.w.Write(names)
+}
+
+func (w *csvWriter) Write(f Finding) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		row[i] = escapeFormula(col.Value(f))
+	}
+	return w.w.Write(row)
+}
+
+// escapeFormula guards against formula injection by prefixing cells which
+// start with =, +, -, @, tab or carriage return with a single quote.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (w *csvWriter) Close() error {
+	// exports without findings still get a header.
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	w.w.Flush()
+	return w.w.Error()
+}
@@ -0,0 +1,55 @@
+// Package export converts nimbusec results into formats understood by other
+// tools: SARIF 2.1.0, CSV and JSON Lines. All writers stream their output, so
+// exports of any size can be written with constant memory:
+//
+//	w := export.NewSARIF(os.Stdout)
+//	err := export.Export(ctx, api, w, "status eq 1")
+package export
+
+import (
+	"context"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Finding is a result together with its owning domain.
+type Finding struct {
+	Domain nimbusec.Domain
+	Result nimbusec.Result
+}
+
+// Writer writes findings in a specific format. Close must be called to
+// complete the output; it does not close the underlying io.Writer.
+type Writer interface {
+	Write(f Finding) error
+	Close() error
+}
+
+// Export writes the results of all domains that match the given filter to w
+// and closes w afterwards, even if the export fails. Results are fetched
+// page by page.
+func Export(ctx context.Context, api *nimbusec.API, w Writer, filter string) (err error) {
+	defer func() {
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for domain, err := range api.IterDomains(ctx, nimbusec.EmptyFilter) {
+		if err != nil {
+			return err
+		}
+
+		for result, err := range api.IterResults(ctx, domain.Id, filter) {
+			if err != nil {
+				return err
+			}
+
+			if err := w.Write(Finding{Domain: domain, Result: result}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
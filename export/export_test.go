@@ -0,0 +1,165 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/export"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func newServer(t *testing.T) *nimbusectest.Server {
+	srv := nimbusectest.NewServer()
+	t.Cleanup(srv.Close)
+
+	first := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	srv.AddResult(first.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "webshell", Severity: nimbusec.SeveritySevere, Resource: "/var/www/shell.php", Threatname: "=cmd"})
+	srv.AddResult(first.Id, nimbusec.Result{Status: nimbusec.StatusAcknowledged, Category: "malware", Severity: nimbusec.SeverityMedium})
+
+	second := srv.AddDomain(nimbusec.Domain{Name: "example.org"})
+	srv.AddResult(second.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "blacklist", Severity: nimbusec.SeverityHigh})
+	return srv
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   int
+	}{
+		{"all", nimbusec.EmptyFilter, 3},
+		{"pending", "status eq 1", 2},
+		{"none", "status eq 4", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(t)
+
+			var sarif, lines, table bytes.Buffer
+			writers := map[string]export.Writer{
+				"sarif": export.NewSARIF(&sarif),
+				"jsonl": export.NewJSONLines(&lines),
+				"csv":   export.NewCSV(&table),
+			}
+			for name, w := range writers {
+				if err := export.Export(t.Context(), srv.API(), w, tt.filter); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+			}
+
+			var log struct {
+				Version string `json:"version"`
+				Runs    []struct {
+					Results []struct {
+						RuleID string `json:"ruleId"`
+						Level  string `json:"level"`
+					} `json:"results"`
+					Tool struct {
+						Driver struct {
+							Rules []struct {
+								ID string `json:"id"`
+							} `json:"rules"`
+						} `json:"driver"`
+					} `json:"tool"`
+				} `json:"runs"`
+			}
+			if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+				t.Fatalf("invalid SARIF: %v\n%s", err, sarif.String())
+			}
+			if log.Version != "2.1.0" || len(log.Runs) != 1 {
+				t.Fatalf("unexpected SARIF log: %s", sarif.String())
+			}
+			if n := len(log.Runs[0].Results); n != tt.want {
+				t.Errorf("SARIF has %d results, want %d", n, tt.want)
+			}
+			if n := len(log.Runs[0].Tool.Driver.Rules); n != tt.want {
+				t.Errorf("SARIF has %d rules, want %d", n, tt.want)
+			}
+
+			if n := strings.Count(lines.String(), "\n"); n != tt.want {
+				t.Errorf("JSON Lines has %d lines, want %d", n, tt.want)
+			}
+
+			rows, err := csv.NewReader(&table).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != tt.want+1 {
+				t.Errorf("CSV has %d rows, want %d", len(rows), tt.want+1)
+			}
+		})
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	srv := newServer(t)
+
+	cols, err := export.Columns("domain", "threatname", "severity")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := export.Export(t.Context(), srv.API(), export.NewCSV(&buf, cols...), "category eq \"webshell\""); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "domain,threatname,severity\nexample.com,'=cmd,severe\n"
+	if buf.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	if _, err := export.Columns("unknown"); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+}
+
+// failingWriter fails after the given number of findings.
+type failingWriter struct {
+	limit  int
+	writes int
+	closed bool
+}
+
+func (w *failingWriter) Write(f export.Finding) error {
+	if w.writes == w.limit {
+		return errors.New("disk full")
+	}
+	w.writes++
+	return nil
+}
+
+func (w *failingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestExportClosesWriter(t *testing.T) {
+	srv := newServer(t)
+
+	tests := []struct {
+		name  string
+		limit int
+		err   bool
+	}{
+		{"success", 10, false},
+		{"write error", 1, true},
+	}
+
+	for _, tt := range tests {
+		w := &failingWriter{limit: tt.limit}
+		err := export.Export(t.Context(), srv.API(), w, nimbusec.EmptyFilter)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+		}
+		if !w.closed {
+			t.Errorf("%s: writer was not closed", tt.name)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// jsonLine is the record written for every finding by the JSON Lines writer.
+type jsonLine struct {
+	Domain string          `json:"domain"`
+	Result nimbusec.Result `json:"result"`
+}
+
+type jsonLinesWriter struct {
+	encoder *json.Encoder
+}
+
+// NewJSONLines returns a writer emitting one JSON object per finding and line.
+func NewJSONLines(w io.Writer) Writer {
+	return &jsonLinesWriter{encoder: json.NewEncoder(w)}
+}
+
+func (w *jsonLinesWriter) Write(f Finding) error {
+	return w.encoder.Encode(jsonLine{Domain: f.Domain.Name, Result: f.Result})
+}
+
+func (w *jsonLinesWriter) Close() error {
+	return nil
+}
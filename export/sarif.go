@@ -0,0 +1,189 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations,omitempty"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifWriter struct {
+	w     *bufio.Writer
+	count int
+	rules map[string]bool
+	err   error
+}
+
+// NewSARIF returns a writer emitting a SARIF 2.1.0 log with a single run.
+// Results are written as they arrive; the rules of the tool are collected
+// and written on Close.
+func NewSARIF(w io.Writer) Writer {
+	return &sarifWriter{w: bufio.NewWriter(w), rules: map[string]bool{}}
+}
+
+// level maps the nimbusec severity to the SARIF result level.
+func level(s nimbusec.Severity) string {
+	switch {
+	case s >= nimbusec.SeveritySevere:
+		return "error"
+	case s >= nimbusec.SeverityMedium:
+		return "warning"
+	}
+	return "note"
+}
+
+// ruleID identifies the kind of finding by its category.
+func ruleID(r nimbusec.Result) string {
+	if r.Category == "" {
+		return "nimbusec"
+	}
+	return r.Category
+}
+
+// artifactURI turns the affected resource into a SARIF uri. Absolute file
+// paths become file uris, urls are kept as they are and all other resources
+// are encoded as relative references.
+func artifactURI(resource string) string {
+	if strings.HasPrefix(resource, "/") {
+		u := url.URL{Scheme: "file", Path: path.Clean(resource)}
+		return u.String()
+	}
+
+	if u, err := url.Parse(resource); err == nil && u.Scheme != "" {
+		return resource
+	}
+
+	u := url.URL{Path: resource}
+	return u.String()
+}
+
+// start writes the opening of the log up to the results of the run.
+func (w *sarifWriter) start() {
+	w.w.WriteString(`{"$schema":"` + sarifSchema + `","version":"` + sarifVersion + `","runs":[{"results":[`)
+}
+
+func (w *sarifWriter) Write(f Finding) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.count == 0 {
+		w.start()
+	} else {
+		w.w.WriteString(",")
+	}
+	w.count++
+
+	r := f.Result
+	text := r.Threatname
+	if text == "" {
+		text = r.Event
+	}
+
+	result := sarifResult{
+		RuleID:  ruleID(r),
+		Level:   level(r.Severity),
+		Message: sarifMessage{Text: text},
+		Properties: map[string]interface{}{
+			"domain":      f.Domain.Name,
+			"domainId":    f.Domain.Id,
+			"resultId":    r.Id,
+			"status":      r.Status.String(),
+			"event":       r.Event,
+			"severity":    r.Severity.String(),
+			"probability": r.Probability,
+			"md5":         r.MD5,
+		},
+	}
+
+	if r.Resource != "" {
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = artifactURI(r.Resource)
+		result.Locations = []sarifLocation{loc}
+	}
+
+	w.rules[result.RuleID] = true
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		w.err = err
+		return err
+	}
+
+	_, w.err = w.w.Write(data)
+	return w.err
+}
+
+func (w *sarifWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.count == 0 {
+		w.start()
+	}
+
+	ids := make([]string, 0, len(w.rules))
+	for id := range w.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rules := make([]sarifRule, len(ids))
+	for i, id := range ids {
+		rules[i] = sarifRule{ID: id, ShortDescription: sarifMessage{Text: "nimbusec " + id + " finding"}}
+	}
+
+	tool := map[string]interface{}{
+		"driver": map[string]interface{}{
+			"name":           "nimbusec",
+			"informationUri": "https://nimbusec.com",
+			"rules":          rules,
+		},
+	}
+
+	data, err := json.Marshal(tool)
+	if err != nil {
+		return err
+	}
+
+	w.w.WriteString(`],"tool":`)
+	w.w.Write(data)
+	w.w.WriteString("}]}\n")
+	return w.w.Flush()
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		severity nimbusec.Severity
+		want     string
+	}{
+		{0, "note"},
+		{nimbusec.SeverityMedium, "warning"},
+		{nimbusec.SeverityHigh, "warning"},
+		{nimbusec.SeveritySevere, "error"},
+	}
+
+	for _, tt := range tests {
+		if got := level(tt.severity); got != tt.want {
+			t.Errorf("level(%v) = %q, want %q", tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestArtifactURI(t *testing.T) {
+	tests := []struct {
+		resource string
+		want     string
+	}{
+		{"/var/www/index.php", "file:///var/www/index.php"},
+		{"/var/www/../www/a b.php", "file:///var/www/a%20b.php"},
+		{"/var/www/100%.php", "file:///var/www/100%25.php"},
+		{"/var/www/a#b?.php", "file:///var/www/a%23b%3F.php"},
+		{"https://example.com/a?b=c", "https://example.com/a?b=c"},
+		{"wp-content/a b.php", "wp-content/a%20b.php"},
+	}
+
+	for _, tt := range tests {
+		if got := artifactURI(tt.resource); got != tt.want {
+			t.Errorf("artifactURI(%q) = %q, want %q", tt.resource, got, tt.want)
+		}
+	}
+}
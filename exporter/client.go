@@ -0,0 +1,101 @@
+package exporter
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ClientMetrics records the latency and errors of the requests a nimbusec
+// API client sends. Use RoundTripper with nimbusec.WithTransport to
+// instrument a client.
+type ClientMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// NewClientMetrics creates the client metrics. They have to be registered
+// with a prometheus registry to be exposed.
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of requests to the nimbusec API.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+		}, []string{"method", "endpoint", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "api",
+			Name:      "request_errors_total",
+			Help:      "Failed requests to the nimbusec API by status code, or \"error\" for network errors.",
+		}, []string{"method", "endpoint", "code"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *ClientMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.duration.Describe(ch)
+	m.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *ClientMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.duration.Collect(ch)
+	m.errors.Collect(ch)
+}
+
+// RoundTripper wraps next, or http.DefaultTransport if nil, to record every
+// request attempt.
+func (m *ClientMetrics) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		elapsed := time.Since(start).Seconds()
+
+		method := req.Method
+		path := endpoint(req.URL.Path)
+
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+
+		m.duration.WithLabelValues(method, path, code).Observe(elapsed)
+		if err != nil || resp.StatusCode >= 400 {
+			m.errors.WithLabelValues(method, path, code).Inc()
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// ids matches path segments identifying an entity: numbers and long
+// hexadecimal or uuid like strings.
+var ids = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F-]{16,})$`)
+
+// endpoint replaces the ids in a request path, so the label keeps a low
+// cardinality, e.g. /v2/domain/42/result becomes /v2/domain/{id}/result.
+func endpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if ids.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
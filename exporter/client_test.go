@@ -0,0 +1,24 @@
+package exporter
+
+import "testing"
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/v2/domain", "/v2/domain"},
+		{"/v2/domain/42/result", "/v2/domain/{id}/result"},
+		{"/v2/domain/42/result/7", "/v2/domain/{id}/result/{id}"},
+		{"/v2/domain/42/config/scanner.deep/", "/v2/domain/{id}/config/scanner.deep/"},
+		{"/v2/bundle/0123456789abcdef0123", "/v2/bundle/{id}"},
+		{"/v2/bundle/123e4567-e89b-12d3-a456-426614174000", "/v2/bundle/{id}"},
+		{"/v2/bundle/basic", "/v2/bundle/basic"},
+	}
+
+	for _, tt := range tests {
+		if got := endpoint(tt.path); got != tt.want {
+			t.Errorf("endpoint(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package exporter exposes the state of a nimbusec account as prometheus
+// metrics. An Exporter periodically collects the issues, infected domains,
+// domain metadata, bundles and agent tokens and serves them as gauges;
+// ClientMetrics instruments the API client itself:
+//
+//	client := exporter.NewClientMetrics()
+//	api, err := nimbusec.NewAPIWithOptions(url, key, secret,
+//		nimbusec.WithTransport(client.RoundTripper(nil)))
+//
+//	e := exporter.New(api)
+//	prometheus.MustRegister(client, e)
+//	go e.Run(ctx, 5*time.Minute)
+package exporter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "nimbusec"
+
+// Exporter collects nimbusec metrics. It implements prometheus.Collector
+// and serves the values of the last successful Refresh.
+type Exporter struct {
+	API *nimbusec.API
+
+	// Now returns the current time for the age metrics, defaults to
+	// time.Now.
+	Now func() time.Time
+
+	mu       sync.RWMutex
+	gauges   []*prometheus.GaugeVec
+	pending  *prometheus.GaugeVec
+	infected *prometheus.GaugeVec
+	deepScan *prometheus.GaugeVec
+	fastScan *prometheus.GaugeVec
+	files    *prometheus.GaugeVec
+	bytes    *prometheus.GaugeVec
+	quota    *prometheus.GaugeVec
+	active   *prometheus.GaugeVec
+	lastCall *prometheus.GaugeVec
+
+	lastRefresh prometheus.Gauge
+	refreshTime prometheus.Histogram
+	failures    prometheus.Counter
+}
+
+// New creates an exporter collecting the metrics with api.
+func New(api *nimbusec.API) *Exporter {
+	e := &Exporter{API: api, Now: time.Now}
+
+	gauge := func(name, help string, labels ...string) *prometheus.GaugeVec {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, labels)
+		e.gauges = append(e.gauges, g)
+		return g
+	}
+
+	e.pending = gauge("results_pending", "Pending results by domain, severity and category.", "domain", "severity", "category")
+	e.infected = gauge("domain_infected", "Whether a domain has pending results (1) or not (0).", "domain")
+	e.deepScan = gauge("last_deep_scan_age_seconds", "Seconds since the last deep scan of a domain.", "domain")
+	e.fastScan = gauge("last_fast_scan_age_seconds", "Seconds since the last fast scan of a domain.", "domain")
+	e.files = gauge("scanned_files", "Files and URLs downloaded by the last deep scan of a domain.", "domain")
+	e.bytes = gauge("scanned_bytes", "Bytes downloaded by the last deep scan of a domain.", "domain")
+	e.quota = gauge("bundle_contingent", "Domains that can be assigned to a bundle.", "bundle", "name")
+	e.active = gauge("bundle_active", "Domains assigned to a bundle.", "bundle", "name")
+	e.lastCall = gauge("agent_last_call_age_seconds", "Seconds since an agent last used its token.", "token", "name")
+
+	e.lastRefresh = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "last_refresh_timestamp_seconds",
+		Help:      "Time of the last successful refresh.",
+	})
+	e.refreshTime = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "refresh_duration_seconds",
+		Help:      "Duration of a refresh of all metrics.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+	e.failures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "refresh_errors_total",
+		Help:      "Failed refreshes.",
+	})
+
+	return e
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, g := range e.gauges {
+		g.Describe(ch)
+	}
+	e.lastRefresh.Describe(ch)
+	e.refreshTime.Describe(ch)
+	e.failures.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, g := range e.gauges {
+		g.Collect(ch)
+	}
+	e.lastRefresh.Collect(ch)
+	e.refreshTime.Collect(ch)
+	e.failures.Collect(ch)
+}
+
+// Run refreshes the metrics every interval until ctx is done. Failed
+// refreshes are counted and keep the previous values.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.Refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshot holds the data of a refresh.
+type snapshot struct {
+	issues   []nimbusec.DomainIssues
+	domains  []nimbusec.Domain
+	infected map[int]bool
+	metadata map[int]*nimbusec.DomainMetadata
+	bundles  []nimbusec.Bundle
+	tokens   []nimbusec.Token
+}
+
+// Refresh fetches the current data and replaces all gauge values.
+func (e *Exporter) Refresh(ctx context.Context) error {
+	start := time.Now()
+	s, err := e.fetch(ctx)
+	if err != nil {
+		e.failures.Inc()
+		return err
+	}
+	e.refreshTime.Observe(time.Since(start).Seconds())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, g := range e.gauges {
+		g.Reset()
+	}
+	e.update(s)
+	e.lastRefresh.SetToCurrentTime()
+	return nil
+}
+
+func (e *Exporter) fetch(ctx context.Context) (*snapshot, error) {
+	s := &snapshot{
+		infected: map[int]bool{},
+		metadata: map[int]*nimbusec.DomainMetadata{},
+	}
+
+	var err error
+	if s.issues, err = e.API.GetIssuesContext(ctx); err != nil {
+		return nil, err
+	}
+	if s.domains, err = e.API.FindDomainsContext(ctx, nimbusec.EmptyFilter); err != nil {
+		return nil, err
+	}
+
+	infected, err := e.API.FindInfectedContext(ctx, nimbusec.EmptyFilter)
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range infected {
+		s.infected[domain.Id] = true
+	}
+
+	for _, domain := range s.domains {
+		metadata, err := e.API.GetDomainMetadataContext(ctx, domain.Id)
+		if nimbusec.IsNotFound(err) {
+			// domain was deleted in the meantime or has not been scanned yet.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.metadata[domain.Id] = metadata
+	}
+
+	if s.bundles, err = e.API.FindBundlesContext(ctx, nimbusec.EmptyFilter); err != nil {
+		return nil, err
+	}
+	if s.tokens, err = e.API.FindTokensContext(ctx, nimbusec.EmptyFilter); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (e *Exporter) update(s *snapshot) {
+	now := e.Now()
+	age := func(t nimbusec.Timestamp) (float64, bool) {
+		if t.IsZero() {
+			return 0, false
+		}
+		return now.Sub(t.Time).Seconds(), true
+	}
+
+	names := make(map[int]string, len(s.domains))
+	for _, domain := range s.domains {
+		names[domain.Id] = domain.Name
+
+		infected := 0.0
+		if s.infected[domain.Id] {
+			infected = 1
+		}
+		e.infected.WithLabelValues(domain.Name).Set(infected)
+
+		metadata, ok := s.metadata[domain.Id]
+		if !ok {
+			continue
+		}
+		if v, ok := age(metadata.LastDeepScan); ok {
+			e.deepScan.WithLabelValues(domain.Name).Set(v)
+		}
+		if v, ok := age(metadata.LastFastScan); ok {
+			e.fastScan.WithLabelValues(domain.Name).Set(v)
+		}
+		e.files.WithLabelValues(domain.Name).Set(float64(metadata.Files))
+		e.bytes.WithLabelValues(domain.Name).Set(float64(metadata.Size))
+	}
+
+	for _, issue := range s.issues {
+		name, ok := names[issue.DomainID]
+		if !ok {
+			name = strconv.Itoa(issue.DomainID)
+		}
+		e.pending.WithLabelValues(name, issue.Severity.String(), issue.Category).Add(float64(issue.Issues))
+	}
+
+	for _, bundle := range s.bundles {
+		e.quota.WithLabelValues(bundle.Id, bundle.Name).Set(float64(bundle.Contingent))
+		e.active.WithLabelValues(bundle.Id, bundle.Name).Set(float64(bundle.Active))
+	}
+
+	for _, token := range s.tokens {
+		if v, ok := age(token.LastCall); ok {
+			e.lastCall.WithLabelValues(strconv.Itoa(token.Id), token.Name).Set(v)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package exporter_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/exporter"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gather collects the metrics of c by name and label values, joined in the
+// order of the sorted label names.
+func gather(t *testing.T, c prometheus.Collector) map[string]map[string]*dto.Metric {
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := map[string]map[string]*dto.Metric{}
+	for _, family := range families {
+		byLabels := map[string]*dto.Metric{}
+		for _, m := range family.GetMetric() {
+			key := ""
+			for i, label := range m.GetLabel() {
+				if i > 0 {
+					key += ","
+				}
+				key += label.GetValue()
+			}
+			byLabels[key] = m
+		}
+		metrics[family.GetName()] = byLabels
+	}
+	return metrics
+}
+
+func TestExporterRefresh(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	infected := srv.AddDomain(nimbusec.Domain{Name: "infected.com"})
+	clean := srv.AddDomain(nimbusec.Domain{Name: "clean.com"})
+	srv.AddResult(infected.Id, nimbusec.Result{Status: nimbusec.StatusPending})
+	srv.AddIssue(nimbusec.DomainIssues{DomainID: infected.Id, Category: "webshell", Severity: nimbusec.SeveritySevere, Issues: 2})
+	srv.SetMetadata(clean.Id, nimbusec.DomainMetadata{
+		LastDeepScan: nimbusec.Timestamp{Time: now.Add(-time.Hour)},
+		Files:        10,
+		Size:         2048,
+	})
+	srv.AddBundle(nimbusec.Bundle{Id: "b1", Name: "basic", Contingent: 5, Active: 2})
+	srv.AddToken(nimbusec.Token{Id: 9, Name: "agent", LastCall: nimbusec.Timestamp{Time: now.Add(-time.Minute)}})
+
+	e := exporter.New(srv.API())
+	e.Now = func() time.Time { return now }
+	if err := e.Refresh(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := gather(t, e)
+	tests := []struct {
+		name   string
+		labels string
+		want   float64
+	}{
+		{"nimbusec_results_pending", "webshell,infected.com,severe", 2},
+		{"nimbusec_domain_infected", "infected.com", 1},
+		{"nimbusec_domain_infected", "clean.com", 0},
+		{"nimbusec_last_deep_scan_age_seconds", "clean.com", 3600},
+		{"nimbusec_scanned_files", "clean.com", 10},
+		{"nimbusec_scanned_bytes", "clean.com", 2048},
+		{"nimbusec_bundle_contingent", "b1,basic", 5},
+		{"nimbusec_bundle_active", "b1,basic", 2},
+		{"nimbusec_agent_last_call_age_seconds", "agent,9", 60},
+	}
+
+	for _, tt := range tests {
+		m, ok := metrics[tt.name][tt.labels]
+		if !ok {
+			t.Errorf("%s{%s} is missing", tt.name, tt.labels)
+			continue
+		}
+		if got := m.GetGauge().GetValue(); got != tt.want {
+			t.Errorf("%s{%s} = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+
+	if _, ok := metrics["nimbusec_last_fast_scan_age_seconds"]["clean.com"]; ok {
+		t.Error("fast scan age is set without a fast scan")
+	}
+}
+
+func TestExporterRefreshFailure(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	srv.Close()
+
+	e := exporter.New(srv.API())
+	if err := e.Refresh(t.Context()); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	m := gather(t, e)["nimbusec_exporter_refresh_errors_total"][""]
+	if m.GetCounter().GetValue() != 1 {
+		t.Errorf("refresh errors = %v, want 1", m.GetCounter().GetValue())
+	}
+}
+
+func TestClientMetrics(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	client := exporter.NewClientMetrics()
+	api := srv.API(nimbusec.WithTransport(client.RoundTripper(http.DefaultTransport)))
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	if _, err := api.GetDomain(domain.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.GetDomain(domain.Id + 100); err == nil {
+		t.Fatal("expected an error for an unknown domain")
+	}
+
+	metrics := gather(t, client)
+	if m := metrics["nimbusec_api_request_duration_seconds"]["200,/v2/domain/{id},GET"]; m.GetHistogram().GetSampleCount() != 1 {
+		t.Errorf("successful requests = %v, want 1", m.GetHistogram().GetSampleCount())
+	}
+	if m := metrics["nimbusec_api_request_errors_total"]["404,/v2/domain/{id},GET"]; m.GetCounter().GetValue() != 1 {
+		t.Errorf("failed requests = %v, want 1", m.GetCounter().GetValue())
+	}
+}
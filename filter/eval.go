@@ -0,0 +1,410 @@
+package filter
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Match reports whether v matches the expression. v must be a struct or a
+// pointer to a struct; fields are resolved by their json name, nested fields
+// are separated by a dot. Slice fields match if any element matches. The zero
+// Expr matches every value.
+func (e Expr) Match(v interface{}) (bool, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return false, fmt.Errorf("filter: can not match %T, expected a struct", v)
+	}
+
+	return e.match(rv)
+}
+
+// ValidateFields checks that the expression is valid and that all referenced
+// fields exist in the struct type of v.
+func (e Expr) ValidateFields(v interface{}) error {
+	if e.Op == "" {
+		return nil
+	}
+
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("filter: can not validate fields of %T, expected a struct", v)
+	}
+
+	return e.validateFields(t)
+}
+
+func (e Expr) validateFields(t reflect.Type) error {
+	if !e.Op.IsComparison() {
+		for _, arg := range e.Args {
+			if err := arg.validateFields(t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, ok := lookupType(t, e.Field); !ok {
+		return fmt.Errorf("filter: unknown field %q for %s", e.Field, t)
+	}
+	return nil
+}
+
+// Select returns all items matching the expression.
+func Select[T any](e Expr, items []T) ([]T, error) {
+	dst := make([]T, 0)
+	for _, item := range items {
+		ok, err := e.Match(item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			dst = append(dst, item)
+		}
+	}
+	return dst, nil
+}
+
+func (e Expr) match(rv reflect.Value) (bool, error) {
+	switch e.Op {
+	case "":
+		return true, nil
+
+	case OpAnd:
+		for _, arg := range e.Args {
+			ok, err := arg.match(rv)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case OpOr:
+		for _, arg := range e.Args {
+			ok, err := arg.match(rv)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case OpNot:
+		if len(e.Args) != 1 {
+			return false, fmt.Errorf("filter: not requires exactly one operand")
+		}
+		ok, err := e.Args[0].match(rv)
+		return !ok, err
+	}
+
+	if !e.Op.IsComparison() {
+		return false, fmt.Errorf("filter: unknown operator %q", e.Op)
+	}
+
+	field, ok := lookupValue(rv, e.Field)
+	if !ok {
+		return false, fmt.Errorf("filter: unknown field %q for %s", e.Field, rv.Type())
+	}
+
+	// slices match if any of their elements match.
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < field.Len(); i++ {
+			ok, err := compareValue(e.Op, field.Index(i), e.Value)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	return compareValue(e.Op, field, e.Value)
+}
+
+// unixNanoer is implemented by time.Time and types embedding it.
+type unixNanoer interface {
+	UnixNano() int64
+}
+
+var unixNanoerType = reflect.TypeOf((*unixNanoer)(nil)).Elem()
+
+// scalar converts a field into string, int64, float64 or bool. Timestamps are
+// converted into milliseconds, as used by the nimbusec API.
+func scalar(v reflect.Value) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return scalar(v.Elem())
+	}
+
+	if v.Type().Implements(unixNanoerType) {
+		return v.Interface().(unixNanoer).UnixNano() / 1e6, nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+
+	return nil, fmt.Errorf("filter: can not compare field of type %s", v.Type())
+}
+
+func compareValue(op Op, field reflect.Value, value interface{}) (bool, error) {
+	actual, err := scalar(field)
+	if err != nil {
+		return false, err
+	}
+
+	value = normalize(value)
+	if actual == nil {
+		return op == OpNe, nil
+	}
+
+	if name, ok := value.(string); ok {
+		if _, ok := actual.(string); !ok {
+			actual, value, err = byName(field, actual, name)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if op == OpLike {
+		s, ok1 := actual.(string)
+		pattern, ok2 := value.(string)
+		if !ok1 || !ok2 {
+			return false, fmt.Errorf("filter: like requires string operands")
+		}
+		re, err := likePattern(pattern)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(s), nil
+	}
+
+	cmp, err := compareScalars(actual, value)
+	if err != nil {
+		return false, err
+	}
+
+	switch op {
+	case OpEq:
+		return cmp == 0, nil
+	case OpNe:
+		return cmp != 0, nil
+	case OpGt:
+		return cmp > 0, nil
+	case OpGe:
+		return cmp >= 0, nil
+	case OpLt:
+		return cmp < 0, nil
+	case OpLe:
+		return cmp <= 0, nil
+	}
+
+	return false, fmt.Errorf("filter: unknown operator %q", op)
+}
+
+// compareScalars returns -1, 0 or 1 if a is less, equal or greater than b.
+func compareScalars(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !x:
+				return -1, nil
+			}
+			return 1, nil
+		}
+
+	case int64, float64:
+		fx, _ := toFloat(x)
+		if fy, ok := toFloat(b); ok {
+			switch {
+			case fx < fy:
+				return -1, nil
+			case fx > fy:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	return 0, fmt.Errorf("filter: can not compare %T with %T", a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+// byName resolves a string compared with a non string field, like an
+// enumeration compared by name (status eq "pending") or a timestamp compared
+// with a formatted date. If the field type implements UnmarshalText, the
+// parsed value is compared. Otherwise, if the field is a fmt.Stringer, its
+// string form is compared case insensitively with the name.
+func byName(field reflect.Value, actual interface{}, name string) (interface{}, interface{}, error) {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		field = field.Elem()
+	}
+
+	parsed := reflect.New(field.Type())
+	if u, ok := parsed.Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(name)); err != nil {
+			return nil, nil, err
+		}
+		value, err := scalar(parsed.Elem())
+		return actual, value, err
+	}
+
+	if s, ok := field.Interface().(fmt.Stringer); ok {
+		return strings.ToLower(s.String()), strings.ToLower(name), nil
+	}
+
+	return actual, name, nil
+}
+
+// maxLikePatterns limits the number of cached like patterns.
+const maxLikePatterns = 1024
+
+// likePatterns caches the compiled like patterns, so that evaluating an
+// expression against many values compiles every pattern only once.
+var (
+	likePatterns     sync.Map // map[string]*regexp.Regexp
+	likePatternCount atomic.Int32
+)
+
+// likePattern converts a like pattern into a regular expression, where %
+// matches any sequence of characters and _ a single character.
+func likePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := likePatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	var buf strings.Builder
+	buf.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			buf.WriteString(".*")
+		case '_':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString("$")
+
+	re, err := regexp.Compile(buf.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if n := likePatternCount.Add(1); n <= maxLikePatterns {
+		likePatterns.Store(pattern, re)
+	}
+	return re, nil
+}
+
+// jsonName returns the json name of a struct field, or "" if the field is
+// not serialized.
+func jsonName(f reflect.StructField) string {
+	if f.PkgPath != "" && !f.Anonymous {
+		return ""
+	}
+
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// fieldIndex finds the field with the given json name in the struct type.
+func fieldIndex(t reflect.Type, name string) ([]int, bool) {
+	var fallback []int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		n := jsonName(f)
+		switch {
+		case n == name:
+			return f.Index, true
+		case fallback == nil && strings.EqualFold(n, name):
+			fallback = f.Index
+		}
+	}
+	return fallback, fallback != nil
+}
+
+func lookupType(t reflect.Type, path string) (reflect.Type, bool) {
+	for _, name := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t.Implements(unixNanoerType) {
+			return nil, false
+		}
+
+		index, ok := fieldIndex(t, name)
+		if !ok {
+			return nil, false
+		}
+		t = t.FieldByIndex(index).Type
+	}
+	return t, true
+}
+
+func lookupValue(v reflect.Value, path string) (reflect.Value, bool) {
+	if _, ok := lookupType(v.Type(), path); !ok {
+		return reflect.Value{}, false
+	}
+
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v = reflect.Zero(v.Type().Elem())
+				continue
+			}
+			v = v.Elem()
+		}
+
+		index, _ := fieldIndex(v.Type(), name)
+		v = v.FieldByIndex(index)
+	}
+	return v, true
+}
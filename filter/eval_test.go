@@ -0,0 +1,129 @@
+package filter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/filter"
+)
+
+func TestMatch(t *testing.T) {
+	result := nimbusec.Result{
+		Id:          7,
+		Status:      nimbusec.StatusPending,
+		Severity:    nimbusec.Severity(3),
+		Probability: 0.8,
+		Threatname:  "JS.Injected",
+		Resource:    "/var/www/index.php",
+		LastDate:    nimbusec.TimestampFromMillis(1704164645006),
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{``, true},
+		{`status eq 1`, true},
+		{`status eq 2`, false},
+		{`status eq "pending"`, true},
+		{`status eq "PENDING"`, true},
+		{`status ne "acknowledged"`, true},
+		{`severity ge 2 and status eq 1`, true},
+		{`severity ge 2 and status eq 2`, false},
+		{`severity lt 2 or probability gt 0.5`, true},
+		{`not probability gt 0.5`, false},
+		{`threatname eq "JS.Injected"`, true},
+		{`resource like "%.php"`, true},
+		{`resource like "/var/www/index.ph_"`, true},
+		{`resource like "%.js"`, false},
+		{`lastDate eq 1704164645006`, true},
+		{`lastDate gt "2024-01-01T00:00:00Z"`, true},
+		{`lastDate lt "2024-01-01T00:00:00Z"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got, err := filter.MustParse(tt.filter).Match(result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchNilPointers(t *testing.T) {
+	type record struct {
+		Seen  *time.Time          `json:"seen"`
+		Last  *nimbusec.Timestamp `json:"last"`
+		Count *int                `json:"count"`
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`seen gt 0`, false},
+		{`seen ne 0`, true},
+		{`last eq 0`, false},
+		{`count eq 1`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got, err := filter.MustParse(tt.filter).Match(&record{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []string{
+		`unknown eq 1`,
+		`status eq "unknown"`,
+		`threatname gt 1`,
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if _, err := filter.MustParse(input).Match(nimbusec.Result{}); err == nil {
+				t.Errorf("Match(%q) succeeded, want error", input)
+			}
+		})
+	}
+
+	if _, err := filter.Eq("a", 1).Match(42); err == nil {
+		t.Error("Match(42) succeeded, want error")
+	}
+}
+
+func TestSelectAndValidateFields(t *testing.T) {
+	domains := []nimbusec.Domain{
+		{Id: 1, Name: "a.example.com", Scheme: "https"},
+		{Id: 2, Name: "b.example.com", Scheme: "http"},
+		{Id: 3, Name: "c.example.org", Scheme: "https"},
+	}
+
+	got, err := filter.Select(filter.MustParse(`scheme eq "https" and name like "%.com"`), domains)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Id != 1 {
+		t.Errorf("Select = %v, want domain 1", got)
+	}
+
+	if err := filter.MustParse(`name eq "x" and fastScans eq "y"`).ValidateFields(nimbusec.Domain{}); err != nil {
+		t.Errorf("ValidateFields = %v, want nil", err)
+	}
+	if err := filter.MustParse(`nope eq 1`).ValidateFields(&nimbusec.Domain{}); err == nil {
+		t.Error("ValidateFields succeeded for unknown field")
+	}
+}
@@ -0,0 +1,283 @@
+// Package filter builds expressions for the `q` parameter of the nimbusec API.
+//
+// A filter consists of comparisons of a field with a value, which can be
+// combined with the logical operators and, or and not:
+//
+//	f := filter.Eq("name", "example.com").And(filter.Ge("severity", 2))
+//	domains, err := api.FindDomains(f.String())
+//
+// String values are always quoted and escaped, so user input can be passed
+// safely as value.
+//
+// Parse turns a filter string back into an Expr, which can be evaluated
+// locally against values of the nimbusec types with Match and Select.
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Op is an operator of the nimbusec filter language.
+type Op string
+
+const (
+	OpEq   Op = "eq"   // equal
+	OpNe   Op = "ne"   // not equal
+	OpGt   Op = "gt"   // greater than
+	OpGe   Op = "ge"   // greater than or equal
+	OpLt   Op = "lt"   // less than
+	OpLe   Op = "le"   // less than or equal
+	OpLike Op = "like" // string match with % as wildcard
+
+	OpAnd Op = "and" // all arguments must match
+	OpOr  Op = "or"  // any argument must match
+	OpNot Op = "not" // the argument must not match
+)
+
+// IsComparison reports whether op compares a field with a value.
+func (op Op) IsComparison() bool {
+	switch op {
+	case OpEq, OpNe, OpGt, OpGe, OpLt, OpLe, OpLike:
+		return true
+	}
+	return false
+}
+
+// Expr is a node of a filter expression. Comparisons use Field and Value,
+// logical operators use Args.
+type Expr struct {
+	Op    Op
+	Field string      // name of the compared field
+	Value interface{} // string, int64, float64 or bool
+	Args  []Expr      // operands of and, or and not
+}
+
+// Eq matches if field equals value.
+func Eq(field string, value interface{}) Expr { return compare(OpEq, field, value) }
+
+// Ne matches if field does not equal value.
+func Ne(field string, value interface{}) Expr { return compare(OpNe, field, value) }
+
+// Gt matches if field is greater than value.
+func Gt(field string, value interface{}) Expr { return compare(OpGt, field, value) }
+
+// Ge matches if field is greater than or equal to value.
+func Ge(field string, value interface{}) Expr { return compare(OpGe, field, value) }
+
+// Lt matches if field is less than value.
+func Lt(field string, value interface{}) Expr { return compare(OpLt, field, value) }
+
+// Le matches if field is less than or equal to value.
+func Le(field string, value interface{}) Expr { return compare(OpLe, field, value) }
+
+// Like matches if field matches pattern, where % matches any sequence of
+// characters.
+func Like(field string, pattern string) Expr { return compare(OpLike, field, pattern) }
+
+// And matches if all expressions match.
+func And(exprs ...Expr) Expr { return Expr{Op: OpAnd, Args: exprs} }
+
+// Or matches if any expression matches.
+func Or(exprs ...Expr) Expr { return Expr{Op: OpOr, Args: exprs} }
+
+// Not matches if expr does not match.
+func Not(expr Expr) Expr { return Expr{Op: OpNot, Args: []Expr{expr}} }
+
+// And combines e and the given expressions with a logical and.
+func (e Expr) And(exprs ...Expr) Expr {
+	return And(append([]Expr{e}, exprs...)...)
+}
+
+// Or combines e and the given expressions with a logical or.
+func (e Expr) Or(exprs ...Expr) Expr {
+	return Or(append([]Expr{e}, exprs...)...)
+}
+
+// Not negates e.
+func (e Expr) Not() Expr {
+	return Not(e)
+}
+
+func compare(op Op, field string, value interface{}) Expr {
+	return Expr{Op: op, Field: field, Value: normalize(value)}
+}
+
+// unixMillier is implemented by time.Time and all types embedding it.
+type unixMillier interface {
+	UnixMilli() int64
+}
+
+// normalize converts value into one of the types supported by Expr.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string, int64, float64, bool:
+		return v
+	case unixMillier:
+		// timestamps are transmitted in milliseconds. This includes
+		// time.Time and types embedding it, like nimbusec.Timestamp.
+		return v.UnixMilli()
+	}
+
+	// named types like enumerations are compared by their underlying value,
+	// even if they implement fmt.Stringer.
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+
+	if v, ok := value.(fmt.Stringer); ok {
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// fieldPattern matches valid field names.
+var fieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// Validate checks that all operators and field names of the expression are
+// valid. Field names are not quoted, so they must never contain user input.
+func (e Expr) Validate() error {
+	switch {
+	case e.Op.IsComparison():
+		if !fieldPattern.MatchString(e.Field) {
+			return fmt.Errorf("filter: invalid field name %q", e.Field)
+		}
+		if e.Op == OpLike {
+			if _, ok := e.Value.(string); !ok {
+				return fmt.Errorf("filter: like requires a string value for field %q", e.Field)
+			}
+		}
+		return nil
+
+	case e.Op == OpAnd || e.Op == OpOr:
+		if len(e.Args) == 0 {
+			return fmt.Errorf("filter: %s without operands", e.Op)
+		}
+
+	case e.Op == OpNot:
+		if len(e.Args) != 1 {
+			return fmt.Errorf("filter: not requires exactly one operand")
+		}
+
+	default:
+		return fmt.Errorf("filter: unknown operator %q", e.Op)
+	}
+
+	for _, arg := range e.Args {
+		if err := arg.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// String renders the expression in the nimbusec filter language. The zero
+// Expr renders as empty filter.
+func (e Expr) String() string {
+	if e.Op == "" {
+		return ""
+	}
+
+	var buf strings.Builder
+	e.write(&buf)
+	return buf.String()
+}
+
+// precedence returns the binding strength of the expression, used to decide
+// whether operands need parentheses.
+func (e Expr) precedence() int {
+	switch e.Op {
+	case OpOr:
+		return 1
+	case OpAnd:
+		return 2
+	case OpNot:
+		return 3
+	}
+	return 4
+}
+
+func (e Expr) write(buf *strings.Builder) {
+	switch e.Op {
+	case OpAnd, OpOr:
+		// a single operand needs no operator at all
+		if len(e.Args) == 1 {
+			e.Args[0].write(buf)
+			return
+		}
+
+		for i, arg := range e.Args {
+			if i > 0 {
+				buf.WriteString(" " + string(e.Op) + " ")
+			}
+			writeOperand(buf, arg, e.precedence())
+		}
+
+	case OpNot:
+		buf.WriteString("not ")
+		if len(e.Args) > 0 {
+			writeOperand(buf, e.Args[0], e.precedence())
+		}
+
+	default:
+		buf.WriteString(e.Field)
+		buf.WriteString(" " + string(e.Op) + " ")
+		buf.WriteString(FormatValue(e.Value))
+	}
+}
+
+func writeOperand(buf *strings.Builder, arg Expr, parent int) {
+	if arg.precedence() >= parent {
+		arg.write(buf)
+		return
+	}
+
+	buf.WriteString("(")
+	arg.write(buf)
+	buf.WriteString(")")
+}
+
+// FormatValue renders a single value as literal of the filter language.
+func FormatValue(value interface{}) string {
+	switch v := normalize(value).(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return Quote(v)
+	}
+
+	return Quote(fmt.Sprint(value))
+}
+
+// Quote returns s as a double quoted string literal, escaping backslashes and
+// double quotes.
+func Quote(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
@@ -0,0 +1,69 @@
+package filter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/filter"
+)
+
+func TestExprString(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC)
+
+	tests := []struct {
+		name string
+		expr filter.Expr
+		want string
+	}{
+		{"zero", filter.Expr{}, ""},
+		{"string", filter.Eq("name", "example.com"), `name eq "example.com"`},
+		{"quotes", filter.Eq("name", `a"b\c`), `name eq "a\"b\\c"`},
+		{"int", filter.Ge("severity", 2), `severity ge 2`},
+		{"float", filter.Lt("probability", 0.5), `probability lt 0.5`},
+		{"bool", filter.Ne("latest", false), `latest ne false`},
+		{"enum", filter.Eq("status", nimbusec.StatusPending), `status eq 1`},
+		{"time", filter.Gt("lastDate", ts), `lastDate gt 1704164645006`},
+		{"timestamp", filter.Gt("lastDate", nimbusec.NewTimestamp(ts)), `lastDate gt 1704164645006`},
+		{"like", filter.Like("resource", "%.php"), `resource like "%.php"`},
+		{"and", filter.Eq("a", 1).And(filter.Eq("b", 2)), `a eq 1 and b eq 2`},
+		{"single operand", filter.And(filter.Eq("a", 1)), `a eq 1`},
+		{"or in and", filter.Eq("a", 1).And(filter.Eq("b", 2).Or(filter.Eq("c", 3))), `a eq 1 and (b eq 2 or c eq 3)`},
+		{"and in or", filter.Eq("a", 1).Or(filter.Eq("b", 2).And(filter.Eq("c", 3))), `a eq 1 or b eq 2 and c eq 3`},
+		{"not", filter.Not(filter.Eq("a", 1).Or(filter.Eq("b", 2))), `not (a eq 1 or b eq 2)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.expr.String(); got != tt.want {
+				t.Errorf("String() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  filter.Expr
+		valid bool
+	}{
+		{"comparison", filter.Eq("name", "x"), true},
+		{"nested field", filter.Eq("domain.name", "x"), true},
+		{"injected field", filter.Eq(`name eq "x" or name`, "y"), false},
+		{"like without string", filter.Expr{Op: filter.OpLike, Field: "name", Value: int64(1)}, false},
+		{"empty and", filter.And(), false},
+		{"not with two operands", filter.Expr{Op: filter.OpNot, Args: []filter.Expr{filter.Eq("a", 1), filter.Eq("b", 2)}}, false},
+		{"unknown operator", filter.Expr{Op: "xor"}, false},
+		{"invalid operand", filter.Eq("a", 1).And(filter.Eq("", 1)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.expr.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
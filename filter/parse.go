@@ -0,0 +1,293 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError is returned by Parse for malformed filter expressions.
+type SyntaxError struct {
+	Offset int    // byte offset of the error in the input
+	Msg    string // description of the error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: syntax error at offset %d: %s", e.Offset, e.Msg)
+}
+
+// Parse parses a filter expression of the nimbusec filter language:
+//
+//	expr       = or
+//	or         = and { "or" and }
+//	and        = not { "and" not }
+//	not        = "not" not | primary
+//	primary    = "(" expr ")" | comparison
+//	comparison = field op value
+//	op         = "eq" | "ne" | "gt" | "ge" | "lt" | "le" | "like"
+//	value      = string | number | "true" | "false"
+//
+// The empty filter parses to the zero Expr, which matches everything.
+func Parse(s string) (Expr, error) {
+	p := &parser{lex: lexer{input: s}}
+	p.next()
+
+	if p.tok.kind == tokEOF {
+		return Expr{}, nil
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return Expr{}, err
+	}
+
+	if p.tok.kind != tokEOF {
+		return Expr{}, p.errorf("unexpected %s", p.tok)
+	}
+
+	return expr, nil
+}
+
+// MustParse is like Parse but panics if the expression can not be parsed.
+func MustParse(s string) Expr {
+	expr, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind  tokenKind
+	text  string // raw identifier or number, unquoted string
+	start int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{kind: tokEOF, start: start}, nil
+	}
+
+	c := l.input[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", start: start}, nil
+
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", start: start}, nil
+
+	case c == '"':
+		var buf strings.Builder
+		l.pos++
+		for l.pos < len(l.input) {
+			c := l.input[l.pos]
+			l.pos++
+			switch c {
+			case '"':
+				return token{kind: tokString, text: buf.String(), start: start}, nil
+			case '\\':
+				if l.pos >= len(l.input) {
+					break
+				}
+				buf.WriteByte(l.input[l.pos])
+				l.pos++
+			default:
+				buf.WriteByte(c)
+			}
+		}
+		return token{}, &SyntaxError{Offset: start, Msg: "unterminated string"}
+
+	case c == '-' || c == '+' || c == '.' || ('0' <= c && c <= '9'):
+		l.pos++
+		for l.pos < len(l.input) && strings.IndexByte("0123456789.eE+-", l.input[l.pos]) >= 0 {
+			l.pos++
+		}
+		return token{kind: tokNumber, text: l.input[start:l.pos], start: start}, nil
+
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for l.pos < len(l.input) {
+			c := l.input[l.pos]
+			if c != '_' && c != '.' && !unicode.IsLetter(rune(c)) && !unicode.IsDigit(rune(c)) {
+				break
+			}
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.input[start:l.pos], start: start}, nil
+	}
+
+	return token{}, &SyntaxError{Offset: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+}
+
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+
+	p.tok, p.err = p.lex.next()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	return &SyntaxError{Offset: p.tok.start, Msg: fmt.Sprintf(format, args...)}
+}
+
+// keyword reports whether the current token is the given keyword.
+func (p *parser) keyword(word string) bool {
+	return p.err == nil && p.tok.kind == tokIdent && strings.EqualFold(p.tok.text, word)
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	return p.parseBinary(OpOr, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	return p.parseBinary(OpAnd, p.parseNot)
+}
+
+func (p *parser) parseBinary(op Op, operand func() (Expr, error)) (Expr, error) {
+	first, err := operand()
+	if err != nil {
+		return Expr{}, err
+	}
+
+	args := []Expr{first}
+	for p.keyword(string(op)) {
+		p.next()
+		arg, err := operand()
+		if err != nil {
+			return Expr{}, err
+		}
+		args = append(args, arg)
+	}
+
+	if len(args) == 1 {
+		return first, nil
+	}
+	return Expr{Op: op, Args: args}, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.keyword(string(OpNot)) {
+		p.next()
+		arg, err := p.parseNot()
+		if err != nil {
+			return Expr{}, err
+		}
+		return Not(arg), nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	if p.err != nil {
+		return Expr{}, p.err
+	}
+
+	if p.tok.kind == tokLParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return Expr{}, err
+		}
+		if p.err != nil || p.tok.kind != tokRParen {
+			return Expr{}, p.errorf("expected \")\", got %s", p.tok)
+		}
+		p.next()
+		return expr, nil
+	}
+
+	if p.tok.kind != tokIdent {
+		return Expr{}, p.errorf("expected field name, got %s", p.tok)
+	}
+	field := p.tok.text
+	p.next()
+
+	if p.err != nil || p.tok.kind != tokIdent || !Op(strings.ToLower(p.tok.text)).IsComparison() {
+		return Expr{}, p.errorf("expected operator after %q, got %s", field, p.tok)
+	}
+	op := Op(strings.ToLower(p.tok.text))
+	p.next()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return Expr{}, err
+	}
+
+	return Expr{Op: op, Field: field, Value: value}, nil
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	tok := p.tok
+	switch {
+	case tok.kind == tokString:
+		p.next()
+		return tok.text, nil
+
+	case tok.kind == tokNumber:
+		p.next()
+		if i, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			return f, nil
+		}
+		return nil, &SyntaxError{Offset: tok.start, Msg: fmt.Sprintf("invalid number %q", tok.text)}
+
+	case p.keyword("true"):
+		p.next()
+		return true, nil
+
+	case p.keyword("false"):
+		p.next()
+		return false, nil
+	}
+
+	return nil, p.errorf("expected value, got %s", tok)
+}
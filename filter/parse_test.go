@@ -0,0 +1,78 @@
+package filter_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cumulodev/nimbusec/filter"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // canonical rendering of the parsed expression
+	}{
+		{``, ``},
+		{`name eq "example.com"`, `name eq "example.com"`},
+		{`name eq "a\"b"`, `name eq "a\"b"`},
+		{`severity ge 2`, `severity ge 2`},
+		{`probability gt 0.75`, `probability gt 0.75`},
+		{`severity lt -1`, `severity lt -1`},
+		{`latest eq true`, `latest eq true`},
+		{`resource like "%.php"`, `resource like "%.php"`},
+		{`a eq 1 and b eq 2 or c eq 3`, `a eq 1 and b eq 2 or c eq 3`},
+		{`a eq 1 and (b eq 2 or c eq 3)`, `a eq 1 and (b eq 2 or c eq 3)`},
+		{`not a eq 1`, `not a eq 1`},
+		{`not (a eq 1 or b eq 2)`, `not (a eq 1 or b eq 2)`},
+		{`((a eq 1))`, `a eq 1`},
+		{`A EQ 1 AND b eq 2`, `A eq 1 and b eq 2`},
+		{`domain.name eq "x"`, `domain.name eq "x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := filter.Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+
+			// rendering and parsing again must yield the same expression.
+			again, err := filter.Parse(expr.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.String() != expr.String() {
+				t.Errorf("round trip = %s, want %s", again, expr)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		`name`,
+		`name eq`,
+		`name foo 1`,
+		`name eq "unterminated`,
+		`(a eq 1`,
+		`a eq 1)`,
+		`a eq 1 and`,
+		`a eq 1 b eq 2`,
+		`eq 1`,
+		`not`,
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			_, err := filter.Parse(input)
+
+			var syntaxErr *filter.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want *SyntaxError", input, err)
+			}
+		})
+	}
+}
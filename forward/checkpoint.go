@@ -0,0 +1,100 @@
+package forward
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Checkpoint records the newest forwarded result and domain event per domain,
+// as well as the forwarded status of every result.
+type Checkpoint struct {
+	Results  map[string]int                              `json:"results"`  // highest result id by domain id
+	Statuses map[string]map[string]nimbusec.ResultStatus `json:"statuses"` // forwarded status by domain and result id
+	Events   map[string]EventCursor                      `json:"events"`   // newest event by domain id
+}
+
+// EventCursor marks the position in the event log of a domain. Events carry
+// no id, so the events sharing the newest timestamp are remembered as well.
+type EventCursor struct {
+	Time int64    `json:"time"` // timestamp (in ms) of the newest event
+	Seen []string `json:"seen"` // keys of the events at Time
+}
+
+func (c EventCursor) seen(key string) bool {
+	for _, s := range c.Seen {
+		if s == key {
+			return true
+		}
+	}
+	return false
+}
+
+func newCheckpoint() *Checkpoint {
+	return &Checkpoint{
+		Results:  map[string]int{},
+		Statuses: map[string]map[string]nimbusec.ResultStatus{},
+		Events:   map[string]EventCursor{},
+	}
+}
+
+// LoadCheckpoint reads a checkpoint from path. A missing file results in an
+// empty checkpoint.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	c := newCheckpoint()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if c.Results == nil {
+		c.Results = map[string]int{}
+	}
+	if c.Statuses == nil {
+		c.Statuses = map[string]map[string]nimbusec.ResultStatus{}
+	}
+	if c.Events == nil {
+		c.Events = map[string]EventCursor{}
+	}
+	return c, nil
+}
+
+// Save atomically writes the checkpoint to path.
+func (c *Checkpoint) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func domainKey(id int) string {
+	return strconv.Itoa(id)
+}
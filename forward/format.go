@@ -0,0 +1,228 @@
+// Package forward polls the nimbusec API for new results and domain events
+// and forwards them as CEF or LEEF messages to a syslog endpoint:
+//
+//	w, err := forward.Dial("tls", "siem.example.com:6514", forward.RFC5424, tlsConfig)
+//	f := &forward.Forwarder{
+//		API:        api,
+//		Writer:     w,
+//		Format:     forward.CEF{},
+//		Checkpoint: "/var/lib/nimbusec/forward.json",
+//		Interval:   time.Minute,
+//	}
+//	err = f.Run(ctx)
+//
+// The checkpoint file records what has been forwarded, so a restarted
+// forwarder skips no findings and resends at most those of an interrupted
+// poll.
+package forward
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Message is a single result or domain event to forward. Exactly one of
+// Result and Event is set.
+type Message struct {
+	Domain nimbusec.Domain
+	Result *nimbusec.Result
+	Event  *nimbusec.DomainEvent
+}
+
+// Time returns the time the message refers to.
+func (m Message) Time() time.Time {
+	if m.Event != nil {
+		return m.Event.Time.Time
+	}
+	if !m.Result.LastDate.IsZero() {
+		return m.Result.LastDate.Time
+	}
+	return m.Result.CreateDate.Time
+}
+
+// Severity returns the syslog severity (0 = emergency to 7 = debug) of the
+// message.
+func (m Message) Severity() int {
+	if m.Event != nil {
+		return 6 // informational
+	}
+
+	switch m.Result.Severity {
+	case nimbusec.SeveritySevere:
+		return 2 // critical
+	case nimbusec.SeverityHigh:
+		return 3 // error
+	}
+	return 4 // warning
+}
+
+// level maps the message to the 0 - 10 severity scale used by CEF and LEEF.
+func (m Message) level() int {
+	if m.Event != nil {
+		return 3
+	}
+
+	switch m.Result.Severity {
+	case nimbusec.SeveritySevere:
+		return 10
+	case nimbusec.SeverityHigh:
+		return 8
+	}
+	return 5
+}
+
+// Formatter formats a message as the payload of a syslog message.
+type Formatter interface {
+	Format(m Message) string
+}
+
+const (
+	defaultVendor  = "cumulo"
+	defaultProduct = "nimbusec"
+	defaultVersion = "2"
+)
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// attributes returns the key value pairs describing m. The keys follow the
+// CEF dictionary, LEEF maps them to its own names where they differ.
+func attributes(m Message) [][2]string {
+	attrs := make([][2]string, 0, 12)
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, [2]string{key, value})
+		}
+	}
+
+	if t := m.Time(); !t.IsZero() {
+		add("rt", strconv.FormatInt(t.UnixMilli(), 10))
+	}
+	add("dhost", m.Domain.Name)
+	add("cs1Label", "domainId")
+	add("cs1", strconv.Itoa(m.Domain.Id))
+
+	if m.Event != nil {
+		add("act", m.Event.Event)
+		add("msg", m.Event.Human)
+		return attrs
+	}
+
+	r := m.Result
+	add("externalId", strconv.Itoa(r.Id))
+	add("cat", r.Category)
+	add("act", r.Event)
+	add("outcome", r.Status.String())
+	if r.Threatname != "" {
+		add("cs2Label", "threatname")
+		add("cs2", r.Threatname)
+	}
+	if strings.Contains(r.Resource, "://") {
+		add("request", r.Resource)
+	} else {
+		add("filePath", r.Resource)
+	}
+	add("fileHash", r.MD5)
+	return attrs
+}
+
+// signature returns the id and name of the kind of message.
+func signature(m Message) (string, string) {
+	if m.Event != nil {
+		return "event:" + m.Event.Event, orDefault(m.Event.Human, m.Event.Event)
+	}
+	if m.Result.Threatname != "" {
+		return "result:" + m.Result.Category, m.Result.Threatname
+	}
+	return "result:" + m.Result.Category, m.Result.Event
+}
+
+// CEF formats messages in the ArcSight Common Event Format.
+type CEF struct {
+	Vendor  string // device vendor, defaults to "cumulo"
+	Product string // device product, defaults to "nimbusec"
+	Version string // device version, defaults to "2"
+}
+
+var (
+	cefHeader    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtension = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// Format implements Formatter.
+func (f CEF) Format(m Message) string {
+	id, name := signature(m)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeader.Replace(orDefault(f.Vendor, defaultVendor)),
+		cefHeader.Replace(orDefault(f.Product, defaultProduct)),
+		cefHeader.Replace(orDefault(f.Version, defaultVersion)),
+		cefHeader.Replace(id),
+		cefHeader.Replace(name),
+		m.level())
+
+	for i, attr := range attributes(m) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(attr[0] + "=" + cefExtension.Replace(attr[1]))
+	}
+	return b.String()
+}
+
+// LEEF formats messages in the IBM QRadar Log Event Extended Format 1.0.
+type LEEF struct {
+	Vendor  string // device vendor, defaults to "cumulo"
+	Product string // device product, defaults to "nimbusec"
+	Version string // device version, defaults to "2"
+}
+
+// leefKeys renames the CEF keys that have a predefined LEEF counterpart or
+// are custom CEF fields. Keys mapped to "" are dropped.
+var leefKeys = map[string]string{
+	"rt":       "devTime", // epoch milliseconds without devTimeFormat
+	"dhost":    "dstHost",
+	"cs1Label": "",
+	"cs1":      "domainId",
+	"cs2Label": "",
+	"cs2":      "threatname",
+}
+
+var (
+	leefHeader    = strings.NewReplacer(`|`, `\|`, "\r", " ", "\n", " ")
+	leefAttribute = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+// Format implements Formatter.
+func (f LEEF) Format(m Message) string {
+	id, _ := signature(m)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:1.0|%s|%s|%s|%s|",
+		leefHeader.Replace(orDefault(f.Vendor, defaultVendor)),
+		leefHeader.Replace(orDefault(f.Product, defaultProduct)),
+		leefHeader.Replace(orDefault(f.Version, defaultVersion)),
+		leefHeader.Replace(id))
+
+	b.WriteString("sev=" + strconv.Itoa(m.level()))
+	for _, attr := range attributes(m) {
+		key := attr[0]
+		if name, ok := leefKeys[key]; ok {
+			key = name
+		}
+		if key == "" {
+			continue
+		}
+		b.WriteString("\t" + key + "=" + leefAttribute.Replace(attr[1]))
+	}
+	return b.String()
+}
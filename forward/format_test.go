@@ -0,0 +1,50 @@
+package forward
+
+import (
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+func TestFormat(t *testing.T) {
+	domain := nimbusec.Domain{Id: 7, Name: "example.com"}
+	result := &nimbusec.Result{
+		Id:         42,
+		Status:     nimbusec.StatusPending,
+		Category:   "webshell",
+		Event:      "added file",
+		Severity:   nimbusec.SeveritySevere,
+		Threatname: "PHP|Shell=x",
+		Resource:   "/var/www/a.php",
+		MD5:        "abc",
+	}
+	event := &nimbusec.DomainEvent{Event: "scan", Human: "scan\nfinished"}
+
+	tests := []struct {
+		name   string
+		format Formatter
+		msg    Message
+		want   string
+	}{
+		{
+			"cef result", CEF{}, Message{Domain: domain, Result: result},
+			`CEF:0|cumulo|nimbusec|2|result:webshell|PHP\|Shell=x|10|dhost=example.com cs1Label=domainId cs1=7 externalId=42 cat=webshell act=added file outcome=pending cs2Label=threatname cs2=PHP|Shell\=x filePath=/var/www/a.php fileHash=abc`,
+		},
+		{
+			"cef event", CEF{Vendor: "v"}, Message{Domain: domain, Event: event},
+			`CEF:0|v|nimbusec|2|event:scan|scan finished|3|dhost=example.com cs1Label=domainId cs1=7 act=scan msg=scan\nfinished`,
+		},
+		{
+			"leef result", LEEF{}, Message{Domain: domain, Result: result},
+			"LEEF:1.0|cumulo|nimbusec|2|result:webshell|sev=10\tdstHost=example.com\tdomainId=7\texternalId=42\tcat=webshell\tact=added file\toutcome=pending\tthreatname=PHP|Shell=x\tfilePath=/var/www/a.php\tfileHash=abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.format.Format(tt.msg); got != tt.want {
+				t.Errorf("Format =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package forward
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/filter"
+)
+
+// DefaultEventLimit is the number of events fetched per request.
+const DefaultEventLimit = 1000
+
+// Forwarder polls the results and domain events of all domains and sends the
+// new ones to a syslog endpoint. New results and status changes of known
+// results are forwarded, as well as all events since the last poll. Messages
+// are sent in chronological order per domain and the checkpoint is saved
+// once per poll, so an interrupted forwarder resends at most the messages of
+// the interrupted poll.
+type Forwarder struct {
+	API        *nimbusec.API
+	Writer     *Writer
+	Format     Formatter     // CEF{} or LEEF{}
+	Checkpoint string        // path of the checkpoint file; empty disables persistence
+	Interval   time.Duration // time between polls of Run
+	Domains    string        // filter selecting the domains, defaults to all
+	Results    string        // filter selecting the results, defaults to all
+	EventLimit int           // events fetched per request, defaults to DefaultEventLimit
+	NoEvents   bool          // only forward results
+
+	state *Checkpoint
+}
+
+// Run polls the API every Interval until ctx is done.
+func (f *Forwarder) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.Poll(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll forwards all results and domain events that are new since the last
+// poll or the saved checkpoint. The checkpoint is saved afterwards, even if
+// forwarding failed half way.
+func (f *Forwarder) Poll(ctx context.Context) (err error) {
+	if f.state == nil {
+		state, err := f.load()
+		if err != nil {
+			return err
+		}
+		f.state = state
+	}
+
+	defer func() {
+		if serr := f.save(); err == nil {
+			err = serr
+		}
+	}()
+
+	for domain, err := range f.API.IterDomains(ctx, f.Domains) {
+		if err != nil {
+			return err
+		}
+
+		if err := f.pollResults(ctx, domain); err != nil {
+			return err
+		}
+
+		if f.NoEvents {
+			continue
+		}
+		if err := f.pollEvents(ctx, domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Forwarder) load() (*Checkpoint, error) {
+	if f.Checkpoint == "" {
+		return newCheckpoint(), nil
+	}
+	return LoadCheckpoint(f.Checkpoint)
+}
+
+func (f *Forwarder) save() error {
+	if f.Checkpoint == "" {
+		return nil
+	}
+	return f.state.Save(f.Checkpoint)
+}
+
+// pollResults forwards the results with a higher id than the checkpoint and
+// the results whose status changed since they were last forwarded.
+func (f *Forwarder) pollResults(ctx context.Context, domain nimbusec.Domain) error {
+	key := domainKey(domain.Id)
+	last := f.state.Results[key]
+	known := f.state.Statuses[key]
+
+	// statuses is rebuilt from the current results, so that results which
+	// no longer exist are dropped from the checkpoint.
+	statuses := make(map[string]nimbusec.ResultStatus)
+	results := make([]nimbusec.Result, 0)
+	for result, err := range f.API.IterResults(ctx, domain.Id, f.Results) {
+		if err != nil {
+			return err
+		}
+
+		id := strconv.Itoa(result.Id)
+		status, ok := known[id]
+		switch {
+		case result.Id > last:
+			results = append(results, result)
+		case ok && status != result.Status:
+			// the old status is kept until the change was forwarded.
+			statuses[id] = status
+			results = append(results, result)
+		default:
+			// results forwarded before statuses were recorded keep their
+			// current status without being resent.
+			statuses[id] = result.Status
+		}
+	}
+	f.state.Statuses[key] = statuses
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Id < results[j].Id
+	})
+
+	for i := range results {
+		if err := f.send(Message{Domain: domain, Result: &results[i]}); err != nil {
+			return err
+		}
+
+		statuses[strconv.Itoa(results[i].Id)] = results[i].Status
+		if results[i].Id > f.state.Results[key] {
+			f.state.Results[key] = results[i].Id
+		}
+	}
+	return nil
+}
+
+// pollEvents forwards the domain events newer than the checkpoint.
+func (f *Forwarder) pollEvents(ctx context.Context, domain nimbusec.Domain) error {
+	key := domainKey(domain.Id)
+	cursor := f.state.Events[key]
+
+	events, err := f.fetchEvents(ctx, domain.Id, cursor.Time)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time.Time)
+	})
+
+	for i := range events {
+		event := &events[i]
+		millis := event.Time.UnixMilli()
+		id := eventKey(event)
+
+		if millis < cursor.Time || (millis == cursor.Time && cursor.seen(id)) {
+			continue
+		}
+
+		if err := f.send(Message{Domain: domain, Event: event}); err != nil {
+			return err
+		}
+
+		if millis > cursor.Time {
+			cursor = EventCursor{Time: millis}
+		}
+		cursor.Seen = append(cursor.Seen, id)
+		f.state.Events[key] = cursor
+	}
+	return nil
+}
+
+// fetchEvents returns all events of the domain since the given time (in ms).
+// The API returns the newest events first, so older events are fetched page
+// by page with an upper time bound until the cursor is reached.
+func (f *Forwarder) fetchEvents(ctx context.Context, domain int, since int64) ([]nimbusec.DomainEvent, error) {
+	limit := f.EventLimit
+	if limit <= 0 {
+		limit = DefaultEventLimit
+	}
+
+	events := make([]nimbusec.DomainEvent, 0)
+	seen := map[string]bool{}
+	expr := filter.Ge("time", since)
+	for {
+		page, err := f.API.GetDomainEventContext(ctx, domain, expr.String(), limit)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		oldest := int64(math.MaxInt64)
+		for _, event := range page {
+			if millis := event.Time.UnixMilli(); millis < oldest {
+				oldest = millis
+			}
+
+			id := strconv.FormatInt(event.Time.UnixMilli(), 10) + "\x00" + eventKey(&event)
+			if !seen[id] {
+				seen[id] = true
+				events = append(events, event)
+				added++
+			}
+		}
+
+		if len(page) < limit || oldest <= since {
+			return events, nil
+		}
+		if added == 0 {
+			return nil, fmt.Errorf("forward: more than %d events of domain %d at %d", limit, domain, oldest)
+		}
+
+		expr = filter.And(filter.Ge("time", since), filter.Le("time", oldest))
+	}
+}
+
+// eventKey identifies an event among the events with the same timestamp.
+func eventKey(event *nimbusec.DomainEvent) string {
+	return event.Event + "\x00" + event.Human + "\x00" + event.Machine
+}
+
+func (f *Forwarder) send(m Message) error {
+	return f.Writer.Send(m.Severity(), m.Time(), f.Format.Format(m))
+}
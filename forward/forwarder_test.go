@@ -0,0 +1,159 @@
+package forward_test
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/forward"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+// receiver collects the syslog messages sent to a local UDP socket.
+type receiver struct {
+	conn net.PacketConn
+}
+
+func newReceiver(t *testing.T) *receiver {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &receiver{conn: conn}
+}
+
+// messages returns the messages received until no message arrives for a
+// short time.
+func (r *receiver) messages(t *testing.T) []string {
+	var messages []string
+	buf := make([]byte, 64*1024)
+	for {
+		r.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return messages
+		}
+		messages = append(messages, string(buf[:n]))
+	}
+}
+
+func count(messages []string, substr string) int {
+	n := 0
+	for _, m := range messages {
+		if strings.Contains(m, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestForwarderPoll(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	first := srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "webshell"})
+	srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "malware"})
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	addEvents := func(from, to int) {
+		for i := from; i < to; i++ {
+			event := &nimbusec.DomainEvent{Time: nimbusec.Timestamp{Time: base.Add(time.Duration(i) * time.Minute)}, Event: "scan"}
+			if err := api.CreateDomainEvent(domain.Id, event); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	addEvents(0, 7)
+
+	recv := newReceiver(t)
+	writer, err := forward.Dial("udp", recv.conn.LocalAddr().String(), forward.RFC5424, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+	newForwarder := func() *forward.Forwarder {
+		return &forward.Forwarder{
+			API:        api,
+			Writer:     writer,
+			Format:     forward.CEF{},
+			Checkpoint: checkpoint,
+			EventLimit: 3,
+		}
+	}
+
+	steps := []struct {
+		name    string
+		prepare func()
+		results int
+		changed int
+		events  int
+	}{
+		{"initial", func() {}, 2, 0, 7},
+		{"nothing new", func() {}, 0, 0, 0},
+		{"status change", func() {
+			if _, err := api.UpdateResult(domain.Id, &nimbusec.Result{Id: first.Id, Status: nimbusec.StatusAcknowledged}); err != nil {
+				t.Fatal(err)
+			}
+		}, 1, 1, 0},
+		{"new result and events", func() {
+			srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "blacklist"})
+			addEvents(7, 12)
+		}, 1, 0, 5},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			step.prepare()
+
+			// every step starts from the saved checkpoint.
+			if err := newForwarder().Poll(t.Context()); err != nil {
+				t.Fatal(err)
+			}
+
+			messages := recv.messages(t)
+			if n := count(messages, "|result:"); n != step.results {
+				t.Errorf("forwarded %d results, want %d", n, step.results)
+			}
+			if n := count(messages, "outcome=acknowledged"); n != step.changed {
+				t.Errorf("forwarded %d status changes, want %d", n, step.changed)
+			}
+			if n := count(messages, "|event:"); n != step.events {
+				t.Errorf("forwarded %d events, want %d", n, step.events)
+			}
+		})
+	}
+}
+
+func TestForwarderEventsAtSameTime(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	at := nimbusec.Timestamp{Time: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := api.CreateDomainEvent(domain.Id, &nimbusec.DomainEvent{Time: at, Event: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recv := newReceiver(t)
+	writer, err := forward.Dial("udp", recv.conn.LocalAddr().String(), forward.RFC5424, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	f := &forward.Forwarder{API: api, Writer: writer, Format: forward.CEF{}, EventLimit: 2}
+	if err := f.Poll(t.Context()); err == nil {
+		t.Error("expected an error for more events at the same time than the limit")
+	}
+}
@@ -0,0 +1,155 @@
+package forward
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protocol is the syslog message format.
+type Protocol int
+
+const (
+	RFC5424 Protocol = iota // the syslog protocol
+	RFC3164                 // BSD syslog
+)
+
+// FacilitySecurity is the security/authorization facility (4, "auth") used
+// by Dial.
+const FacilitySecurity = 4
+
+// Writer sends syslog messages over UDP, TCP or TLS. Stream connections use
+// octet counting framing (RFC 6587) for RFC 5424 and newline framing for
+// RFC 3164. Broken connections are re-established on the next write.
+type Writer struct {
+	Network  string      // "udp", "tcp" or "tls"
+	Addr     string      // host:port of the syslog endpoint
+	Protocol Protocol    // message format
+	TLS      *tls.Config // configuration for the "tls" network
+	Facility int         // syslog facility; Dial sets FacilitySecurity
+	Hostname string      // hostname of the messages, defaults to os.Hostname
+	AppName  string      // application name (tag), defaults to "nimbusec"
+	Timeout  time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Dial creates a writer and connects it to the syslog endpoint at addr.
+func Dial(network, addr string, protocol Protocol, config *tls.Config) (*Writer, error) {
+	w := &Writer{
+		Network:  network,
+		Addr:     addr,
+		Protocol: protocol,
+		TLS:      config,
+		Facility: FacilitySecurity,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) connect() error {
+	dialer := &net.Dialer{Timeout: w.Timeout}
+
+	var conn net.Conn
+	var err error
+	switch w.Network {
+	case "udp", "tcp":
+		conn, err = dialer.Dial(w.Network, w.Addr)
+	case "tls":
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.Addr, w.TLS)
+	default:
+		return fmt.Errorf("forward: unsupported network %q", w.Network)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.conn = conn
+	return nil
+}
+
+// Send writes msg with the given syslog severity and timestamp; a zero t
+// means now. A failed write is retried once on a new connection.
+func (w *Writer) Send(severity int, t time.Time, msg string) error {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	data := w.frame(w.format(severity, t, msg))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		if w.conn == nil {
+			if err = w.connect(); err != nil {
+				continue
+			}
+		}
+
+		if w.Timeout > 0 {
+			w.conn.SetWriteDeadline(time.Now().Add(w.Timeout))
+		}
+		if _, err = w.conn.Write(data); err == nil {
+			return nil
+		}
+
+		w.conn.Close()
+		w.conn = nil
+	}
+	return err
+}
+
+// Close closes the connection to the syslog endpoint.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func (w *Writer) hostname() string {
+	if w.Hostname != "" {
+		return w.Hostname
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "-"
+}
+
+func (w *Writer) format(severity int, t time.Time, msg string) string {
+	pri := w.Facility*8 + severity
+	app := orDefault(w.AppName, "nimbusec")
+
+	if w.Protocol == RFC3164 {
+		return fmt.Sprintf("<%d>%s %s %s: %s", pri, t.Format(time.Stamp), w.hostname(), app, msg)
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d - - %s", pri, t.UTC().Format("2006-01-02T15:04:05.000Z07:00"), w.hostname(), app, os.Getpid(), msg)
+}
+
+func (w *Writer) frame(msg string) []byte {
+	switch {
+	case w.Network == "udp":
+		return []byte(msg)
+	case w.Protocol == RFC5424:
+		return []byte(strconv.Itoa(len(msg)) + " " + msg)
+	}
+	return []byte(strings.ReplaceAll(msg, "\n", " ") + "\n")
+}
@@ -0,0 +1,36 @@
+package forward
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterFormat(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		writer *Writer
+		want   string
+	}{
+		{"rfc5424", &Writer{Network: "udp", Protocol: RFC5424, Facility: FacilitySecurity, Hostname: "host"}, "<34>1 2024-03-01T12:30:00.000Z host nimbusec "},
+		{"rfc3164", &Writer{Network: "udp", Protocol: RFC3164, Facility: FacilitySecurity, Hostname: "host", AppName: "app"}, "<34>Mar  1 12:30:00 host app: msg"},
+		{"kern facility", &Writer{Network: "udp", Protocol: RFC3164, Facility: 0, Hostname: "host"}, "<2>Mar  1 12:30:00 host nimbusec: msg"},
+		{"octet counting", &Writer{Network: "tcp", Protocol: RFC5424, Hostname: "host"}, "<2>1 2024-03-01T12:30:00.000Z host nimbusec "},
+		{"newline framing", &Writer{Network: "tcp", Protocol: RFC3164, Hostname: "host"}, "<2>Mar  1 12:30:00 host nimbusec: msg\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(tt.writer.frame(tt.writer.format(2, at, "msg")))
+			if tt.writer.Network == "tcp" && tt.writer.Protocol == RFC5424 {
+				// strip the octet count prefix.
+				_, got, _ = strings.Cut(got, " ")
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("message = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
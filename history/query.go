@@ -0,0 +1,213 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Query restricts the results considered by the query helpers. Zero fields
+// do not restrict.
+type Query struct {
+	Domain   int       // id of the domain
+	Category string    // category of the results, e.g. "blacklist"
+	Since    time.Time // only results created at or after Since
+	Until    time.Time // only results created before Until
+}
+
+// where builds the condition on the results table aliased as r.
+func (q Query) where() (string, []interface{}) {
+	conds := []string{"1 = 1"}
+	args := make([]interface{}, 0)
+
+	if q.Domain != 0 {
+		conds = append(conds, "r.domain_id = ?")
+		args = append(args, q.Domain)
+	}
+	if q.Category != "" {
+		conds = append(conds, "r.category = ?")
+		args = append(args, q.Category)
+	}
+	if !q.Since.IsZero() {
+		conds = append(conds, "r.create_date >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		conds = append(conds, "r.create_date < ?")
+		args = append(args, q.Until.UnixMilli())
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Remediation summarizes how long it took to remove results.
+type Remediation struct {
+	Remediated int           // results that have been removed
+	Open       int           // results that are not removed yet
+	Mean       time.Duration // mean time from creation to removal
+	Max        time.Duration // longest time from creation to removal
+}
+
+// MTTR returns the mean time to remediate the results matching q, measured
+// from the creation of a result to its first transition to removed.
+func (s *Store) MTTR(ctx context.Context, q Query) (Remediation, error) {
+	where, args := q.where()
+	row := s.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(t.removed),
+			COUNT(*) - COUNT(t.removed),
+			COALESCE(AVG(t.removed - COALESCE(r.create_date, r.first_seen)), 0),
+			COALESCE(MAX(t.removed - COALESCE(r.create_date, r.first_seen)), 0)
+		FROM results r
+		LEFT JOIN (
+			SELECT domain_id, result_id, MIN(time) AS removed
+			FROM transitions WHERE new_status = ?
+			GROUP BY domain_id, result_id
+		) t ON t.domain_id = r.domain_id AND t.result_id = r.id
+		WHERE `+where, append([]interface{}{nimbusec.StatusRemoved}, args...)...)
+
+	var rem Remediation
+	var mean float64
+	var max int64
+	if err := row.Scan(&rem.Remediated, &rem.Open, &mean, &max); err != nil {
+		return rem, err
+	}
+
+	rem.Mean = time.Duration(mean) * time.Millisecond
+	rem.Max = time.Duration(max) * time.Millisecond
+	return rem, nil
+}
+
+// Recurrence describes how often the same threat was found.
+type Recurrence struct {
+	Key         string             // the MD5 or threatname
+	Results     int                // number of distinct results
+	Domains     int                // number of affected domains
+	Occurrences int                // detections including reoccurrences of a result
+	First       nimbusec.Timestamp // first creation of a result
+	Last        nimbusec.Timestamp // last detection of a result
+}
+
+// RecurrenceByMD5 groups the results matching q by the MD5 hash of the
+// affected file, most frequent first. Results without hash are ignored.
+func (s *Store) RecurrenceByMD5(ctx context.Context, q Query) ([]Recurrence, error) {
+	return s.recurrence(ctx, "md5", q)
+}
+
+// RecurrenceByThreatname is like RecurrenceByMD5 but groups by threatname.
+func (s *Store) RecurrenceByThreatname(ctx context.Context, q Query) ([]Recurrence, error) {
+	return s.recurrence(ctx, "threatname", q)
+}
+
+func (s *Store) recurrence(ctx context.Context, column string, q Query) ([]Recurrence, error) {
+	where, args := q.where()
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.`+column+`, COUNT(*), COUNT(DISTINCT r.domain_id), SUM(r.occurrences),
+			MIN(r.create_date), MAX(r.last_date)
+		FROM results r
+		WHERE r.`+column+` != '' AND `+where+`
+		GROUP BY r.`+column+`
+		ORDER BY COUNT(*) DESC, SUM(r.occurrences) DESC, r.`+column, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recurrences := make([]Recurrence, 0)
+	for rows.Next() {
+		var r Recurrence
+		if err := rows.Scan(&r.Key, &r.Results, &r.Domains, &r.Occurrences, &r.First, &r.Last); err != nil {
+			return nil, err
+		}
+		recurrences = append(recurrences, r)
+	}
+	return recurrences, rows.Err()
+}
+
+// Entry is a single point on the timeline of a domain: either a status
+// transition of a result or a domain event.
+type Entry struct {
+	Time nimbusec.Timestamp
+
+	// set for transitions
+	ResultID   int
+	OldStatus  nimbusec.ResultStatus // 0 when the result appeared
+	NewStatus  nimbusec.ResultStatus
+	Category   string
+	Threatname string
+	Resource   string
+
+	// set for domain events
+	Event *nimbusec.DomainEvent
+}
+
+// IsEvent reports whether the entry is a domain event.
+func (e Entry) IsEvent() bool {
+	return e.Event != nil
+}
+
+// Timeline returns all transitions and events of a domain in chronological
+// order. A zero since or until does not restrict the time range.
+func (s *Store) Timeline(ctx context.Context, domain int, since, until time.Time) ([]Entry, error) {
+	lower, upper := int64(0), int64(1<<62)
+	if !since.IsZero() {
+		lower = since.UnixMilli()
+	}
+	if !until.IsZero() {
+		upper = until.UnixMilli()
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.time, t.result_id, t.old_status, t.new_status, r.category, r.threatname, r.resource,
+			NULL, NULL, NULL
+		FROM transitions t
+		JOIN results r ON r.domain_id = t.domain_id AND r.id = t.result_id
+		WHERE t.domain_id = ? AND t.time >= ? AND t.time < ?
+		UNION ALL
+		SELECT e.time, 0, NULL, 0, '', '', '', e.event, e.human, e.machine
+		FROM events e
+		WHERE e.domain_id = ? AND e.time >= ? AND e.time < ?
+		ORDER BY 1, 2`,
+		domain, lower, upper, domain, lower, upper)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		var e Entry
+		var old sql.NullInt64
+		var event, human, machine sql.NullString
+		err := rows.Scan(&e.Time, &e.ResultID, &old, &e.NewStatus, &e.Category, &e.Threatname, &e.Resource,
+			&event, &human, &machine)
+		if err != nil {
+			return nil, err
+		}
+
+		e.OldStatus = nimbusec.ResultStatus(old.Int64)
+		if event.Valid {
+			e.Event = &nimbusec.DomainEvent{
+				Time:    e.Time,
+				Event:   event.String,
+				Human:   human.String,
+				Machine: machine.String,
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// FirstSeen returns when a result of the given category first appeared on a
+// domain, e.g. when it was blacklisted for the first time. The zero time is
+// returned if there was none.
+func (s *Store) FirstSeen(ctx context.Context, domain int, category string) (time.Time, error) {
+	var first nimbusec.Timestamp
+	err := s.db.QueryRowContext(ctx, `
+		SELECT MIN(COALESCE(create_date, first_seen)) FROM results
+		WHERE domain_id = ? AND category = ?`, domain, category).Scan(&first)
+	return first.Time, err
+}
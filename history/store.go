@@ -0,0 +1,420 @@
+// Package history keeps a local record of the results of a nimbusec account.
+// The API only reports the current state; a Store snapshots domains,
+// results, metadata and events into SQLite on every Sync and records each
+// status transition, so questions about the past can be answered:
+//
+//	store, err := history.Open("history.db")
+//	err = store.Sync(ctx, api)
+//	mttr, err := store.MTTR(ctx, history.Query{Category: "blacklist"})
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+)
+
+// DefaultEventLimit is the number of domain events fetched per domain and
+// sync.
+const DefaultEventLimit = 1000
+
+// schema creates the tables of the store. All times are stored as unix
+// timestamps in milliseconds, the same unit the nimbusec API uses.
+const schema = `
+CREATE TABLE IF NOT EXISTS syncs (
+	id   INTEGER PRIMARY KEY AUTOINCREMENT,
+	time INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS domains (
+	id         INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	bundle     TEXT NOT NULL,
+	scheme     TEXT NOT NULL,
+	deep_scan  TEXT NOT NULL,
+	fast_scans TEXT NOT NULL,
+	first_seen INTEGER NOT NULL,
+	last_seen  INTEGER NOT NULL,
+	deleted    INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS results (
+	domain_id   INTEGER NOT NULL,
+	id          INTEGER NOT NULL,
+	status      INTEGER NOT NULL,
+	event       TEXT NOT NULL,
+	category    TEXT NOT NULL,
+	severity    INTEGER NOT NULL,
+	probability REAL NOT NULL,
+	threatname  TEXT NOT NULL,
+	resource    TEXT NOT NULL,
+	md5         TEXT NOT NULL,
+	create_date INTEGER,
+	last_date   INTEGER,
+	occurrences INTEGER NOT NULL DEFAULT 1,
+	first_seen  INTEGER NOT NULL,
+	last_seen   INTEGER NOT NULL,
+	PRIMARY KEY (domain_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS results_md5 ON results (md5);
+CREATE INDEX IF NOT EXISTS results_threatname ON results (threatname);
+
+CREATE TABLE IF NOT EXISTS transitions (
+	domain_id  INTEGER NOT NULL,
+	result_id  INTEGER NOT NULL,
+	time       INTEGER NOT NULL,
+	old_status INTEGER,
+	new_status INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS transitions_result ON transitions (domain_id, result_id);
+
+CREATE TABLE IF NOT EXISTS metadata (
+	domain_id      INTEGER NOT NULL,
+	time           INTEGER NOT NULL,
+	last_deep_scan INTEGER,
+	last_fast_scan INTEGER,
+	agent          INTEGER,
+	files          INTEGER NOT NULL,
+	size           INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS events (
+	domain_id INTEGER NOT NULL,
+	time      INTEGER NOT NULL,
+	event     TEXT NOT NULL,
+	human     TEXT NOT NULL,
+	machine   TEXT NOT NULL,
+	UNIQUE (domain_id, time, event, human, machine)
+);
+`
+
+// Store is a result history backed by a SQL database.
+type Store struct {
+	db *sql.DB
+
+	// EventLimit is the number of events fetched per domain by Sync,
+	// defaults to DefaultEventLimit.
+	EventLimit int
+
+	// Now returns the time of a sync, defaults to time.Now.
+	Now func() time.Time
+}
+
+// Open opens or creates the SQLite database at path.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New uses an already opened SQLite database and creates the tables of the
+// store if necessary.
+func New(db *sql.DB) (*Store, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+	return &Store{db: db, Now: time.Now}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// DB returns the underlying database for custom queries.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// millis converts t to the stored representation; the zero time is NULL.
+func millis(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UnixMilli()
+}
+
+// Snapshot is the state of a single domain at the time of a sync.
+type Snapshot struct {
+	Domain   nimbusec.Domain
+	Results  []nimbusec.Result
+	Metadata *nimbusec.DomainMetadata // nil if not available
+	Events   []nimbusec.DomainEvent
+}
+
+// Sync fetches all domains with their results, metadata and events and
+// records them. Domains and results that are no longer returned by the API
+// are marked as deleted and removed.
+func (s *Store) Sync(ctx context.Context, api *nimbusec.API) error {
+	now := s.Now()
+	domains, err := api.FindDomainsContext(ctx, nimbusec.EmptyFilter)
+	if err != nil {
+		return err
+	}
+
+	limit := s.EventLimit
+	if limit <= 0 {
+		limit = DefaultEventLimit
+	}
+
+	seen := make([]int, 0, len(domains))
+	for _, domain := range domains {
+		snapshot := Snapshot{Domain: domain}
+
+		snapshot.Results, err = api.FindResultsContext(ctx, domain.Id, nimbusec.EmptyFilter)
+		if err != nil {
+			return err
+		}
+
+		snapshot.Metadata, err = api.GetDomainMetadataContext(ctx, domain.Id)
+		if nimbusec.IsNotFound(err) {
+			snapshot.Metadata, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		snapshot.Events, err = api.GetDomainEventContext(ctx, domain.Id, nimbusec.EmptyFilter, limit)
+		if err != nil {
+			return err
+		}
+
+		if err := s.Record(ctx, now, snapshot); err != nil {
+			return err
+		}
+		seen = append(seen, domain.Id)
+	}
+
+	return s.finish(ctx, now, seen)
+}
+
+// Record stores the snapshot of a domain taken at time t. Results of the
+// domain missing in the snapshot are considered removed.
+func (s *Store) Record(ctx context.Context, t time.Time, snapshot Snapshot) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := t.UnixMilli()
+	if err := recordDomain(ctx, tx, now, snapshot.Domain); err != nil {
+		return err
+	}
+
+	ids := make(map[int]bool, len(snapshot.Results))
+	for _, result := range snapshot.Results {
+		ids[result.Id] = true
+		if err := recordResult(ctx, tx, now, snapshot.Domain.Id, result); err != nil {
+			return err
+		}
+	}
+
+	if err := removeMissing(ctx, tx, now, snapshot.Domain.Id, ids); err != nil {
+		return err
+	}
+
+	if m := snapshot.Metadata; m != nil {
+		_, err := tx.ExecContext(ctx, `INSERT INTO metadata
+			(domain_id, time, last_deep_scan, last_fast_scan, agent, files, size)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			snapshot.Domain.Id, now, millis(m.LastDeepScan.Time), millis(m.LastFastScan.Time),
+			millis(m.Agent.Time), m.Files, m.Size)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range snapshot.Events {
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO events
+			(domain_id, time, event, human, machine) VALUES (?, ?, ?, ?, ?)`,
+			snapshot.Domain.Id, e.Time.UnixMilli(), e.Event, e.Human, e.Machine)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func recordDomain(ctx context.Context, tx *sql.Tx, now int64, d nimbusec.Domain) error {
+	fastScans, err := json.Marshal(d.FastScans)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO domains
+		(id, name, bundle, scheme, deep_scan, fast_scans, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name, bundle = excluded.bundle, scheme = excluded.scheme,
+			deep_scan = excluded.deep_scan, fast_scans = excluded.fast_scans,
+			last_seen = excluded.last_seen, deleted = NULL`,
+		d.Id, d.Name, d.Bundle, d.Scheme, d.DeepScan, string(fastScans), now, now)
+	return err
+}
+
+func recordResult(ctx context.Context, tx *sql.Tx, now int64, domain int, r nimbusec.Result) error {
+	var status nimbusec.ResultStatus
+	var lastDate nimbusec.Timestamp
+	err := tx.QueryRowContext(ctx, `SELECT status, last_date FROM results
+		WHERE domain_id = ? AND id = ?`, domain, r.Id).Scan(&status, &lastDate)
+
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.ExecContext(ctx, `INSERT INTO results
+			(domain_id, id, status, event, category, severity, probability, threatname,
+			 resource, md5, create_date, last_date, first_seen, last_seen)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			domain, r.Id, r.Status, r.Event, r.Category, r.Severity, r.Probability, r.Threatname,
+			r.Resource, r.MD5, millis(r.CreateDate.Time), millis(r.LastDate.Time), now, now)
+		if err != nil {
+			return err
+		}
+
+		// the first transition dates back to the creation of the result.
+		since := now
+		if !r.CreateDate.IsZero() {
+			since = r.CreateDate.UnixMilli()
+		}
+		return transition(ctx, tx, since, domain, r.Id, nil, r.Status)
+
+	case err != nil:
+		return err
+	}
+
+	reoccurred := 0
+	if r.LastDate.After(lastDate.Time) {
+		reoccurred = 1
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE results SET
+		status = ?, event = ?, category = ?, severity = ?, probability = ?, threatname = ?,
+		resource = ?, md5 = ?, create_date = ?, last_date = ?,
+		occurrences = occurrences + ?, last_seen = ?
+		WHERE domain_id = ? AND id = ?`,
+		r.Status, r.Event, r.Category, r.Severity, r.Probability, r.Threatname,
+		r.Resource, r.MD5, millis(r.CreateDate.Time), millis(r.LastDate.Time),
+		reoccurred, now, domain, r.Id)
+	if err != nil {
+		return err
+	}
+
+	if status != r.Status {
+		return transition(ctx, tx, now, domain, r.Id, &status, r.Status)
+	}
+	return nil
+}
+
+func transition(ctx context.Context, tx *sql.Tx, now int64, domain, result int, from *nimbusec.ResultStatus, to nimbusec.ResultStatus) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO transitions
+		(domain_id, result_id, time, old_status, new_status) VALUES (?, ?, ?, ?, ?)`,
+		domain, result, now, from, to)
+	return err
+}
+
+// removeMissing sets the status of all results of domain not in ids to
+// removed.
+func removeMissing(ctx context.Context, tx *sql.Tx, now int64, domain int, ids map[int]bool) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, status FROM results
+		WHERE domain_id = ? AND status != ?`, domain, nimbusec.StatusRemoved)
+	if err != nil {
+		return err
+	}
+
+	type gone struct {
+		id     int
+		status nimbusec.ResultStatus
+	}
+	missing := make([]gone, 0)
+	for rows.Next() {
+		var g gone
+		if err := rows.Scan(&g.id, &g.status); err != nil {
+			rows.Close()
+			return err
+		}
+		if !ids[g.id] {
+			missing = append(missing, g)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, g := range missing {
+		_, err := tx.ExecContext(ctx, `UPDATE results SET status = ?
+			WHERE domain_id = ? AND id = ?`, nimbusec.StatusRemoved, domain, g.id)
+		if err != nil {
+			return err
+		}
+		if err := transition(ctx, tx, now, domain, g.id, &g.status, nimbusec.StatusRemoved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish records the sync and marks all domains not in seen as deleted.
+func (s *Store) finish(ctx context.Context, t time.Time, seen []int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := t.UnixMilli()
+	ids := make(map[int]bool, len(seen))
+	for _, id := range seen {
+		ids[id] = true
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM domains WHERE deleted IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	deleted := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		if !ids[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range deleted {
+		if _, err := tx.ExecContext(ctx, `UPDATE domains SET deleted = ? WHERE id = ?`, now, id); err != nil {
+			return err
+		}
+		if err := removeMissing(ctx, tx, now, id, nil); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO syncs (time) VALUES (?)`, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,228 @@
+package history_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/history"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func at(hours int) nimbusec.Timestamp {
+	return nimbusec.Timestamp{Time: base.Add(time.Duration(hours) * time.Hour)}
+}
+
+func open(t *testing.T) *history.Store {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRecord(t *testing.T) {
+	store := open(t)
+	ctx := t.Context()
+	domain := nimbusec.Domain{Id: 1, Name: "example.com"}
+
+	shell := nimbusec.Result{Id: 10, Status: nimbusec.StatusPending, Category: "webshell", MD5: "aaa", CreateDate: at(0), LastDate: at(0)}
+	blacklist := nimbusec.Result{Id: 11, Status: nimbusec.StatusPending, Category: "blacklist", CreateDate: at(1), LastDate: at(1)}
+	copied := nimbusec.Result{Id: 12, Status: nimbusec.StatusPending, Category: "webshell", MD5: "aaa", CreateDate: at(2), LastDate: at(2)}
+
+	acknowledged := shell
+	acknowledged.Status = nimbusec.StatusAcknowledged
+	reoccurred := copied
+	reoccurred.LastDate = at(5)
+
+	snapshots := []struct {
+		time     int
+		snapshot history.Snapshot
+	}{
+		{2, history.Snapshot{Domain: domain, Results: []nimbusec.Result{shell, blacklist, copied}}},
+		{4, history.Snapshot{Domain: domain, Results: []nimbusec.Result{acknowledged, blacklist, reoccurred}, Events: []nimbusec.DomainEvent{{Time: at(3), Event: "scan"}}}},
+		// the blacklist result is gone, so it counts as removed.
+		{6, history.Snapshot{Domain: domain, Results: []nimbusec.Result{acknowledged, reoccurred}}},
+	}
+	for _, s := range snapshots {
+		if err := store.Record(ctx, at(s.time).Time, s.snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("mttr", func(t *testing.T) {
+		tests := []struct {
+			query history.Query
+			want  history.Remediation
+		}{
+			{history.Query{}, history.Remediation{Remediated: 1, Open: 2, Mean: 5 * time.Hour, Max: 5 * time.Hour}},
+			{history.Query{Category: "webshell"}, history.Remediation{Open: 2}},
+			{history.Query{Since: at(1).Time, Until: at(2).Time}, history.Remediation{Remediated: 1, Mean: 5 * time.Hour, Max: 5 * time.Hour}},
+			{history.Query{Domain: 2}, history.Remediation{}},
+		}
+
+		for _, tt := range tests {
+			got, err := store.MTTR(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("MTTR(%+v) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("recurrence", func(t *testing.T) {
+		recurrences, err := store.RecurrenceByMD5(ctx, history.Query{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recurrences) != 1 {
+			t.Fatalf("got %d recurrences, want 1", len(recurrences))
+		}
+
+		r := recurrences[0]
+		if r.Key != "aaa" || r.Results != 2 || r.Domains != 1 || r.Occurrences != 3 {
+			t.Errorf("recurrence = %+v", r)
+		}
+		if !r.First.Equal(at(0).Time) || !r.Last.Equal(at(5).Time) {
+			t.Errorf("recurrence from %v to %v, want %v to %v", r.First, r.Last, at(0), at(5))
+		}
+	})
+
+	t.Run("timeline", func(t *testing.T) {
+		entries, err := store.Timeline(ctx, domain.Id, time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		type entry struct {
+			hours  int
+			result int
+			old    nimbusec.ResultStatus
+			new    nimbusec.ResultStatus
+			event  bool
+		}
+		want := []entry{
+			{0, 10, 0, nimbusec.StatusPending, false},
+			{1, 11, 0, nimbusec.StatusPending, false},
+			{2, 12, 0, nimbusec.StatusPending, false},
+			{3, 0, 0, 0, true},
+			{4, 10, nimbusec.StatusPending, nimbusec.StatusAcknowledged, false},
+			{6, 11, nimbusec.StatusPending, nimbusec.StatusRemoved, false},
+		}
+
+		if len(entries) != len(want) {
+			t.Fatalf("got %d entries, want %d", len(entries), len(want))
+		}
+		for i, e := range entries {
+			got := entry{int(e.Time.Sub(base) / time.Hour), e.ResultID, e.OldStatus, e.NewStatus, e.IsEvent()}
+			if got != want[i] {
+				t.Errorf("entry %d = %+v, want %+v", i, got, want[i])
+			}
+		}
+
+		entries, err = store.Timeline(ctx, domain.Id, at(3).Time, at(5).Time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("got %d entries between 3 and 5, want 2", len(entries))
+		}
+	})
+
+	t.Run("first seen", func(t *testing.T) {
+		tests := []struct {
+			category string
+			want     time.Time
+		}{
+			{"blacklist", at(1).Time},
+			{"webshell", at(0).Time},
+			{"malware", time.Time{}},
+		}
+
+		for _, tt := range tests {
+			got, err := store.FirstSeen(ctx, domain.Id, tt.category)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("FirstSeen(%q) = %v, want %v", tt.category, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestSync(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	kept := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	deleted := srv.AddDomain(nimbusec.Domain{Name: "example.org"})
+	srv.AddResult(kept.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "webshell", CreateDate: at(0)})
+	srv.AddResult(deleted.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "malware", CreateDate: at(0)})
+	if err := api.CreateDomainEvent(kept.Id, &nimbusec.DomainEvent{Time: at(1), Event: "scan"}); err != nil {
+		t.Fatal(err)
+	}
+
+	store := open(t)
+	ctx := t.Context()
+
+	now := base.Add(2 * time.Hour)
+	store.Now = func() time.Time { return now }
+	if err := store.Sync(ctx, api); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.DeleteDomain(&deleted, true); err != nil {
+		t.Fatal(err)
+	}
+	now = base.Add(4 * time.Hour)
+	if err := store.Sync(ctx, api); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		domain int
+		want   history.Remediation
+	}{
+		{kept.Id, history.Remediation{Open: 1}},
+		{deleted.Id, history.Remediation{Remediated: 1, Mean: 4 * time.Hour, Max: 4 * time.Hour}},
+	}
+	for _, tt := range tests {
+		got, err := store.MTTR(ctx, history.Query{Domain: tt.domain})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("MTTR of domain %d = %+v, want %+v", tt.domain, got, tt.want)
+		}
+	}
+
+	var deletedAt, syncs int64
+	if err := store.DB().QueryRowContext(ctx, `SELECT deleted FROM domains WHERE id = ?`, deleted.Id).Scan(&deletedAt); err != nil {
+		t.Fatal(err)
+	}
+	if deletedAt != now.UnixMilli() {
+		t.Errorf("domain deleted at %d, want %d", deletedAt, now.UnixMilli())
+	}
+	if err := store.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM syncs`).Scan(&syncs); err != nil {
+		t.Fatal(err)
+	}
+	if syncs != 2 {
+		t.Errorf("recorded %d syncs, want 2", syncs)
+	}
+
+	entries, err := store.Timeline(ctx, kept.Id, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || !entries[1].IsEvent() {
+		t.Errorf("timeline = %+v, want a transition and an event", entries)
+	}
+}
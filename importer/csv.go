@@ -0,0 +1,184 @@
+// Package importer registers many domains at once from a CSV file:
+//
+//	name,scheme,bundle,fastScans,sitemap
+//	www.example.com,https,3a8f0c2d,/ /shop/,
+//	blog.example.com,,3a8f0c2d,,blog-sitemap.xml
+//
+// Only the name column is required. Landing pages are separated by spaces
+// or semicolons and may be relative to the domain; with a sitemap column
+// they are derived from a local sitemap.xml instead. Every row is normalized
+// and validated with Domain.Normalize and Domain.Validate before any request
+// is made, and the outcome of every row is written to a result file.
+// Domains are created with CreateOrGetDomain and existing domains are left
+// untouched, so an import can safely be run again.
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Options control how rows are read.
+type Options struct {
+	Scheme       string // scheme of rows without one, defaults to "https"
+	Bundle       string // bundle of rows without one
+	SitemapDir   string // directory relative sitemap paths are resolved against
+	MaxFastScans int    // maximum number of landing pages per domain, 0 for no limit
+}
+
+// Row is a single domain read from the CSV file.
+type Row struct {
+	Line   int // line in the CSV file
+	Domain nimbusec.Domain
+	Err    error // validation error, the row is not imported if set
+}
+
+// columns are the known column names, lower case.
+var columns = map[string]string{
+	"name":         "name",
+	"domain":       "name",
+	"scheme":       "scheme",
+	"bundle":       "bundle",
+	"deepscan":     "deepScan",
+	"fastscans":    "fastScans",
+	"landingpages": "fastScans",
+	"sitemap":      "sitemap",
+}
+
+// ReadCSV reads and normalizes all rows of a CSV file with a header line.
+// Invalid rows are returned with Err set; an error is only returned if the
+// file itself can not be read.
+func ReadCSV(r io.Reader, opts Options) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("importer: reading header: %v", err)
+	}
+
+	index := make(map[string]int)
+	for i, name := range header {
+		column, ok := columns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("importer: unknown column %q", name)
+		}
+		index[column] = i
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, fmt.Errorf("importer: missing column \"name\"")
+	}
+
+	rows := make([]Row, 0)
+	seen := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("importer: %v", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		field := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := Row{Line: line}
+		row.Domain, row.Err = normalize(field, opts)
+		if row.Err != nil {
+			// keep the name to identify the row in the results.
+			row.Domain.Name = field("name")
+		} else {
+			if first, ok := seen[row.Domain.Name]; ok {
+				row.Err = fmt.Errorf("duplicate of line %d", first)
+			} else {
+				seen[row.Domain.Name] = line
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// normalize builds the domain of a row with Domain.Normalize and checks it
+// with Domain.Validate. Landing pages of a sitemap on other hosts are
+// skipped.
+func normalize(field func(string) string, opts Options) (nimbusec.Domain, error) {
+	d := nimbusec.Domain{
+		Name:     field("name"),
+		Scheme:   field("scheme"),
+		Bundle:   field("bundle"),
+		DeepScan: field("deepScan"),
+		FastScans: strings.FieldsFunc(field("fastScans"), func(r rune) bool {
+			return r == ';' || r == ' ' || r == '\t'
+		}),
+	}
+	if d.Name == "" {
+		return d, fmt.Errorf("missing name")
+	}
+
+	// a scheme given with the name takes precedence over the default.
+	if d.Scheme == "" && !strings.Contains(d.Name, "://") {
+		d.Scheme = opts.Scheme
+		if d.Scheme == "" {
+			d.Scheme = "https"
+		}
+	}
+
+	if d.Bundle == "" {
+		d.Bundle = opts.Bundle
+	}
+	if d.Bundle == "" {
+		return d, fmt.Errorf("missing bundle")
+	}
+
+	if d.DeepScan == "" {
+		d.DeepScan = "/"
+	}
+	if err := d.Normalize(); err != nil {
+		return d, err
+	}
+
+	if sitemap := field("sitemap"); sitemap != "" {
+		if !filepath.IsAbs(sitemap) {
+			sitemap = filepath.Join(opts.SitemapDir, sitemap)
+		}
+		locs, err := ReadSitemap(sitemap)
+		if err != nil {
+			return d, err
+		}
+
+		site := nimbusec.Domain{Name: d.Name, Scheme: d.Scheme, FastScans: locs}
+		if err := site.Normalize(); err != nil {
+			return d, err
+		}
+
+		// sitemaps may list pages of other hosts, those are skipped.
+		for _, page := range site.FastScans {
+			if u, err := url.Parse(page); err == nil && u.Host == d.Name {
+				d.FastScans = append(d.FastScans, page)
+			}
+		}
+		if err := d.Normalize(); err != nil {
+			return d, err
+		}
+	}
+
+	if opts.MaxFastScans > 0 && len(d.FastScans) > opts.MaxFastScans {
+		d.FastScans = d.FastScans[:opts.MaxFastScans]
+	}
+	return d, d.Validate()
+}
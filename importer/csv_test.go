@@ -0,0 +1,145 @@
+package importer_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/importer"
+)
+
+const sitemap = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://blog.example.com/</loc></url>
+  <url><loc>https://blog.example.com/post</loc></url>
+  <url><loc>https://cdn.example.com/x</loc></url>
+</urlset>`
+
+func TestReadCSV(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sitemap.xml"), []byte(sitemap), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := importer.Options{Bundle: "basic", SitemapDir: dir, MaxFastScans: 3}
+
+	tests := []struct {
+		name string
+		csv  string
+		want nimbusec.Domain
+		err  string
+	}{
+		{
+			"defaults",
+			"name\nwww.example.com\n",
+			nimbusec.Domain{Name: "www.example.com", Scheme: "https", Bundle: "basic", DeepScan: "https://www.example.com/", FastScans: []string{}},
+			"",
+		},
+		{
+			"url as name",
+			"domain,bundle\nHTTP://Shop.Example.com/,pro\n",
+			nimbusec.Domain{Name: "shop.example.com", Scheme: "http", Bundle: "pro", DeepScan: "http://shop.example.com/", FastScans: []string{}},
+			"",
+		},
+		{
+			"landing pages",
+			"name,fastScans,deepScan\nexample.com,/ /shop;/shop /about#x /contact,/start\n",
+			nimbusec.Domain{Name: "example.com", Scheme: "https", Bundle: "basic", DeepScan: "https://example.com/start",
+				FastScans: []string{"https://example.com/", "https://example.com/shop", "https://example.com/about"}},
+			"",
+		},
+		{
+			"sitemap",
+			"name,sitemap\nblog.example.com,sitemap.xml\n",
+			nimbusec.Domain{Name: "blog.example.com", Scheme: "https", Bundle: "basic", DeepScan: "https://blog.example.com/",
+				FastScans: []string{"https://blog.example.com/", "https://blog.example.com/post"}},
+			"",
+		},
+		{
+			"idn",
+			"name,fastScans\nBu\u0308cher.example,/shop\n",
+			nimbusec.Domain{Name: "xn--bcher-kva.example", Scheme: "https", Bundle: "basic", DeepScan: "https://xn--bcher-kva.example/",
+				FastScans: []string{"https://xn--bcher-kva.example/shop"}},
+			"",
+		},
+		{"invalid name", "name\nexa mple.com\n", nimbusec.Domain{Name: "exa mple.com"}, "invalid name"},
+		{"missing name", "name,scheme\n,https\n", nimbusec.Domain{}, "missing name"},
+		{"invalid scheme", "name,scheme\nexample.com,ftp\n", nimbusec.Domain{Name: "example.com"}, "invalid scheme"},
+		{"foreign landing page", "name,fastScans\nexample.com,https://example.org/\n", nimbusec.Domain{Name: "example.com"}, "host does not match"},
+		{"missing sitemap", "name,sitemap\nexample.com,missing.xml\n", nimbusec.Domain{Name: "example.com"}, "missing.xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := importer.ReadCSV(strings.NewReader(tt.csv), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+
+			row := rows[0]
+			if tt.err != "" {
+				if row.Err == nil || !strings.Contains(row.Err.Error(), tt.err) {
+					t.Errorf("error = %v, want %q", row.Err, tt.err)
+				}
+				if row.Domain.Name != tt.want.Name {
+					t.Errorf("name = %q, want %q", row.Domain.Name, tt.want.Name)
+				}
+				return
+			}
+
+			if row.Err != nil {
+				t.Fatal(row.Err)
+			}
+			if !reflect.DeepEqual(row.Domain, tt.want) {
+				t.Errorf("domain = %+v, want %+v", row.Domain, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSVFile(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		err  bool
+	}{
+		{"empty", "", true},
+		{"unknown column", "name,color\n", true},
+		{"no name column", "scheme\nhttps\n", true},
+		{"header only", "name\n", false},
+	}
+
+	for _, tt := range tests {
+		_, err := importer.ReadCSV(strings.NewReader(tt.csv), importer.Options{Bundle: "basic"})
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestReadCSVDuplicates(t *testing.T) {
+	rows, err := importer.ReadCSV(strings.NewReader("name\nexample.com\nEXAMPLE.com.\nexample.org\n"), importer.Options{Bundle: "basic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make([]int, len(rows))
+	for i, row := range rows {
+		lines[i] = row.Line
+	}
+	if !reflect.DeepEqual(lines, []int{2, 3, 4}) {
+		t.Errorf("lines = %v, want [2 3 4]", lines)
+	}
+
+	if rows[1].Err == nil || !strings.Contains(rows[1].Err.Error(), "duplicate of line 2") {
+		t.Errorf("error = %v, want duplicate of line 2", rows[1].Err)
+	}
+	if rows[0].Err != nil || rows[2].Err != nil {
+		t.Errorf("unexpected errors: %v, %v", rows[0].Err, rows[2].Err)
+	}
+}
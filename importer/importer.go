@@ -0,0 +1,127 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// DefaultConcurrency is the number of domains created in parallel.
+const DefaultConcurrency = 8
+
+// Status is the outcome of importing a row.
+type Status string
+
+const (
+	StatusCreated Status = "created" // the domain was created
+	StatusExists  Status = "exists"  // the domain already existed
+	StatusInvalid Status = "invalid" // the row failed validation
+	StatusFailed  Status = "failed"  // the API returned an error
+	StatusSkipped Status = "skipped" // not imported in a dry run
+)
+
+// Result is the outcome of a single row.
+type Result struct {
+	Line   int
+	Name   string
+	Status Status
+	Id     int // id of the domain, if it exists
+	Err    error
+}
+
+// Importer creates the domains of the read rows.
+type Importer struct {
+	API         *nimbusec.API
+	Concurrency int  // parallel requests, defaults to DefaultConcurrency
+	DryRun      bool // only validate and check which domains exist
+}
+
+// Import creates the domains of all valid rows and returns a result per row
+// in the order of rows. Domains that already exist are not modified. An
+// error is only returned if the existing domains can not be listed; errors
+// of single rows are reported in their result.
+func (im *Importer) Import(ctx context.Context, rows []Row) ([]Result, error) {
+	domains, err := im.API.FindDomainsContext(ctx, nimbusec.EmptyFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]int, len(domains))
+	for _, d := range domains {
+		existing[strings.ToLower(d.Name)] = d.Id
+	}
+
+	concurrency := im.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	results := make([]Result, len(rows))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, row := range rows {
+		result := Result{Line: row.Line, Name: row.Domain.Name}
+
+		if row.Err != nil {
+			result.Status, result.Err = StatusInvalid, row.Err
+			results[i] = result
+			continue
+		}
+
+		if id, ok := existing[row.Domain.Name]; ok {
+			result.Status, result.Id = StatusExists, id
+			results[i] = result
+			continue
+		}
+
+		if im.DryRun {
+			result.Status = StatusSkipped
+			results[i] = result
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, domain nimbusec.Domain) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			created, err := im.API.CreateOrGetDomainContext(ctx, &domain)
+			if err != nil {
+				result.Status, result.Err = StatusFailed, err
+			} else {
+				result.Status, result.Id = StatusCreated, created.Id
+			}
+			results[i] = result
+		}(i, row.Domain)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// WriteResults writes the results as CSV with the columns line, name,
+// status, id and error.
+func WriteResults(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"line", "name", "status", "id", "error"})
+
+	for _, r := range results {
+		id, msg := "", ""
+		if r.Id != 0 {
+			id = strconv.Itoa(r.Id)
+		}
+		if r.Err != nil {
+			msg = r.Err.Error()
+		}
+		writer.Write([]string{strconv.Itoa(r.Line), r.Name, string(r.Status), id, msg})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,75 @@
+package importer_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/importer"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestImport(t *testing.T) {
+	const input = "name,scheme\nexisting.com,https\nnew.com,https\nbroken.com,ftp\nother.com,http\n"
+
+	tests := []struct {
+		name    string
+		dryRun  bool
+		want    []importer.Status
+		domains int
+	}{
+		{"import", false, []importer.Status{importer.StatusExists, importer.StatusCreated, importer.StatusInvalid, importer.StatusCreated}, 3},
+		{"dry run", true, []importer.Status{importer.StatusExists, importer.StatusSkipped, importer.StatusInvalid, importer.StatusSkipped}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := nimbusectest.NewServer()
+			defer srv.Close()
+			existing := srv.AddDomain(nimbusec.Domain{Name: "existing.com", Scheme: "http"})
+
+			rows, err := importer.ReadCSV(strings.NewReader(input), importer.Options{Bundle: "basic"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			im := &importer.Importer{API: srv.API(), Concurrency: 2, DryRun: tt.dryRun}
+			results, err := im.Import(t.Context(), rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, r := range results {
+				if r.Status != tt.want[i] {
+					t.Errorf("row %d (%s): status = %s, want %s (%v)", r.Line, r.Name, r.Status, tt.want[i], r.Err)
+				}
+			}
+			if results[0].Id != existing.Id {
+				t.Errorf("existing id = %d, want %d", results[0].Id, existing.Id)
+			}
+
+			if n := len(srv.Domains()); n != tt.domains {
+				t.Errorf("account has %d domains, want %d", n, tt.domains)
+			}
+			for _, d := range srv.Domains() {
+				if d.Id == existing.Id && d.Scheme != "http" {
+					t.Errorf("existing domain was modified: %+v", d)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := importer.WriteResults(&buf, results); err != nil {
+				t.Fatal(err)
+			}
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(results)+1 || records[3][2] != string(importer.StatusInvalid) || records[3][4] == "" {
+				t.Errorf("unexpected result file: %q", records)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type urlset struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+// ReadSitemap returns the locations of all pages in a local sitemap.xml
+// file. Sitemap index files are not supported, as their sitemaps would have
+// to be downloaded.
+func ReadSitemap(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var set urlset
+	if err := xml.NewDecoder(file).Decode(&set); err != nil {
+		return nil, fmt.Errorf("sitemap %s: %v", path, err)
+	}
+	if set.XMLName.Local != "urlset" {
+		return nil, fmt.Errorf("sitemap %s: unsupported root element <%s>", path, set.XMLName.Local)
+	}
+
+	locs := make([]string, 0, len(set.URLs))
+	for _, u := range set.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			locs = append(locs, loc)
+		}
+	}
+	return locs, nil
+}
@@ -1,8 +1,8 @@
 package nimbusec
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -19,14 +19,17 @@ const (
 	DefaultAPI = "https://api.nimbusec.com/"
 )
 
-// ErrNotFound is returned by GetXYByName functions if the requested entity can not be found.
-var ErrNotFound = errors.New("not found")
-
 // API represents a client to the nimbusec API.
 type API struct {
-	url    *url.URL
-	client *oauth.Consumer
-	token  *oauth.AccessToken
+	url      *url.URL
+	key      string
+	secret   string
+	token    *oauth.AccessToken
+	client   *http.Client
+	header   http.Header
+	retry    *RetryPolicy
+	limiter  *limiter
+	pageSize int
 }
 
 // Params is an convenience alias for URL query values as used with OAuth.
@@ -34,19 +37,7 @@ type Params map[string]string
 
 // NewAPI creates a new nimbusec API client.
 func NewAPI(rawurl, key, secret string) (*API, error) {
-	client := oauth.NewConsumer(key, secret, oauth.ServiceProvider{})
-	token := &oauth.AccessToken{}
-
-	parsed, err := url.Parse(rawurl)
-	if err != nil {
-		return nil, err
-	}
-
-	return &API{
-		url:    parsed,
-		client: client,
-		token:  token,
-	}, nil
+	return NewAPIWithOptions(rawurl, key, secret)
 }
 
 // BuildURL builds the fully qualified url to the nimbusec API.
@@ -58,28 +49,118 @@ func (a *API) BuildURL(relpath string, args ...interface{}) string {
 	return ""
 }
 
-// try is used to encapsulate a HTTP operation and retrieve the optional
-// nimbusec error if one happened.
+// try is used to encapsulate a HTTP operation and convert every non 2xx
+// response into an *APIError. The response body is consumed and closed in
+// this case.
 func try(resp *http.Response, err error) (*http.Response, error) {
 	if resp == nil {
 		return resp, err
 	}
 
-	if resp.StatusCode < 300 {
+	if err != nil || resp.StatusCode < 300 {
 		return resp, err
 	}
 
-	msg := resp.Header.Get("x-nimbusec-error")
-	if msg != "" {
-		return resp, errors.New(msg)
+	defer resp.Body.Close()
+	return resp, newAPIError(resp)
+}
+
+// do sends a request to the nimbusec API and retries it according to the
+// retry policy of the client. The request is bound to ctx, so cancelling ctx
+// aborts the request as well as any pending retry.
+func (a *API) do(ctx context.Context, method, url string, params Params, contentType string, payload []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := a.send(ctx, method, url, params, contentType, payload)
+
+		wait, ok := a.retry.backoff(ctx, method, attempt, resp, err)
+		if !ok {
+			return resp, err
+		}
+
+		if a.retry.OnRetry != nil {
+			a.retry.OnRetry(RetryAttempt{
+				Attempt: attempt,
+				Method:  method,
+				URL:     url,
+				Err:     err,
+				Wait:    wait,
+			})
+		}
+
+		if ctxErr := sleep(ctx, wait); ctxErr != nil {
+			return resp, fmt.Errorf("%w (retry aborted: %w)", err, ctxErr)
+		}
+	}
+}
+
+// send signs and sends a single request through the OAuth consumer. As the
+// OAuth nonce and timestamp must be unique, every attempt is signed anew.
+func (a *API) send(ctx context.Context, method, url string, params Params, contentType string, payload []byte) (*http.Response, error) {
+	if err := a.limiter.wait(ctx, method); err != nil {
+		return nil, err
 	}
 
+	client := &contextClient{ctx: ctx, client: a.client, header: a.header}
+	consumer := oauth.NewConsumer(a.key, a.secret, oauth.ServiceProvider{})
+	consumer.HttpClient = client
+
+	var resp *http.Response
+	var err error
+	switch method {
+	case "GET":
+		resp, err = consumer.Get(url, params, a.token)
+	case "POST":
+		resp, err = consumer.Post(url, contentType, string(payload), params, a.token)
+	case "PUT":
+		resp, err = consumer.Put(url, contentType, string(payload), params, a.token)
+	case "DELETE":
+		resp, err = consumer.Delete(url, params, a.token)
+	default:
+		return nil, fmt.Errorf("nimbusec: unsupported method %s", method)
+	}
+
+	// the consumer hides transport errors behind its own error values,
+	// therefore the original response and error are returned if the request
+	// was sent at all.
+	if client.sent {
+		return client.resp, client.err
+	}
 	return resp, err
 }
 
+// contextClient sends the requests signed by the OAuth consumer with the
+// http client, default headers and context of the API.
+type contextClient struct {
+	ctx    context.Context
+	client *http.Client
+	header http.Header
+
+	sent bool
+	resp *http.Response
+	err  error
+}
+
+func (c *contextClient) Do(req *http.Request) (*http.Response, error) {
+	req = req.WithContext(c.ctx)
+	for k, values := range c.header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+
+	c.sent = true
+	c.resp, c.err = try(c.client.Do(req))
+	return c.resp, c.err
+}
+
 // Get is a helper for all GET request with json payload.
 func (a *API) Get(url string, params Params, dst interface{}) error {
-	resp, err := try(a.client.Get(url, params, a.token))
+	return a.GetContext(context.Background(), url, params, dst)
+}
+
+// GetContext is like Get but aborts the request once ctx is done.
+func (a *API) GetContext(ctx context.Context, url string, params Params, dst interface{}) error {
+	resp, err := a.do(ctx, "GET", url, params, "", nil)
 	if err != nil {
 		return err
 	}
@@ -103,12 +184,17 @@ func (a *API) Get(url string, params Params, dst interface{}) error {
 
 // Post is a helper for all POST request with json payload.
 func (a *API) Post(url string, params Params, src interface{}, dst interface{}) error {
+	return a.PostContext(context.Background(), url, params, src, dst)
+}
+
+// PostContext is like Post but aborts the request once ctx is done.
+func (a *API) PostContext(ctx context.Context, url string, params Params, src interface{}, dst interface{}) error {
 	payload, err := json.Marshal(src)
 	if err != nil {
 		return err
 	}
 
-	resp, err := try(a.client.Post(url, "application/json", string(payload), params, a.token))
+	resp, err := a.do(ctx, "POST", url, params, "application/json", payload)
 	if err != nil {
 		return err
 	}
@@ -132,12 +218,17 @@ func (a *API) Post(url string, params Params, src interface{}, dst interface{})
 
 // Put is a helper for all PUT request with json payload.
 func (a *API) Put(url string, params Params, src interface{}, dst interface{}) error {
+	return a.PutContext(context.Background(), url, params, src, dst)
+}
+
+// PutContext is like Put but aborts the request once ctx is done.
+func (a *API) PutContext(ctx context.Context, url string, params Params, src interface{}, dst interface{}) error {
 	payload, err := json.Marshal(src)
 	if err != nil {
 		return err
 	}
 
-	resp, err := try(a.client.Put(url, "application/json", string(payload), params, a.token))
+	resp, err := a.do(ctx, "PUT", url, params, "application/json", payload)
 	if err != nil {
 		return err
 	}
@@ -161,14 +252,23 @@ func (a *API) Put(url string, params Params, src interface{}, dst interface{}) e
 
 // Delete is a helper for all DELETE request with json payload.
 func (a *API) Delete(url string, params Params) error {
-	resp, err := a.client.Delete(url, params, a.token)
+	return a.DeleteContext(context.Background(), url, params)
+}
+
+// DeleteContext is like Delete but aborts the request once ctx is done.
+func (a *API) DeleteContext(ctx context.Context, url string, params Params) error {
+	resp, err := a.do(ctx, "DELETE", url, params, "", nil)
+	if err != nil {
+		return err
+	}
+
 	resp.Body.Close()
-	return err
+	return nil
 }
 
 // getTextPlain is a helper for all GET request with plain text payload.
-func (a *API) getTextPlain(url string, params Params) (string, error) {
-	data, err := a.getBytes(url, params)
+func (a *API) getTextPlain(ctx context.Context, url string, params Params) (string, error) {
+	data, err := a.getBytes(ctx, url, params)
 	if err != nil {
 		return "", err
 	}
@@ -177,8 +277,8 @@ func (a *API) getTextPlain(url string, params Params) (string, error) {
 }
 
 // putTextPlain is a helper for all PUT request with plain text payload.
-func (a *API) putTextPlain(url string, params Params, payload string) (string, error) {
-	resp, err := try(a.client.Put(url, "text/plain", string(payload), params, a.token))
+func (a *API) putTextPlain(ctx context.Context, url string, params Params, payload string) (string, error) {
+	resp, err := a.do(ctx, "PUT", url, params, "text/plain", []byte(payload))
 	if err != nil {
 		return "", err
 	}
@@ -194,8 +294,8 @@ func (a *API) putTextPlain(url string, params Params, payload string) (string, e
 }
 
 // getBytes is a helper for all GET request with raw byte payload.
-func (a *API) getBytes(url string, params Params) ([]byte, error) {
-	resp, err := try(a.client.Get(url, params, a.token))
+func (a *API) getBytes(ctx context.Context, url string, params Params) ([]byte, error) {
+	resp, err := a.do(ctx, "GET", url, params, "", nil)
 	if err != nil {
 		return nil, err
 	}
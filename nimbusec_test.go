@@ -0,0 +1,98 @@
+package nimbusec_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestDeleteNotFound(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	err := srv.API().DeleteDomain(&nimbusec.Domain{Id: 9999}, false)
+	if !nimbusec.IsNotFound(err) {
+		t.Fatalf("error = %v, want not found", err)
+	}
+
+	var apiErr *nimbusec.APIError
+	if !errors.As(err, &apiErr) || apiErr.Method != "DELETE" {
+		t.Errorf("error = %#v, want an APIError of the DELETE", err)
+	}
+}
+
+func TestDeleteServerError(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int // responses with 503 before the delete succeeds
+		calls    int
+		err      bool
+	}{
+		{"retried", 1, 2, false},
+		{"exhausted", 5, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			api, err := nimbusec.NewAPIWithOptions(srv.URL, "key", "secret", nimbusec.WithRetryPolicy(&nimbusec.RetryPolicy{
+				MaxAttempts: 3,
+				MinBackoff:  time.Millisecond,
+				MaxBackoff:  time.Millisecond,
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = api.DeleteDomain(&nimbusec.Domain{Id: 1}, false)
+			if calls != tt.calls {
+				t.Errorf("calls = %d, want %d", calls, tt.calls)
+			}
+			if !tt.err {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+
+			var apiErr *nimbusec.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("error = %v, want an APIError with status 503", err)
+			}
+		})
+	}
+}
+
+func TestDownloadAgent(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	agent := nimbusec.Agent{OS: "linux", Arch: "64bit", Version: 14, Format: "bin"}
+	srv.AddAgent(agent, []byte("binary"))
+	api := srv.API()
+
+	data, err := api.DownloadAgent(agent)
+	if err != nil || string(data) != "binary" {
+		t.Errorf("download = %q, %v, want binary", data, err)
+	}
+
+	agent.Version = 13
+	if data, err := api.DownloadAgent(agent); !nimbusec.IsNotFound(err) || len(data) != 0 {
+		t.Errorf("unknown agent: download = %q, %v, want not found", data, err)
+	}
+}
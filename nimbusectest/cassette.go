@@ -0,0 +1,327 @@
+package nimbusectest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a Recorder records or replays interactions.
+type Mode int
+
+const (
+	// ModeReplay serves responses from the cassette and never touches the
+	// network. Requests without a recorded interaction fail.
+	ModeReplay Mode = iota
+
+	// ModeRecord sends all requests to the real API and records them.
+	ModeRecord
+
+	// ModeAuto replays if the cassette file exists and records otherwise.
+	ModeAuto
+)
+
+// scrubbed replaces sensitive values in recorded interactions.
+const scrubbed = "[scrubbed]"
+
+// sensitiveFields are json fields whose values are scrubbed from recorded
+// request and response bodies.
+var sensitiveFields = map[string]bool{
+	"key":          true,
+	"secret":       true,
+	"password":     true,
+	"signatureKey": true,
+}
+
+// sensitiveHeaders are headers whose values are scrubbed from recordings.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// Cassette is the file format of recorded interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request/response pair.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the scrubbed request of an interaction.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"` // path and query, without oauth parameters
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the scrubbed response of an interaction.
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is a http.RoundTripper that records interactions with the nimbusec
+// API into a cassette file or replays them from it. Use it with
+// nimbusec.WithTransport:
+//
+//	rec, err := nimbusectest.NewRecorder("testdata/domains.json", nimbusectest.ModeAuto, nil)
+//	api, err := nimbusec.NewAPIWithOptions(url, key, secret, nimbusec.WithTransport(rec))
+//	defer rec.Close()
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder creates a recorder for the cassette at path. The transport is
+// used to send requests when recording; nil means http.DefaultTransport.
+func NewRecorder(path string, mode Mode, transport http.RoundTripper) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if mode == ModeAuto {
+		mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			mode = ModeReplay
+		}
+	}
+
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: transport,
+	}
+
+	if mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("nimbusectest: invalid cassette %s: %v", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+
+	return r, nil
+}
+
+// Mode returns whether the recorder records or replays.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModeReplay {
+		return r.replay(req)
+	}
+	return r.record(req)
+}
+
+// Close writes the cassette file when recording.
+func (r *Recorder) Close() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0644)
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    normalizeURL(req.URL),
+			Header: scrubHeader(req.Header),
+			Body:   scrubBody(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     scrubHeader(resp.Header),
+			Body:       scrubBody(respBody),
+		},
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay serves the first unused interaction matching method, path and
+// normalized query of the request.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	key := normalizeURL(req.URL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != key {
+			continue
+		}
+
+		r.used[i] = true
+		recorded := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Header.Clone(),
+			Body:          ioutil.NopCloser(strings.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("nimbusectest: no recorded interaction for %s %s in %s", req.Method, key, r.path)
+}
+
+// ErrUnusedInteractions is returned by Unused if not all recorded interactions
+// were replayed.
+var ErrUnusedInteractions = errors.New("nimbusectest: unused interactions in cassette")
+
+// Unused reports an error if some recorded interactions have not been
+// replayed, which usually means the code under test changed its behaviour.
+func (r *Recorder) Unused() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []string
+	for i, used := range r.used {
+		if !used {
+			req := r.cassette.Interactions[i].Request
+			unused = append(unused, req.Method+" "+req.URL)
+		}
+	}
+
+	if len(unused) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnusedInteractions, strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// normalizeURL returns path and sorted query of u without oauth parameters.
+func normalizeURL(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if !strings.HasPrefix(k, "oauth_") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	if len(parts) == 0 {
+		return u.EscapedPath()
+	}
+	return u.EscapedPath() + "?" + strings.Join(parts, "&")
+}
+
+func scrubHeader(header http.Header) http.Header {
+	scrubbedHeader := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if scrubbedHeader.Get(name) != "" {
+			scrubbedHeader.Set(name, scrubbed)
+		}
+	}
+
+	// bodies may change by scrubbing, so the recorded length is meaningless.
+	scrubbedHeader.Del("Content-Length")
+	return scrubbedHeader
+}
+
+// scrubBody replaces the values of sensitive fields in json bodies. Other
+// bodies are kept as they are.
+func scrubBody(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+
+	data, err := json.Marshal(scrubValue(v))
+	if err != nil {
+		return string(body)
+	}
+	return string(data)
+}
+
+func scrubValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, value := range x {
+			if sensitiveFields[k] {
+				x[k] = scrubbed
+				continue
+			}
+			x[k] = scrubValue(value)
+		}
+	case []interface{}:
+		for i, value := range x {
+			x[i] = scrubValue(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,86 @@
+package nimbusectest_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestRecorder(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	srv.AddDomain(nimbusec.Domain{Name: "example.com", Scheme: "https"})
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	// record against the fake server.
+	rec, err := nimbusectest.NewRecorder(path, nimbusectest.ModeAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != nimbusectest.ModeRecord {
+		t.Fatalf("mode = %v, want ModeRecord for a missing cassette", rec.Mode())
+	}
+
+	api := srv.API(nimbusec.WithTransport(rec))
+	if _, err := api.FindDomains(`name eq "example.com"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.CreateToken(&nimbusec.Token{Name: "agent", Key: "k", Secret: "very-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leaked := range []string{"very-secret", srv.Secret, "oauth_signature"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("cassette contains %q", leaked)
+		}
+	}
+
+	// replay after the server is gone.
+	srv.Close()
+
+	rec, err = nimbusectest.NewRecorder(path, nimbusectest.ModeAuto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != nimbusectest.ModeReplay {
+		t.Fatalf("mode = %v, want ModeReplay for an existing cassette", rec.Mode())
+	}
+
+	api, err = nimbusec.NewAPIWithOptions(srv.URL, "other-key", "other-secret", nimbusec.WithTransport(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rec.Unused(); !errors.Is(err, nimbusectest.ErrUnusedInteractions) {
+		t.Errorf("Unused before replay = %v, want ErrUnusedInteractions", err)
+	}
+
+	domains, err := api.FindDomains(`name eq "example.com"`)
+	if err != nil || len(domains) != 1 || domains[0].Name != "example.com" {
+		t.Fatalf("replayed FindDomains = %v, %v", domains, err)
+	}
+
+	if _, err := api.FindDomains(`name eq "other.com"`); err == nil {
+		t.Error("FindDomains without recorded interaction succeeded")
+	}
+
+	if _, err := api.CreateToken(&nimbusec.Token{Name: "agent"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Unused(); err != nil {
+		t.Errorf("Unused after replay = %v", err)
+	}
+}
@@ -0,0 +1,802 @@
+package nimbusectest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+func (s *Server) createDomain(w http.ResponseWriter, r *http.Request) {
+	var domain nimbusec.Domain
+	if !readJSON(w, r, &domain) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, existing := range s.domains {
+		if existing.Name != domain.Name {
+			continue
+		}
+
+		switch upsert(r) {
+		case "true":
+			domain.Id = id
+			s.domains[id] = domain
+			writeJSON(w, domain)
+		case "false":
+			writeJSON(w, existing)
+		default:
+			writeError(w, http.StatusConflict, fmt.Sprintf("domain %q already exists", domain.Name))
+		}
+		return
+	}
+
+	domain.Id = s.id()
+	s.domains[domain.Id] = domain
+	writeJSON(w, domain)
+}
+
+func (s *Server) findDomains(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	domains := sortedValues(s.domains)
+	s.mu.Unlock()
+
+	writeFiltered(w, r, domains)
+}
+
+// domain looks up the domain of the request path and reports a 404 error if
+// it does not exist. The caller must hold s.mu.
+func (s *Server) domain(w http.ResponseWriter, r *http.Request) (nimbusec.Domain, bool) {
+	id, ok := pathID(w, r, "domain")
+	if !ok {
+		return nimbusec.Domain{}, false
+	}
+
+	domain, ok := s.domains[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("domain %d not found", id))
+	}
+	return domain, ok
+}
+
+func (s *Server) getDomain(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if domain, ok := s.domain(w, r); ok {
+		writeJSON(w, domain)
+	}
+}
+
+func (s *Server) updateDomain(w http.ResponseWriter, r *http.Request) {
+	var update nimbusec.Domain
+	if !readJSON(w, r, &update) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	domain, ok := s.domain(w, r)
+	if !ok {
+		return
+	}
+
+	update.Id = domain.Id
+	s.domains[domain.Id] = update
+	writeJSON(w, update)
+}
+
+func (s *Server) deleteDomain(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	domain, ok := s.domain(w, r)
+	if !ok {
+		return
+	}
+
+	delete(s.domains, domain.Id)
+	delete(s.results, domain.Id)
+	delete(s.metadata, domain.Id)
+	delete(s.applications, domain.Id)
+	delete(s.events, domain.Id)
+	delete(s.domainConfigs, domain.Id)
+}
+
+func (s *Server) findInfected(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	infected := make([]nimbusec.Domain, 0)
+	for _, domain := range sortedValues(s.domains) {
+		for _, result := range s.results[domain.Id] {
+			if result.IsPending() {
+				infected = append(infected, domain)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	writeFiltered(w, r, infected)
+}
+
+func (s *Server) listDomainConfigs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if domain, ok := s.domain(w, r); ok {
+		writeJSON(w, sortedKeys(s.domainConfigs[domain.Id]))
+	}
+}
+
+func (s *Server) getDomainConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if domain, ok := s.domain(w, r); ok {
+		getConfig(w, r, s.domainConfigs[domain.Id])
+	}
+}
+
+func (s *Server) setDomainConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	domain, ok := s.domain(w, r)
+	if !ok {
+		return
+	}
+
+	if s.domainConfigs[domain.Id] == nil {
+		s.domainConfigs[domain.Id] = map[string]string{}
+	}
+	setConfig(w, r, s.domainConfigs[domain.Id])
+}
+
+func (s *Server) deleteDomainConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if domain, ok := s.domain(w, r); ok {
+		deleteConfig(w, r, s.domainConfigs[domain.Id])
+	}
+}
+
+func (s *Server) getDomainEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	domain, ok := s.domain(w, r)
+	events := append([]nimbusec.DomainEvent(nil), s.events[domain.Id]...)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	expr, ok := query(w, r)
+	if !ok {
+		return
+	}
+
+	// newest events first, as returned by the nimbusec API.
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.After(events[j].Time.Time) })
+
+	matched := make([]nimbusec.DomainEvent, 0)
+	for _, event := range events {
+		ok, err := expr.Match(event)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if ok {
+			matched = append(matched, event)
+		}
+	}
+
+	writeJSON(w, paginate(r, matched))
+}
+
+func (s *Server) createDomainEvent(w http.ResponseWriter, r *http.Request) {
+	var event nimbusec.DomainEvent
+	if !readJSON(w, r, &event) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if domain, ok := s.domain(w, r); ok {
+		s.events[domain.Id] = append(s.events[domain.Id], event)
+	}
+}
+
+func (s *Server) getDomainMetadata(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if domain, ok := s.domain(w, r); ok {
+		writeJSON(w, s.metadata[domain.Id])
+	}
+}
+
+func (s *Server) getDomainApplications(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if domain, ok := s.domain(w, r); ok {
+		apps := append(make([]nimbusec.DomainApplication, 0), s.applications[domain.Id]...)
+		writeJSON(w, apps)
+	}
+}
+
+func (s *Server) getScreenshot(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	domain, ok := s.domain(w, r)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%d/%s/%s", domain.Id, r.PathValue("region"), r.PathValue("viewport"))
+	screenshot, ok := s.screenshots[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "screenshot not found")
+		return
+	}
+	writeJSON(w, screenshot)
+}
+
+func (s *Server) getImage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, ok := s.images[r.URL.Path]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s not found", r.URL.Path))
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Server) getIssues(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, append(make([]nimbusec.DomainIssues, 0), s.issues...))
+}
+
+func (s *Server) findResults(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	domain, ok := s.domain(w, r)
+	results := sortedValues(s.results[domain.Id])
+	s.mu.Unlock()
+
+	if ok {
+		writeFiltered(w, r, results)
+	}
+}
+
+// result looks up the result of the request path and reports a 404 error if
+// it does not exist. The caller must hold s.mu.
+func (s *Server) result(w http.ResponseWriter, r *http.Request) (int, nimbusec.Result, bool) {
+	domain, ok := s.domain(w, r)
+	if !ok {
+		return 0, nimbusec.Result{}, false
+	}
+
+	id, ok := pathID(w, r, "result")
+	if !ok {
+		return 0, nimbusec.Result{}, false
+	}
+
+	result, ok := s.results[domain.Id][id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("result %d not found", id))
+	}
+	return domain.Id, result, ok
+}
+
+func (s *Server) getResult(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, result, ok := s.result(w, r); ok {
+		writeJSON(w, result)
+	}
+}
+
+func (s *Server) updateResult(w http.ResponseWriter, r *http.Request) {
+	var update nimbusec.Result
+	if !readJSON(w, r, &update) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	domain, result, ok := s.result(w, r)
+	if !ok {
+		return
+	}
+
+	// all fields except status are ignored.
+	result.Status = update.Status
+	s.results[domain][result.Id] = result
+	writeJSON(w, result)
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var user nimbusec.User
+	if !readJSON(w, r, &user) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, existing := range s.users {
+		if existing.Login != user.Login {
+			continue
+		}
+
+		switch upsert(r) {
+		case "true":
+			user.Id = id
+			s.users[id] = user
+			writeJSON(w, hidePassword(user))
+		case "false":
+			writeJSON(w, hidePassword(existing))
+		default:
+			writeError(w, http.StatusConflict, fmt.Sprintf("user %q already exists", user.Login))
+		}
+		return
+	}
+
+	user.Id = s.id()
+	s.users[user.Id] = user
+	writeJSON(w, hidePassword(user))
+}
+
+// hidePassword removes the secrets of the user, which are never returned by
+// the nimbusec API.
+func hidePassword(user nimbusec.User) nimbusec.User {
+	user.Password = ""
+	user.SignatureKey = ""
+	return user
+}
+
+func (s *Server) findUsers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	users := sortedValues(s.users)
+	s.mu.Unlock()
+
+	for i := range users {
+		users[i] = hidePassword(users[i])
+	}
+	writeFiltered(w, r, users)
+}
+
+// user looks up the user of the request path and reports a 404 error if it
+// does not exist. The caller must hold s.mu.
+func (s *Server) user(w http.ResponseWriter, r *http.Request) (nimbusec.User, bool) {
+	id, ok := pathID(w, r, "user")
+	if !ok {
+		return nimbusec.User{}, false
+	}
+
+	user, ok := s.users[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("user %d not found", id))
+	}
+	return user, ok
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.user(w, r); ok {
+		writeJSON(w, hidePassword(user))
+	}
+}
+
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request) {
+	var update nimbusec.User
+	if !readJSON(w, r, &update) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	update.Id = user.Id
+	s.users[user.Id] = update
+	writeJSON(w, hidePassword(update))
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	delete(s.users, user.Id)
+	delete(s.userDomains, user.Id)
+	delete(s.userConfigs, user.Id)
+	delete(s.notifications, user.Id)
+}
+
+func (s *Server) getDomainSet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.user(w, r); ok {
+		writeJSON(w, append(make([]int, 0), s.userDomains[user.Id]...))
+	}
+}
+
+func (s *Server) updateDomainSet(w http.ResponseWriter, r *http.Request) {
+	var domains []int
+	if !readJSON(w, r, &domains) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	for _, id := range domains {
+		if _, ok := s.domains[id]; !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("domain %d not found", id))
+			return
+		}
+	}
+
+	s.userDomains[user.Id] = append(make([]int, 0), domains...)
+	writeJSON(w, s.userDomains[user.Id])
+}
+
+func (s *Server) linkDomain(w http.ResponseWriter, r *http.Request) {
+	var domain int
+	if !readJSON(w, r, &domain) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := s.domains[domain]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("domain %d not found", domain))
+		return
+	}
+
+	for _, id := range s.userDomains[user.Id] {
+		if id == domain {
+			return
+		}
+	}
+	s.userDomains[user.Id] = append(s.userDomains[user.Id], domain)
+}
+
+func (s *Server) unlinkDomain(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	domain, ok := pathID(w, r, "domain")
+	if !ok {
+		return
+	}
+
+	linked := s.userDomains[user.Id][:0]
+	for _, id := range s.userDomains[user.Id] {
+		if id != domain {
+			linked = append(linked, id)
+		}
+	}
+	s.userDomains[user.Id] = linked
+}
+
+func (s *Server) listUserConfigs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.user(w, r); ok {
+		writeJSON(w, sortedKeys(s.userConfigs[user.Id]))
+	}
+}
+
+func (s *Server) getUserConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.user(w, r); ok {
+		getConfig(w, r, s.userConfigs[user.Id])
+	}
+}
+
+func (s *Server) setUserConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	if s.userConfigs[user.Id] == nil {
+		s.userConfigs[user.Id] = map[string]string{}
+	}
+	setConfig(w, r, s.userConfigs[user.Id])
+}
+
+func (s *Server) deleteUserConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.user(w, r); ok {
+		deleteConfig(w, r, s.userConfigs[user.Id])
+	}
+}
+
+func (s *Server) createNotification(w http.ResponseWriter, r *http.Request) {
+	var notification nimbusec.Notification
+	if !readJSON(w, r, &notification) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.user(w, r)
+	if !ok {
+		return
+	}
+
+	if s.notifications[user.Id] == nil {
+		s.notifications[user.Id] = map[int]nimbusec.Notification{}
+	}
+
+	for id, existing := range s.notifications[user.Id] {
+		if existing.Domain != notification.Domain || existing.Transport != notification.Transport {
+			continue
+		}
+
+		switch upsert(r) {
+		case "true":
+			notification.Id = id
+			s.notifications[user.Id][id] = notification
+			writeJSON(w, notification)
+		case "false":
+			writeJSON(w, existing)
+		default:
+			writeError(w, http.StatusConflict, "notification already exists")
+		}
+		return
+	}
+
+	notification.Id = s.id()
+	s.notifications[user.Id][notification.Id] = notification
+	writeJSON(w, notification)
+}
+
+func (s *Server) findNotifications(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	user, ok := s.user(w, r)
+	notifications := sortedValues(s.notifications[user.Id])
+	s.mu.Unlock()
+
+	if ok {
+		writeFiltered(w, r, notifications)
+	}
+}
+
+// notification looks up the notification of the request path and reports a
+// 404 error if it does not exist. The caller must hold s.mu.
+func (s *Server) notification(w http.ResponseWriter, r *http.Request) (int, nimbusec.Notification, bool) {
+	user, ok := s.user(w, r)
+	if !ok {
+		return 0, nimbusec.Notification{}, false
+	}
+
+	id, ok := pathID(w, r, "notification")
+	if !ok {
+		return 0, nimbusec.Notification{}, false
+	}
+
+	notification, ok := s.notifications[user.Id][id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("notification %d not found", id))
+	}
+	return user.Id, notification, ok
+}
+
+func (s *Server) getNotification(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, notification, ok := s.notification(w, r); ok {
+		writeJSON(w, notification)
+	}
+}
+
+func (s *Server) updateNotification(w http.ResponseWriter, r *http.Request) {
+	var update nimbusec.Notification
+	if !readJSON(w, r, &update) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, notification, ok := s.notification(w, r)
+	if !ok {
+		return
+	}
+
+	update.Id = notification.Id
+	s.notifications[user][notification.Id] = update
+	writeJSON(w, update)
+}
+
+func (s *Server) deleteNotification(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, notification, ok := s.notification(w, r); ok {
+		delete(s.notifications[user], notification.Id)
+	}
+}
+
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request) {
+	var token nimbusec.Token
+	if !readJSON(w, r, &token) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.Id = s.id()
+	if token.Key == "" {
+		token.Key = fmt.Sprintf("token-key-%d", token.Id)
+	}
+	if token.Secret == "" {
+		token.Secret = fmt.Sprintf("token-secret-%d", token.Id)
+	}
+	s.tokens[token.Id] = token
+	writeJSON(w, token)
+}
+
+func (s *Server) findTokens(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	tokens := sortedValues(s.tokens)
+	s.mu.Unlock()
+
+	writeFiltered(w, r, tokens)
+}
+
+func (s *Server) getToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "token")
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("token %d not found", id))
+		return
+	}
+	writeJSON(w, token)
+}
+
+func (s *Server) findAgents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	agents := append(make([]nimbusec.Agent, 0), s.agents...)
+	s.mu.Unlock()
+
+	writeFiltered(w, r, agents)
+}
+
+func (s *Server) downloadAgent(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, ok := s.agentFiles[r.PathValue("file")]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Server) findBundles(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	bundles := make([]nimbusec.Bundle, 0, len(s.bundles))
+	for _, bundle := range s.bundles {
+		bundles = append(bundles, bundle)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i].Id < bundles[j].Id })
+	writeFiltered(w, r, bundles)
+}
+
+func (s *Server) getBundle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bundle, ok := s.bundles[r.PathValue("bundle")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("bundle %s not found", r.PathValue("bundle")))
+		return
+	}
+	writeJSON(w, bundle)
+}
+
+func getConfig(w http.ResponseWriter, r *http.Request, configs map[string]string) {
+	value, ok := configs[r.PathValue("key")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("config %s not found", r.PathValue("key")))
+		return
+	}
+	writeText(w, value)
+}
+
+func setConfig(w http.ResponseWriter, r *http.Request, configs map[string]string) {
+	value, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	configs[r.PathValue("key")] = string(value)
+	writeText(w, string(value))
+}
+
+func deleteConfig(w http.ResponseWriter, r *http.Request, configs map[string]string) {
+	if _, ok := configs[r.PathValue("key")]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("config %s not found", r.PathValue("key")))
+		return
+	}
+	delete(configs, r.PathValue("key"))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,180 @@
+package nimbusectest
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxClockSkew is the maximum accepted difference between the OAuth timestamp
+// of a request and the time of the server.
+const maxClockSkew = 5 * time.Minute
+
+// verify checks the OAuth 1.0a signature of the request as described in
+// RFC 5849, section 3.4. The implementation is tested against the test
+// vectors of the specification rather than the client, so that signing
+// errors of the client are detected.
+func (s *Server) verify(r *http.Request) error {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "OAuth ") {
+		return errors.New("missing OAuth authorization")
+	}
+
+	params, err := parseAuthorization(header)
+	if err != nil {
+		return err
+	}
+
+	if params["oauth_consumer_key"] != s.Key {
+		return errors.New("unknown consumer key")
+	}
+
+	if params["oauth_signature_method"] != "HMAC-SHA1" {
+		return errors.New("unsupported signature method")
+	}
+
+	ts, err := strconv.ParseInt(params["oauth_timestamp"], 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return errors.New("timestamp out of range")
+	}
+
+	nonce := params["oauth_nonce"]
+	if nonce == "" {
+		return errors.New("missing nonce")
+	}
+
+	signature := params["oauth_signature"]
+	delete(params, "oauth_signature")
+	delete(params, "realm")
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	values := r.URL.Query()
+	for k, v := range params {
+		values.Add(k, v)
+	}
+
+	// form encoded bodies are part of the signature, other bodies are not.
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		for k, v := range r.PostForm {
+			values[k] = append(values[k], v...)
+		}
+	}
+
+	base := signatureBase(r.Method, scheme+"://"+r.Host+r.URL.EscapedPath(), values)
+	expected := sign(base, s.Secret, "")
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return errors.New("invalid signature")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nonces[nonce] {
+		return errors.New("nonce already used")
+	}
+	s.nonces[nonce] = true
+
+	return nil
+}
+
+// parseAuthorization returns the parameters of an OAuth Authorization header.
+func parseAuthorization(header string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, "OAuth "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed OAuth parameter %q", part)
+		}
+
+		key, err := url.PathUnescape(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := url.PathUnescape(strings.Trim(kv[1], "\""))
+		if err != nil {
+			return nil, err
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+// signatureBase builds the signature base string of RFC 5849, section 3.4.1,
+// from the request method, the base string URI and all request parameters.
+func signatureBase(method, uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err == nil {
+		host := strings.ToLower(u.Host)
+		if (u.Scheme == "http" && strings.HasSuffix(host, ":80")) ||
+			(u.Scheme == "https" && strings.HasSuffix(host, ":443")) {
+			host = host[:strings.LastIndex(host, ":")]
+		}
+		uri = strings.ToLower(u.Scheme) + "://" + host + u.EscapedPath()
+	}
+
+	// parameters are sorted by their encoded name and, if names are equal,
+	// by their encoded value (section 3.4.1.3.2).
+	type pair struct{ key, value string }
+	pairs := make([]pair, 0, len(params))
+	for k, values := range params {
+		for _, v := range values {
+			pairs = append(pairs, pair{percentEncode(k), percentEncode(v)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].key != pairs[j].key {
+			return pairs[i].key < pairs[j].key
+		}
+		return pairs[i].value < pairs[j].value
+	})
+
+	normalized := make([]string, len(pairs))
+	for i, p := range pairs {
+		normalized[i] = p.key + "=" + p.value
+	}
+
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		percentEncode(uri),
+		percentEncode(strings.Join(normalized, "&")),
+	}, "&")
+}
+
+// sign computes the HMAC-SHA1 signature of the base string (section 3.4.2).
+func sign(base, consumerSecret, tokenSecret string) string {
+	mac := hmac.New(sha1.New, []byte(percentEncode(consumerSecret)+"&"+percentEncode(tokenSecret)))
+	mac.Write([]byte(base))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// percentEncode encodes s as required by the OAuth specification.
+func percentEncode(s string) string {
+	var buf strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,106 @@
+package nimbusectest
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSignatureBase(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		uri    string
+		params url.Values
+		want   string
+	}{
+		{
+			// RFC 5849, section 3.4.1.1; a3 is sorted by value and c%40
+			// before c2, which a plain sort of "key=value" strings gets wrong.
+			name:   "rfc 5849",
+			method: "POST",
+			uri:    "http://example.com/request",
+			params: url.Values{
+				"b5":                     {"=%3D"},
+				"a3":                     {"a", "2 q"},
+				"c@":                     {""},
+				"a2":                     {"r b"},
+				"oauth_consumer_key":     {"9djdj82h48djs9d2"},
+				"oauth_token":            {"kkk9d7dh3k39sjv7"},
+				"oauth_signature_method": {"HMAC-SHA1"},
+				"oauth_timestamp":        {"137131201"},
+				"oauth_nonce":            {"7d8f3e4a"},
+				"c2":                     {""},
+			},
+			want: "POST&http%3A%2F%2Fexample.com%2Frequest&a2%3Dr%2520b%26a3%3D2%2520q" +
+				"%26a3%3Da%26b5%3D%253D%25253D%26c%2540%3D%26c2%3D%26oauth_consumer_" +
+				"key%3D9djdj82h48djs9d2%26oauth_nonce%3D7d8f3e4a%26oauth_signature_m" +
+				"ethod%3DHMAC-SHA1%26oauth_timestamp%3D137131201%26oauth_token%3Dkkk" +
+				"9d7dh3k39sjv7",
+		},
+		{
+			// RFC 5849, section 3.4.1.2: default ports are removed, the host
+			// is lower cased.
+			name:   "base string uri",
+			method: "get",
+			uri:    "HTTP://EXAMPLE.COM:80/r%20v/X",
+			params: url.Values{},
+			want:   "GET&http%3A%2F%2Fexample.com%2Fr%2520v%2FX&",
+		},
+		{
+			name:   "non default port",
+			method: "GET",
+			uri:    "https://www.example.net:8080/?q=1",
+			params: url.Values{"q": {"1"}},
+			want:   "GET&https%3A%2F%2Fwww.example.net%3A8080%2F&q%3D1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signatureBase(tt.method, tt.uri, tt.params); got != tt.want {
+				t.Errorf("signatureBase =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// OAuth Core 1.0, appendix A.5.
+	params := url.Values{
+		"file":                   {"vacation.jpg"},
+		"size":                   {"original"},
+		"oauth_consumer_key":     {"dpf43f3p2l4k3l03"},
+		"oauth_token":            {"nnch734d00sl2jdk"},
+		"oauth_signature_method": {"HMAC-SHA1"},
+		"oauth_timestamp":        {"1191242096"},
+		"oauth_nonce":            {"kllo9940pd9333jh"},
+		"oauth_version":          {"1.0"},
+	}
+
+	base := signatureBase("GET", "http://photos.example.net/photos", params)
+	if got, want := sign(base, "kd94hf93k423kf44", "pfkkdhi9sl3r4s00"), "tR3+Ty81lMeYAr/Fid0kMTYa/WM="; got != want {
+		t.Errorf("sign = %s, want %s", got, want)
+	}
+}
+
+func TestParseAuthorization(t *testing.T) {
+	params, err := parseAuthorization(`OAuth realm="Example", oauth_consumer_key="9djdj82h48djs9d2", oauth_signature="bYT5CMsGcbgUdFHObYMEfcx6bsw%3D"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"realm":              "Example",
+		"oauth_consumer_key": "9djdj82h48djs9d2",
+		"oauth_signature":    "bYT5CMsGcbgUdFHObYMEfcx6bsw=",
+	}
+	for k, v := range want {
+		if params[k] != v {
+			t.Errorf("%s = %q, want %q", k, params[k], v)
+		}
+	}
+
+	if _, err := parseAuthorization(`OAuth oauth_consumer_key`); err == nil {
+		t.Error("parseAuthorization succeeded for malformed header")
+	}
+}
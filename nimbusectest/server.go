@@ -0,0 +1,494 @@
+// Package nimbusectest provides an in-process fake of the nimbusec API for
+// hermetic tests of code built on nimbusec.API.
+//
+//	srv := nimbusectest.NewServer()
+//	defer srv.Close()
+//
+//	srv.AddDomain(nimbusec.Domain{Name: "example.com", Scheme: "https"})
+//	api := srv.API()
+//	domains, err := api.FindDomains(`name eq "example.com"`)
+//
+// The fake keeps all entities in memory, honors the upsert and q parameters,
+// reports errors with the x-nimbusec-error header and verifies the OAuth
+// signature of every request.
+//
+// Recorder complements the fake: it records interactions with the real API
+// into cassette files once and replays them offline afterwards.
+package nimbusectest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/filter"
+)
+
+// Server is a fake nimbusec API server.
+type Server struct {
+	*httptest.Server
+
+	Key    string // OAuth consumer key accepted by the server
+	Secret string // OAuth consumer secret accepted by the server
+
+	// IgnoreLimit and IgnoreOffset make the server ignore the paging
+	// parameters, like endpoints of the nimbusec API without paging support.
+	IgnoreLimit  bool
+	IgnoreOffset bool
+
+	mu            sync.Mutex
+	nextID        int
+	nonces        map[string]bool
+	domains       map[int]nimbusec.Domain
+	results       map[int]map[int]nimbusec.Result
+	metadata      map[int]nimbusec.DomainMetadata
+	applications  map[int][]nimbusec.DomainApplication
+	events        map[int][]nimbusec.DomainEvent
+	screenshots   map[string]nimbusec.Screenshot
+	images        map[string][]byte
+	issues        []nimbusec.DomainIssues
+	domainConfigs map[int]map[string]string
+	users         map[int]nimbusec.User
+	userDomains   map[int][]int
+	userConfigs   map[int]map[string]string
+	notifications map[int]map[int]nimbusec.Notification
+	tokens        map[int]nimbusec.Token
+	bundles       map[string]nimbusec.Bundle
+	agents        []nimbusec.Agent
+	agentFiles    map[string][]byte
+
+	failStatus  int
+	failMessage string
+}
+
+// NewServer starts a new fake server with fixed test credentials. The caller
+// must call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		Key:           "nimbusectest-key",
+		Secret:        "nimbusectest-secret",
+		nonces:        map[string]bool{},
+		domains:       map[int]nimbusec.Domain{},
+		results:       map[int]map[int]nimbusec.Result{},
+		metadata:      map[int]nimbusec.DomainMetadata{},
+		applications:  map[int][]nimbusec.DomainApplication{},
+		events:        map[int][]nimbusec.DomainEvent{},
+		screenshots:   map[string]nimbusec.Screenshot{},
+		images:        map[string][]byte{},
+		domainConfigs: map[int]map[string]string{},
+		users:         map[int]nimbusec.User{},
+		userDomains:   map[int][]int{},
+		userConfigs:   map[int]map[string]string{},
+		notifications: map[int]map[int]nimbusec.Notification{},
+		tokens:        map[int]nimbusec.Token{},
+		bundles:       map[string]nimbusec.Bundle{},
+		agentFiles:    map[string][]byte{},
+	}
+
+	s.Server = httptest.NewServer(s.handler())
+	return s
+}
+
+// API returns a client configured for the fake server.
+func (s *Server) API(opts ...nimbusec.Option) *nimbusec.API {
+	api, err := nimbusec.NewAPIWithOptions(s.URL, s.Key, s.Secret, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("nimbusectest: failed to create client: %v", err))
+	}
+	return api
+}
+
+// id returns the next free id. The caller must hold s.mu.
+func (s *Server) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+// AddDomain stores the domain and returns it with its assigned id.
+func (s *Server) AddDomain(domain nimbusec.Domain) nimbusec.Domain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if domain.Id == 0 {
+		domain.Id = s.id()
+	}
+	s.domains[domain.Id] = domain
+	return domain
+}
+
+// Domains returns all stored domains ordered by id.
+func (s *Server) Domains() []nimbusec.Domain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.domains)
+}
+
+// AddResult stores a result for the given domain and returns it with its
+// assigned id.
+func (s *Server) AddResult(domain int, result nimbusec.Result) nimbusec.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result.Id == 0 {
+		result.Id = s.id()
+	}
+	if s.results[domain] == nil {
+		s.results[domain] = map[int]nimbusec.Result{}
+	}
+	s.results[domain][result.Id] = result
+	return result
+}
+
+// Results returns all results of the given domain ordered by id.
+func (s *Server) Results(domain int) []nimbusec.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.results[domain])
+}
+
+// SetMetadata sets the metadata of the given domain.
+func (s *Server) SetMetadata(domain int, metadata nimbusec.DomainMetadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata[domain] = metadata
+}
+
+// AddApplication adds a detected application to the given domain.
+func (s *Server) AddApplication(domain int, app nimbusec.DomainApplication) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applications[domain] = append(s.applications[domain], app)
+}
+
+// Events returns all events of the given domain in the order they were created.
+func (s *Server) Events(domain int) []nimbusec.DomainEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]nimbusec.DomainEvent(nil), s.events[domain]...)
+}
+
+// SetScreenshot sets the screenshot of the domain for region and viewport.
+func (s *Server) SetScreenshot(domain int, region, viewport string, screenshot nimbusec.Screenshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.screenshots[fmt.Sprintf("%d/%s/%s", domain, region, viewport)] = screenshot
+}
+
+// AddImage serves data at the given absolute path, e.g. for screenshot urls.
+func (s *Server) AddImage(path string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.images[path] = data
+}
+
+// AddIssue adds an entry to the domain issue overview.
+func (s *Server) AddIssue(issue nimbusec.DomainIssues) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issues = append(s.issues, issue)
+}
+
+// DomainConfigs returns a copy of the configuration of the given domain.
+func (s *Server) DomainConfigs(domain int) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyMap(s.domainConfigs[domain])
+}
+
+// AddUser stores the user and returns it with its assigned id.
+func (s *Server) AddUser(user nimbusec.User) nimbusec.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.Id == 0 {
+		user.Id = s.id()
+	}
+	s.users[user.Id] = user
+	return user
+}
+
+// Users returns all stored users ordered by id.
+func (s *Server) Users() []nimbusec.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.users)
+}
+
+// UserDomains returns the domain set of the given user.
+func (s *Server) UserDomains(user int) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.userDomains[user]...)
+}
+
+// Notifications returns all notifications of the given user ordered by id.
+func (s *Server) Notifications(user int) []nimbusec.Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedValues(s.notifications[user])
+}
+
+// AddToken stores the token and returns it with its assigned id.
+func (s *Server) AddToken(token nimbusec.Token) nimbusec.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token.Id == 0 {
+		token.Id = s.id()
+	}
+	s.tokens[token.Id] = token
+	return token
+}
+
+// AddBundle stores the bundle. Bundles without id get a generated one.
+func (s *Server) AddBundle(bundle nimbusec.Bundle) nimbusec.Bundle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if bundle.Id == "" {
+		bundle.Id = fmt.Sprintf("bundle-%d", s.id())
+	}
+	s.bundles[bundle.Id] = bundle
+	return bundle
+}
+
+// AddAgent makes the agent and its binary available for download.
+func (s *Server) AddAgent(agent nimbusec.Agent, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.agents = append(s.agents, agent)
+	s.agentFiles[agentFile(agent)] = data
+}
+
+func agentFile(agent nimbusec.Agent) string {
+	return fmt.Sprintf("nimbusagent-%s-%s-v%d.%s", agent.OS, agent.Arch, agent.Version, agent.Format)
+}
+
+// Fail makes the server answer every authenticated request with status and
+// message, e.g. 403 "quota exceeded" for an exhausted bundle. A status of 0
+// restores normal operation.
+func (s *Server) Fail(status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failStatus, s.failMessage = status, message
+}
+
+// handler builds the router of the fake server.
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /v2/domain", s.createDomain)
+	mux.HandleFunc("GET /v2/domain", s.findDomains)
+	mux.HandleFunc("GET /v2/domain/{domain}", s.getDomain)
+	mux.HandleFunc("PUT /v2/domain/{domain}", s.updateDomain)
+	mux.HandleFunc("DELETE /v2/domain/{domain}", s.deleteDomain)
+	mux.HandleFunc("GET /v2/infected", s.findInfected)
+	mux.HandleFunc("GET /v2/domain/{domain}/config", s.listDomainConfigs)
+	mux.HandleFunc("GET /v2/domain/{domain}/config/{key}/{$}", s.getDomainConfig)
+	mux.HandleFunc("PUT /v2/domain/{domain}/config/{key}/{$}", s.setDomainConfig)
+	mux.HandleFunc("DELETE /v2/domain/{domain}/config/{key}/{$}", s.deleteDomainConfig)
+	mux.HandleFunc("GET /v2/domain/{domain}/events", s.getDomainEvents)
+	mux.HandleFunc("POST /v2/domain/{domain}/events", s.createDomainEvent)
+	mux.HandleFunc("GET /v2/domain/{domain}/metadata", s.getDomainMetadata)
+	mux.HandleFunc("GET /v2/domain/{domain}/applications", s.getDomainApplications)
+	mux.HandleFunc("GET /v2/domain/{domain}/screenshot/{region}/{viewport}", s.getScreenshot)
+	mux.HandleFunc("GET /v2/domainissues", s.getIssues)
+
+	mux.HandleFunc("GET /v2/domain/{domain}/result", s.findResults)
+	mux.HandleFunc("GET /v2/domain/{domain}/result/{result}", s.getResult)
+	mux.HandleFunc("PUT /v2/domain/{domain}/result/{result}", s.updateResult)
+
+	mux.HandleFunc("POST /v2/user", s.createUser)
+	mux.HandleFunc("GET /v2/user", s.findUsers)
+	mux.HandleFunc("GET /v2/user/{user}", s.getUser)
+	mux.HandleFunc("PUT /v2/user/{user}", s.updateUser)
+	mux.HandleFunc("DELETE /v2/user/{user}", s.deleteUser)
+	mux.HandleFunc("GET /v2/user/{user}/domains", s.getDomainSet)
+	mux.HandleFunc("PUT /v2/user/{user}/domains", s.updateDomainSet)
+	mux.HandleFunc("POST /v2/user/{user}/domains", s.linkDomain)
+	mux.HandleFunc("DELETE /v2/user/{user}/domains/{domain}", s.unlinkDomain)
+	mux.HandleFunc("GET /v2/user/{user}/config", s.listUserConfigs)
+	mux.HandleFunc("GET /v2/user/{user}/config/{key}/{$}", s.getUserConfig)
+	mux.HandleFunc("PUT /v2/user/{user}/config/{key}/{$}", s.setUserConfig)
+	mux.HandleFunc("DELETE /v2/user/{user}/config/{key}/{$}", s.deleteUserConfig)
+	mux.HandleFunc("POST /v2/user/{user}/notification", s.createNotification)
+	mux.HandleFunc("GET /v2/user/{user}/notification", s.findNotifications)
+	mux.HandleFunc("GET /v2/user/{user}/notification/{notification}", s.getNotification)
+	mux.HandleFunc("PUT /v2/user/{user}/notification/{notification}", s.updateNotification)
+	mux.HandleFunc("DELETE /v2/user/{user}/notification/{notification}", s.deleteNotification)
+
+	mux.HandleFunc("POST /v2/agent/token", s.createToken)
+	mux.HandleFunc("GET /v2/agent/token", s.findTokens)
+	mux.HandleFunc("GET /v2/agent/token/{token}", s.getToken)
+	mux.HandleFunc("GET /v2/agent/download", s.findAgents)
+	mux.HandleFunc("GET /v2/agent/download/{file}", s.downloadAgent)
+
+	mux.HandleFunc("GET /v2/bundle", s.findBundles)
+	mux.HandleFunc("GET /v2/bundle/{bundle}", s.getBundle)
+
+	mux.HandleFunc("GET /", s.getImage)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.verify(r); err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		s.mu.Lock()
+		status, msg := s.failStatus, s.failMessage
+		s.mu.Unlock()
+		if status != 0 {
+			writeError(w, status, msg)
+			return
+		}
+
+		if s.IgnoreLimit || s.IgnoreOffset {
+			query := r.URL.Query()
+			if s.IgnoreLimit {
+				query.Del("limit")
+			}
+			if s.IgnoreOffset {
+				query.Del("offset")
+			}
+			r.URL.RawQuery = query.Encode()
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// writeError reports an error the same way the nimbusec API does.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("x-nimbusec-error", msg)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, msg)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeText(w http.ResponseWriter, s string) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, s)
+}
+
+// readJSON decodes the request body into dst and reports a 400 error if that
+// fails.
+func readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid json: %v", err))
+		return false
+	}
+	return true
+}
+
+// pathID parses the integer path parameter name and reports a 404 error if
+// that fails.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("invalid %s id", name))
+		return 0, false
+	}
+	return id, true
+}
+
+// query parses the q parameter of the request and reports a 400 error if
+// that fails.
+func query(w http.ResponseWriter, r *http.Request) (filter.Expr, bool) {
+	expr, err := filter.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return filter.Expr{}, false
+	}
+	return expr, true
+}
+
+// writeFiltered writes all items matching the q parameter of the request.
+func writeFiltered[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	expr, ok := query(w, r)
+	if !ok {
+		return
+	}
+
+	var zero T
+	if err := expr.ValidateFields(zero); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	matched, err := filter.Select(expr, items)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, paginate(r, matched))
+}
+
+// paginate applies the limit and offset parameters of the request to items.
+func paginate[T any](r *http.Request, items []T) []T {
+	if offset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && offset > 0 {
+		if offset > len(items) {
+			offset = len(items)
+		}
+		items = items[offset:]
+	}
+
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	return items
+}
+
+// upsert returns the value of the upsert parameter: "true", "false" or "".
+func upsert(r *http.Request) string {
+	return r.URL.Query().Get("upsert")
+}
+
+type identifiable interface {
+	nimbusec.Domain | nimbusec.Result | nimbusec.User | nimbusec.Notification | nimbusec.Token
+}
+
+func idOf(v interface{}) int {
+	switch x := v.(type) {
+	case nimbusec.Domain:
+		return x.Id
+	case nimbusec.Result:
+		return x.Id
+	case nimbusec.User:
+		return x.Id
+	case nimbusec.Notification:
+		return x.Id
+	case nimbusec.Token:
+		return x.Id
+	}
+	return 0
+}
+
+func sortedValues[T identifiable](m map[int]T) []T {
+	dst := make([]T, 0, len(m))
+	for _, v := range m {
+		dst = append(dst, v)
+	}
+	sort.Slice(dst, func(i, j int) bool { return idOf(dst[i]) < idOf(dst[j]) })
+	return dst
+}
+
+func copyMap(m map[string]string) map[string]string {
+	dst := make(map[string]string, len(m))
+	for k, v := range m {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,293 @@
+package nimbusectest_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestDomains(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	domain := &nimbusec.Domain{Name: "example.com", Scheme: "https", Bundle: "b", DeepScan: "https://example.com/"}
+	created, err := api.CreateDomain(domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Id == 0 {
+		t.Fatal("created domain has no id")
+	}
+
+	if _, err := api.CreateDomain(domain); !nimbusec.IsConflict(err) {
+		t.Errorf("duplicate CreateDomain error = %v, want conflict", err)
+	}
+
+	update := *domain
+	update.Bundle = "other"
+	existing, err := api.CreateOrGetDomain(&update)
+	if err != nil || existing.Id != created.Id || existing.Bundle != "b" {
+		t.Errorf("CreateOrGetDomain = %+v, %v, want the existing domain", existing, err)
+	}
+
+	updated, err := api.CreateOrUpdateDomain(&update)
+	if err != nil || updated.Id != created.Id || updated.Bundle != "other" {
+		t.Errorf("CreateOrUpdateDomain = %+v, %v, want the updated domain", updated, err)
+	}
+
+	srv.AddDomain(nimbusec.Domain{Name: "example.org", Scheme: "http"})
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{nimbusec.EmptyFilter, []string{"example.com", "example.org"}},
+		{`name eq "example.org"`, []string{"example.org"}},
+		{`scheme eq "https" or name like "%.org"`, []string{"example.com", "example.org"}},
+		{`name eq "missing"`, []string{}},
+	}
+
+	for _, tt := range tests {
+		domains, err := api.FindDomains(tt.filter)
+		if err != nil {
+			t.Fatalf("FindDomains(%q): %v", tt.filter, err)
+		}
+
+		names := make([]string, 0)
+		for _, d := range domains {
+			names = append(names, d.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("FindDomains(%q) = %v, want %v", tt.filter, names, tt.want)
+		}
+	}
+
+	if err := api.DeleteDomain(created, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.GetDomain(created.Id); !nimbusec.IsNotFound(err) {
+		t.Errorf("GetDomain after delete error = %v, want not found", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	tests := []struct {
+		name   string
+		call   func() error
+		status int
+	}{
+		{"unknown domain", func() error { _, err := api.GetDomain(42); return err }, 404},
+		{"invalid filter", func() error { _, err := api.FindDomains(`name eq`); return err }, 400},
+		{"unknown field", func() error { _, err := api.FindDomains(`nope eq 1`); return err }, 400},
+		{"unknown result", func() error { _, err := api.GetResult(42, 1); return err }, 404},
+		{"missing config", func() error {
+			d := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+			_, err := api.GetDomainConfig(d.Id, "missing")
+			return err
+		}, 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+
+			var apiErr *nimbusec.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", apiErr.StatusCode, tt.status)
+			}
+			if apiErr.Message == "" {
+				t.Error("x-nimbusec-error message is empty")
+			}
+		})
+	}
+}
+
+func TestSignatureRejected(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		key, secret string
+	}{
+		{"wrong secret", srv.Key, "wrong"},
+		{"unknown key", "unknown", srv.Secret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, err := nimbusec.NewAPI(srv.URL, tt.key, tt.secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := api.FindDomains(nimbusec.EmptyFilter); !nimbusec.IsUnauthorized(err) {
+				t.Errorf("error = %v, want unauthorized", err)
+			}
+		})
+	}
+
+	// parameters whose names sort differently as "key=value" strings must
+	// still be signed correctly.
+	api := srv.API()
+	if _, err := api.FindDomainsPage(t.Context(), `name eq "a-b.c"`, nimbusec.Page{Limit: 10, Offset: 1}); err != nil {
+		t.Errorf("paged FindDomains: %v", err)
+	}
+}
+
+func TestResults(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	clean := srv.AddDomain(nimbusec.Domain{Name: "clean.example.com"})
+	infected := srv.AddDomain(nimbusec.Domain{Name: "infected.example.com"})
+	srv.AddResult(clean.Id, nimbusec.Result{Status: nimbusec.StatusAcknowledged})
+	pending := srv.AddResult(infected.Id, nimbusec.Result{Status: nimbusec.StatusPending, Severity: 3})
+
+	domains, err := api.FindInfected(nimbusec.EmptyFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 1 || domains[0].Id != infected.Id {
+		t.Errorf("FindInfected = %v, want only %s", domains, infected.Name)
+	}
+
+	results, err := api.FindResults(infected.Id, `severity ge 2 and status eq "pending"`)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("FindResults = %v, %v, want one result", results, err)
+	}
+
+	// all fields except the status are ignored by updates.
+	updated, err := api.UpdateResult(infected.Id, &nimbusec.Result{Id: pending.Id, Status: nimbusec.StatusFalsePositive, Severity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != nimbusec.StatusFalsePositive || updated.Severity != 3 {
+		t.Errorf("UpdateResult = %+v, want status changed only", updated)
+	}
+
+	domains, err = api.FindInfected(nimbusec.EmptyFilter)
+	if err != nil || len(domains) != 0 {
+		t.Errorf("FindInfected after update = %v, %v, want none", domains, err)
+	}
+}
+
+func TestDomainConfigs(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	d := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	for _, key := range []string{"b", "a"} {
+		if _, err := api.SetDomainConfig(d.Id, key, "value-"+key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := api.ListDomainConfigs(d.Id)
+	if err != nil || !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("ListDomainConfigs = %v, %v, want [a b]", keys, err)
+	}
+
+	value, err := api.GetDomainConfig(d.Id, "a")
+	if err != nil || value != "value-a" {
+		t.Errorf("GetDomainConfig = %q, %v, want value-a", value, err)
+	}
+
+	if err := api.DeleteDomainConfig(d.Id, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.DomainConfigs(d.Id); !reflect.DeepEqual(got, map[string]string{"b": "value-b"}) {
+		t.Errorf("configs after delete = %v", got)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	user, err := api.CreateUser(&nimbusec.User{Login: "o'brien\"", Mail: "x@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != "" {
+		t.Error("password returned by the server")
+	}
+
+	found, err := api.GetUserByLogin(`o'brien"`)
+	if err != nil || found.Id != user.Id {
+		t.Errorf("GetUserByLogin = %+v, %v", found, err)
+	}
+
+	if _, err := api.GetUserByLogin("missing"); !errors.Is(err, nimbusec.ErrNotFound) {
+		t.Errorf("GetUserByLogin(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	for _, name := range []string{"a.com", "b.com", "c.com", "d.com", "e.com"} {
+		srv.AddDomain(nimbusec.Domain{Name: name})
+	}
+
+	tests := []struct {
+		page nimbusec.Page
+		want []string
+	}{
+		{nimbusec.Page{}, []string{"a.com", "b.com", "c.com", "d.com", "e.com"}},
+		{nimbusec.Page{Limit: 2}, []string{"a.com", "b.com"}},
+		{nimbusec.Page{Limit: 2, Offset: 2}, []string{"c.com", "d.com"}},
+		{nimbusec.Page{Limit: 2, Offset: 4}, []string{"e.com"}},
+		{nimbusec.Page{Offset: 10}, []string{}},
+	}
+
+	for _, tt := range tests {
+		domains, err := api.FindDomainsPage(t.Context(), nimbusec.EmptyFilter, tt.page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := make([]string, 0)
+		for _, d := range domains {
+			names = append(names, d.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("FindDomainsPage(%+v) = %v, want %v", tt.page, names, tt.want)
+		}
+	}
+}
+
+func TestAgentDownload(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	agent := nimbusec.Agent{OS: "linux", Arch: "64bit", Version: 14, Format: "zip"}
+	srv.AddAgent(agent, []byte("binary"))
+
+	data, err := api.DownloadAgent(agent)
+	if err != nil || string(data) != "binary" {
+		t.Errorf("DownloadAgent = %q, %v", data, err)
+	}
+
+	agents, err := api.FindAgents(nimbusec.EmptyFilter)
+	if err != nil || len(agents) != 1 {
+		t.Errorf("FindAgents = %v, %v", agents, err)
+	}
+}
@@ -0,0 +1,164 @@
+package nimbusec
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cumulodev/oauth"
+)
+
+// Option configures an API client created with NewAPIWithOptions.
+type Option func(*API) error
+
+// NewAPIWithOptions creates a new nimbusec API client and applies the given
+// options in order.
+func NewAPIWithOptions(rawurl, key, secret string, opts ...Option) (*API, error) {
+	parsed, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &API{
+		url:    parsed,
+		key:    key,
+		secret: secret,
+		token:  &oauth.AccessToken{},
+		client: &http.Client{},
+		header: http.Header{},
+	}
+
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// WithHTTPClient uses a copy of the given client to send requests. Options
+// modifying the transport or timeout do not affect the passed client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *API) error {
+		if client == nil {
+			return errors.New("nimbusec: http client must not be nil")
+		}
+
+		c := *client
+		a.client = &c
+		return nil
+	}
+}
+
+// WithTransport uses the given round tripper to send requests.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(a *API) error {
+		a.client.Transport = transport
+		return nil
+	}
+}
+
+// WithTimeout limits the time of a single request attempt, including reading
+// the response body.
+func WithTimeout(timeout time.Duration) Option {
+	return func(a *API) error {
+		a.client.Timeout = timeout
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(agent string) Option {
+	return func(a *API) error {
+		a.header.Set("User-Agent", agent)
+		return nil
+	}
+}
+
+// WithHeader adds a header that is sent with every request.
+func WithHeader(key, value string) Option {
+	return func(a *API) error {
+		a.header.Add(key, value)
+		return nil
+	}
+}
+
+// WithProxy sends all requests through the proxy at the given url.
+func WithProxy(rawurl string) Option {
+	return func(a *API) error {
+		proxy, err := url.Parse(rawurl)
+		if err != nil {
+			return err
+		}
+
+		t, err := a.transport()
+		if err != nil {
+			return err
+		}
+
+		t.Proxy = http.ProxyURL(proxy)
+		return nil
+	}
+}
+
+// WithTLSConfig uses the given TLS configuration, e.g. to trust a custom CA
+// pool or to present a client certificate.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(a *API) error {
+		t, err := a.transport()
+		if err != nil {
+			return err
+		}
+
+		t.TLSClientConfig = config
+		return nil
+	}
+}
+
+// WithRetryPolicy is the option equivalent of SetRetryPolicy.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(a *API) error {
+		a.SetRetryPolicy(policy)
+		return nil
+	}
+}
+
+// WithRateLimit is the option equivalent of SetRateLimit.
+func WithRateLimit(limit *RateLimit) Option {
+	return func(a *API) error {
+		a.SetRateLimit(limit)
+		return nil
+	}
+}
+
+// WithPageSize sets the number of entities the Iter* methods fetch per request.
+func WithPageSize(size int) Option {
+	return func(a *API) error {
+		if size <= 0 {
+			return errors.New("nimbusec: page size must be positive")
+		}
+
+		a.pageSize = size
+		return nil
+	}
+}
+
+// transport returns a private copy of the client transport, so that it can be
+// modified by options.
+func (a *API) transport() (*http.Transport, error) {
+	var t *http.Transport
+	switch rt := a.client.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = rt.Clone()
+	default:
+		return nil, errors.New("nimbusec: option requires the transport to be a *http.Transport")
+	}
+
+	a.client.Transport = t
+	return t, nil
+}
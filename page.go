@@ -0,0 +1,229 @@
+package nimbusec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strconv"
+)
+
+// DefaultPageSize is the number of entities the iterators fetch per request,
+// unless configured otherwise with WithPageSize.
+const DefaultPageSize = 500
+
+// Page selects a window of a collection. A zero Limit fetches all remaining
+// entities.
+type Page struct {
+	Limit  int // maximum number of entities to return
+	Offset int // number of entities to skip
+}
+
+// apply adds the paging parameters to params.
+func (p Page) apply(params Params) Params {
+	if p.Limit > 0 {
+		params["limit"] = strconv.Itoa(p.Limit)
+	}
+	if p.Offset > 0 {
+		params["offset"] = strconv.Itoa(p.Offset)
+	}
+	return params
+}
+
+// filterParams returns the parameters for the given filter.
+func filterParams(filter string) Params {
+	params := Params{}
+	if filter != EmptyFilter {
+		params["q"] = filter
+	}
+	return params
+}
+
+// stream issues a GET request and decodes the returned json array element by
+// element, so that the whole collection never has to be held in memory. The
+// number of decoded elements is stored in count. stream returns false if the
+// iteration must stop, either because of an error or because yield said so.
+func stream[T any](ctx context.Context, a *API, url string, params Params, count *int, yield func(T, error) bool) bool {
+	var zero T
+
+	resp, err := a.do(ctx, "GET", url, params, "", nil)
+	if err != nil {
+		yield(zero, err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	tok, err := decoder.Token()
+	if err != nil {
+		yield(zero, err)
+		return false
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		yield(zero, fmt.Errorf("nimbusec: expected json array, got %v", tok))
+		return false
+	}
+
+	for decoder.More() {
+		var item T
+		if err := decoder.Decode(&item); err != nil {
+			yield(zero, err)
+			return false
+		}
+
+		*count++
+		if !yield(item, nil) {
+			return false
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		yield(zero, err)
+		return false
+	}
+	return true
+}
+
+// maxPages limits the number of pages paginate fetches, as a safeguard
+// against endpoints that never return a short page.
+const maxPages = 10000
+
+// paginate iterates over all entities of a collection, fetching one page per
+// request. Iteration stops after the first error. id returns the id of an
+// entity, which is used to detect servers ignoring the offset parameter.
+func paginate[T any](ctx context.Context, a *API, url string, params Params, id func(T) int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		size := a.pageSize
+		if size <= 0 {
+			size = DefaultPageSize
+		}
+
+		previous := 0
+		for page := 0; page < maxPages; page++ {
+			count := 0
+			first := 0
+			repeated := false
+			each := func(item T, err error) bool {
+				if err == nil && count == 1 {
+					first = id(item)
+					// a page starting with the same entity as the one
+					// before means the offset was ignored.
+					if page > 0 && first == previous {
+						repeated = true
+						return false
+					}
+				}
+				return yield(item, err)
+			}
+
+			query := Page{Limit: size, Offset: page * size}.apply(copyParams(params))
+			if !stream(ctx, a, url, query, &count, each) || repeated {
+				return
+			}
+
+			// a short page is the last one. A page larger than requested means
+			// the endpoint does not support paging and returned everything.
+			if count != size {
+				return
+			}
+			previous = first
+		}
+
+		var zero T
+		yield(zero, fmt.Errorf("nimbusec: %s returned more than %d pages", url, maxPages))
+	}
+}
+
+func copyParams(params Params) Params {
+	dst := make(Params, len(params)+2)
+	for k, v := range params {
+		dst[k] = v
+	}
+	return dst
+}
+
+// getPage fetches a single page of a collection into dst.
+func (a *API) getPage(ctx context.Context, url string, filter string, page Page, dst interface{}) error {
+	return a.GetContext(ctx, url, page.apply(filterParams(filter)), dst)
+}
+
+// FindDomainsPage is like FindDomains but only fetches the given page.
+func (a *API) FindDomainsPage(ctx context.Context, filter string, page Page) ([]Domain, error) {
+	dst := make([]Domain, 0)
+	err := a.getPage(ctx, a.BuildURL("/v2/domain"), filter, page, &dst)
+	return dst, err
+}
+
+// IterDomains iterates over all domains that match the given filter criteria.
+func (a *API) IterDomains(ctx context.Context, filter string) iter.Seq2[Domain, error] {
+	return paginate[Domain](ctx, a, a.BuildURL("/v2/domain"), filterParams(filter), domainID)
+}
+
+// FindInfectedPage is like FindInfected but only fetches the given page.
+func (a *API) FindInfectedPage(ctx context.Context, filter string, page Page) ([]Domain, error) {
+	dst := make([]Domain, 0)
+	err := a.getPage(ctx, a.BuildURL("/v2/infected"), filter, page, &dst)
+	return dst, err
+}
+
+// IterInfected iterates over all domains with pending results that match the
+// given filter criteria.
+func (a *API) IterInfected(ctx context.Context, filter string) iter.Seq2[Domain, error] {
+	return paginate[Domain](ctx, a, a.BuildURL("/v2/infected"), filterParams(filter), domainID)
+}
+
+// FindResultsPage is like FindResults but only fetches the given page.
+func (a *API) FindResultsPage(ctx context.Context, domain int, filter string, page Page) ([]Result, error) {
+	dst := make([]Result, 0)
+	err := a.getPage(ctx, a.BuildURL("/v2/domain/%d/result", domain), filter, page, &dst)
+	return dst, err
+}
+
+// IterResults iterates over all results of the domain that match the given
+// filter criteria.
+func (a *API) IterResults(ctx context.Context, domain int, filter string) iter.Seq2[Result, error] {
+	return paginate[Result](ctx, a, a.BuildURL("/v2/domain/%d/result", domain), filterParams(filter), resultID)
+}
+
+// FindUsersPage is like FindUsers but only fetches the given page.
+func (a *API) FindUsersPage(ctx context.Context, filter string, page Page) ([]User, error) {
+	dst := make([]User, 0)
+	err := a.getPage(ctx, a.BuildURL("/v2/user"), filter, page, &dst)
+	return dst, err
+}
+
+// IterUsers iterates over all users that match the given filter criteria.
+func (a *API) IterUsers(ctx context.Context, filter string) iter.Seq2[User, error] {
+	return paginate[User](ctx, a, a.BuildURL("/v2/user"), filterParams(filter), userID)
+}
+
+// FindNotificationsPage is like FindNotifications but only fetches the given page.
+func (a *API) FindNotificationsPage(ctx context.Context, user int, filter string, page Page) ([]Notification, error) {
+	dst := make([]Notification, 0)
+	err := a.getPage(ctx, a.BuildURL("/v2/user/%d/notification", user), filter, page, &dst)
+	return dst, err
+}
+
+// IterNotifications iterates over all notifications of the user that match
+// the given filter criteria.
+func (a *API) IterNotifications(ctx context.Context, user int, filter string) iter.Seq2[Notification, error] {
+	return paginate[Notification](ctx, a, a.BuildURL("/v2/user/%d/notification", user), filterParams(filter), notificationID)
+}
+
+// FindTokensPage is like FindTokens but only fetches the given page.
+func (a *API) FindTokensPage(ctx context.Context, filter string, page Page) ([]Token, error) {
+	dst := make([]Token, 0)
+	err := a.getPage(ctx, a.BuildURL("/v2/agent/token"), filter, page, &dst)
+	return dst, err
+}
+
+// IterTokens iterates over all tokens that match the given filter criteria.
+func (a *API) IterTokens(ctx context.Context, filter string) iter.Seq2[Token, error] {
+	return paginate[Token](ctx, a, a.BuildURL("/v2/agent/token"), filterParams(filter), tokenID)
+}
+
+func domainID(d Domain) int             { return d.Id }
+func resultID(r Result) int             { return r.Id }
+func userID(u User) int                 { return u.Id }
+func notificationID(n Notification) int { return n.Id }
+func tokenID(t Token) int               { return t.Id }
@@ -0,0 +1,88 @@
+package nimbusec_test
+
+import (
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestIterDomains(t *testing.T) {
+	tests := []struct {
+		name         string
+		domains      int
+		ignoreLimit  bool
+		ignoreOffset bool
+		want         int
+	}{
+		{"empty", 0, false, false, 0},
+		{"short page", 2, false, false, 2},
+		{"exact pages", 6, false, false, 6},
+		{"partial last page", 7, false, false, 7},
+		{"ignores offset", 7, false, true, 3},
+		{"ignores offset with exact page", 3, false, true, 3},
+		{"ignores limit and offset", 7, true, true, 7},
+		{"ignores limit and offset with exact page", 3, true, true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := nimbusectest.NewServer()
+			defer srv.Close()
+			srv.IgnoreLimit = tt.ignoreLimit
+			srv.IgnoreOffset = tt.ignoreOffset
+
+			for i := 0; i < tt.domains; i++ {
+				srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+			}
+
+			api := srv.API(nimbusec.WithPageSize(3))
+
+			seen := map[int]bool{}
+			for domain, err := range api.IterDomains(t.Context(), nimbusec.EmptyFilter) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if seen[domain.Id] {
+					t.Fatalf("domain %d yielded twice", domain.Id)
+				}
+				seen[domain.Id] = true
+
+				if len(seen) > tt.domains {
+					t.Fatalf("yielded %d domains, more than exist", len(seen))
+				}
+			}
+
+			if len(seen) != tt.want {
+				t.Errorf("yielded %d domains, want %d", len(seen), tt.want)
+			}
+		})
+	}
+}
+
+func TestIterResultsStopsEarly(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	for i := 0; i < 10; i++ {
+		srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending})
+	}
+
+	api := srv.API(nimbusec.WithPageSize(3))
+
+	n := 0
+	for _, err := range api.IterResults(t.Context(), domain.Id, `status eq 1`) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if n == 4 {
+			break
+		}
+	}
+
+	if n != 4 {
+		t.Errorf("iterated %d results, want 4", n)
+	}
+}
@@ -0,0 +1,235 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// FieldDrift is a domain field that differs from the profile.
+type FieldDrift struct {
+	Field   string
+	Current string
+	Desired string
+}
+
+// Drift is the difference between a domain and its effective profile.
+type Drift struct {
+	Name    string
+	Profile string
+	Current *nimbusec.Domain // nil if the domain could not be fetched
+	Desired *nimbusec.Domain
+	Fields  []FieldDrift
+	Configs []nimbusec.ConfigChange // changes needed to match the profile
+	Err     error                   // error while checking or applying
+}
+
+// Diverged reports whether the domain differs from its profile.
+func (d Drift) Diverged() bool {
+	return len(d.Fields) > 0 || len(d.Configs) > 0
+}
+
+// Manager detects and corrects drift of domains from their profiles.
+type Manager struct {
+	API         *nimbusec.API
+	Profiles    *Set
+	Prune       bool // config keys not in the profile count as drift and are deleted, unset keys always are
+	Concurrency int  // domains checked in parallel, defaults to nimbusec.DefaultBulkConcurrency
+}
+
+// Check compares every assigned domain with its effective profile. Errors
+// of single domains are reported in their Drift.
+func (m *Manager) Check(ctx context.Context) ([]Drift, error) {
+	return m.run(ctx, false)
+}
+
+// Apply checks all domains like Check and updates the domain settings and
+// configs of those that diverged. The returned drifts describe what was
+// changed.
+func (m *Manager) Apply(ctx context.Context) ([]Drift, error) {
+	return m.run(ctx, true)
+}
+
+func (m *Manager) run(ctx context.Context, apply bool) ([]Drift, error) {
+	if err := m.Profiles.Validate(); err != nil {
+		return nil, err
+	}
+
+	concurrency := m.Concurrency
+	if concurrency <= 0 {
+		concurrency = nimbusec.DefaultBulkConcurrency
+	}
+
+	domains := m.Profiles.Domains
+	drifts := make([]Drift, len(domains))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+spawn:
+	for i, a := range domains {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(domains); j++ {
+				drifts[j] = Drift{Name: domains[j].Name, Profile: domains[j].Profile, Err: ctx.Err()}
+			}
+			break spawn
+		}
+
+		wg.Add(1)
+		go func(i int, a Assignment) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			drift := m.check(ctx, a)
+			if apply && drift.Err == nil && drift.Diverged() {
+				drift.Err = m.apply(ctx, drift)
+			}
+			drifts[i] = drift
+		}(i, a)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return drifts, err
+	}
+	return drifts, nil
+}
+
+func (m *Manager) check(ctx context.Context, a Assignment) Drift {
+	drift := Drift{Name: a.Name, Profile: a.Profile}
+
+	current, err := m.API.GetDomainByNameContext(ctx, a.Name)
+	if err != nil {
+		drift.Err = err
+		return drift
+	}
+	drift.Current = current
+
+	effective := m.Profiles.Effective(a)
+	desired, err := effective.apply(*current)
+	if err != nil {
+		drift.Err = err
+		return drift
+	}
+	drift.Desired = &desired
+
+	field := func(name, current, desired string) {
+		if current != desired {
+			drift.Fields = append(drift.Fields, FieldDrift{Field: name, Current: current, Desired: desired})
+		}
+	}
+	field("bundle", current.Bundle, desired.Bundle)
+	field("scheme", current.Scheme, desired.Scheme)
+	field("deepScan", current.DeepScan, desired.DeepScan)
+	if !sameSet(current.FastScans, desired.FastScans) {
+		field("fastScans", strings.Join(current.FastScans, " "), strings.Join(desired.FastScans, " "))
+	}
+
+	configs, err := m.API.GetAllDomainConfigsContext(ctx, current.Id)
+	if err != nil {
+		drift.Err = err
+		return drift
+	}
+	drift.Configs = nimbusec.DiffConfigs(configs, effective.Configs, m.Prune)
+	if !m.Prune {
+		// unset keys are deleted even if other keys are kept.
+		for _, key := range effective.Unset {
+			if old, ok := configs[key]; ok {
+				drift.Configs = append(drift.Configs, nimbusec.ConfigChange{Key: key, Old: old, Delete: true})
+			}
+		}
+		sort.Slice(drift.Configs, func(i, j int) bool {
+			return drift.Configs[i].Key < drift.Configs[j].Key
+		})
+	}
+
+	return drift
+}
+
+func (m *Manager) apply(ctx context.Context, drift Drift) error {
+	if len(drift.Fields) > 0 {
+		if _, err := m.API.UpdateDomainContext(ctx, drift.Desired); err != nil {
+			return err
+		}
+	}
+	return m.API.ApplyConfigChangesContext(ctx, drift.Current.Id, drift.Configs)
+}
+
+// WriteReport writes the domains that diverged from their profile or could
+// not be checked, grouped by profile.
+func WriteReport(w io.Writer, drifts []Drift) error {
+	byProfile := make(map[string][]Drift)
+	for _, d := range drifts {
+		if d.Diverged() || d.Err != nil {
+			byProfile[d.Profile] = append(byProfile[d.Profile], d)
+		}
+	}
+
+	profiles := make([]string, 0, len(byProfile))
+	for name := range byProfile {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+
+	var b strings.Builder
+	diverged := 0
+	for _, profile := range profiles {
+		fmt.Fprintf(&b, "%s:\n", profile)
+
+		list := byProfile[profile]
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Name < list[j].Name
+		})
+
+		for _, d := range list {
+			fmt.Fprintf(&b, "  %s\n", d.Name)
+			if d.Err != nil {
+				fmt.Fprintf(&b, "    error: %v\n", d.Err)
+			}
+			if d.Diverged() {
+				diverged++
+			}
+			for _, f := range d.Fields {
+				fmt.Fprintf(&b, "    %s: %q, profile %q\n", f.Field, f.Current, f.Desired)
+			}
+			for _, c := range d.Configs {
+				switch {
+				case c.Delete:
+					fmt.Fprintf(&b, "    config %s: %q, not in profile\n", c.Key, c.Old)
+				case c.Old == "":
+					fmt.Fprintf(&b, "    config %s: unset, profile %q\n", c.Key, c.New)
+				default:
+					fmt.Fprintf(&b, "    config %s: %q, profile %q\n", c.Key, c.Old, c.New)
+				}
+			}
+		}
+	}
+	fmt.Fprintf(&b, "%d of %d domains diverged from their profile\n", diverged, len(drifts))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	count := make(map[string]int, len(a))
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		count[s]--
+		if count[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
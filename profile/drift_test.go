@@ -0,0 +1,179 @@
+package profile_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+	"github.com/cumulodev/nimbusec/profile"
+)
+
+const profiles = `
+base:
+  configs:
+    notify: "true"
+profiles:
+  shop:
+    fastScans: [/, /shop/]
+  static:
+    unset: [depth]
+domains:
+  - name: shop.example.com
+    profile: shop
+  - name: static.example.com
+    profile: static
+  - name: missing.example.com
+    profile: static
+`
+
+// account creates the domains of the profiles: the shop is a legacy domain
+// that would not pass Domain.Validate, the static site has a config the
+// profile unsets and one it does not know.
+func account(t *testing.T) *nimbusectest.Server {
+	srv := nimbusectest.NewServer()
+	t.Cleanup(srv.Close)
+
+	shop := srv.AddDomain(nimbusec.Domain{Name: "shop.example.com", Scheme: "https", DeepScan: "/", FastScans: []string{"/", "/"}})
+	static := srv.AddDomain(nimbusec.Domain{Name: "static.example.com", Scheme: "https"})
+
+	api := srv.API()
+	for domain, configs := range map[int]map[string]string{
+		shop.Id:   {"notify": "true"},
+		static.Id: {"notify": "true", "depth": "2", "custom": "x"},
+	} {
+		for key, value := range configs {
+			if _, err := api.SetDomainConfig(domain, key, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return srv
+}
+
+func TestCheck(t *testing.T) {
+	set, err := profile.ParseYAML([]byte(profiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		prune  bool
+		static []nimbusec.ConfigChange
+	}{
+		{"keep", false, []nimbusec.ConfigChange{
+			{Key: "depth", Old: "2", Delete: true},
+		}},
+		{"prune", true, []nimbusec.ConfigChange{
+			{Key: "custom", Old: "x", Delete: true},
+			{Key: "depth", Old: "2", Delete: true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := account(t)
+			m := &profile.Manager{API: srv.API(), Profiles: set, Prune: tt.prune}
+
+			drifts, err := m.Check(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			shop, static, missing := drifts[0], drifts[1], drifts[2]
+			if shop.Err != nil {
+				t.Fatalf("legacy domain: %v", shop.Err)
+			}
+			want := []profile.FieldDrift{{Field: "fastScans", Current: "/ /", Desired: "https://shop.example.com/ https://shop.example.com/shop/"}}
+			if !reflect.DeepEqual(shop.Fields, want) || len(shop.Configs) != 0 {
+				t.Errorf("shop drift = %+v %+v, want %+v", shop.Fields, shop.Configs, want)
+			}
+
+			if static.Err != nil {
+				t.Fatal(static.Err)
+			}
+			if len(static.Fields) != 0 || !reflect.DeepEqual(static.Configs, tt.static) {
+				t.Errorf("static drift = %+v %+v, want %+v", static.Fields, static.Configs, tt.static)
+			}
+
+			if !nimbusec.IsNotFound(missing.Err) {
+				t.Errorf("missing domain: error = %v, want not found", missing.Err)
+			}
+
+			var report strings.Builder
+			if err := profile.WriteReport(&report, drifts); err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range []string{"config depth: \"2\", not in profile", "missing.example.com", "2 of 3 domains diverged"} {
+				if !strings.Contains(report.String(), line) {
+					t.Errorf("report misses %q:\n%s", line, report.String())
+				}
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	set, err := profile.ParseYAML([]byte(profiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := account(t)
+	m := &profile.Manager{API: srv.API(), Profiles: set}
+	if _, err := m.Apply(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range srv.Domains() {
+		switch d.Name {
+		case "shop.example.com":
+			if want := []string{"https://shop.example.com/", "https://shop.example.com/shop/"}; !reflect.DeepEqual(d.FastScans, want) {
+				t.Errorf("shop landing pages = %v, want %v", d.FastScans, want)
+			}
+			if d.DeepScan != "/" {
+				t.Errorf("shop deep scan = %q, want it unchanged", d.DeepScan)
+			}
+		case "static.example.com":
+			if want := map[string]string{"notify": "true", "custom": "x"}; !reflect.DeepEqual(srv.DomainConfigs(d.Id), want) {
+				t.Errorf("static configs = %v, want %v", srv.DomainConfigs(d.Id), want)
+			}
+		}
+	}
+
+	// a second run finds nothing left to do.
+	drifts, err := m.Check(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range drifts[:2] {
+		if d.Err != nil || d.Diverged() {
+			t.Errorf("%s still diverged: %+v %+v %v", d.Name, d.Fields, d.Configs, d.Err)
+		}
+	}
+}
+
+func TestCheckCancelled(t *testing.T) {
+	set, err := profile.ParseYAML([]byte(profiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	m := &profile.Manager{API: account(t).API(), Profiles: set, Concurrency: 1}
+	drifts, err := m.Check(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	for _, d := range drifts {
+		if !errors.Is(d.Err, context.Canceled) {
+			t.Errorf("%s: error = %v, want context.Canceled", d.Name, d.Err)
+		}
+	}
+}
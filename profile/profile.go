@@ -0,0 +1,225 @@
+// Package profile applies configuration profiles to groups of domains.
+// Settings are layered: the base applies to every domain, a profile to the
+// domains assigned to it, and per-domain overrides to a single domain:
+//
+//	base:
+//	  configs:
+//	    notifyBlacklist: "true"
+//	profiles:
+//	  wordpress-shop:
+//	    fastScans: [/, /shop/, /wp-login.php]
+//	    configs:
+//	      scanDepth: "5"
+//	  static-site:
+//	    unset: [scanDepth]
+//	domains:
+//	  - name: shop.example.com
+//	    profile: wordpress-shop
+//	    configs:
+//	      scanDepth: "3"
+//
+// Later layers replace the settings of earlier ones; "unset" removes an
+// inherited config key and deletes it from the domain. Relative landing
+// pages are resolved against each domain. A Manager compares the effective
+// settings with the account, reports domains that drifted from their
+// profile and brings them back in line.
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+	"gopkg.in/yaml.v3"
+)
+
+// Layer is a set of settings. Empty fields do not change inherited values.
+type Layer struct {
+	Bundle    string            `json:"bundle,omitempty" yaml:"bundle,omitempty"`
+	Scheme    string            `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	DeepScan  string            `json:"deepScan,omitempty" yaml:"deepScan,omitempty"`   // url or path of the deep scan
+	FastScans []string          `json:"fastScans,omitempty" yaml:"fastScans,omitempty"` // urls or paths of the landing pages
+	Configs   map[string]string `json:"configs,omitempty" yaml:"configs,omitempty"`
+	Unset     []string          `json:"unset,omitempty" yaml:"unset,omitempty"` // inherited config keys to remove
+}
+
+// Assignment assigns a profile to a domain, with optional overrides.
+type Assignment struct {
+	Name    string `json:"name" yaml:"name"`
+	Profile string `json:"profile" yaml:"profile"`
+	Layer   `yaml:",inline"`
+}
+
+// Set is a base layer, named profiles and their assignment to domains.
+type Set struct {
+	Base     Layer            `json:"base" yaml:"base"`
+	Profiles map[string]Layer `json:"profiles" yaml:"profiles"`
+	Domains  []Assignment     `json:"domains" yaml:"domains"`
+}
+
+// Load reads a profile set from a YAML or JSON file, depending on its
+// extension.
+func Load(filename string) (*Set, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	}
+
+	return nil, fmt.Errorf("profile: unknown file format %q", filepath.Ext(filename))
+}
+
+// ParseJSON parses and validates a JSON profile set.
+func ParseJSON(data []byte) (*Set, error) {
+	set := new(Set)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(set); err != nil {
+		return nil, fmt.Errorf("profile: %v", err)
+	}
+	return set, set.Validate()
+}
+
+// ParseYAML parses and validates a YAML profile set.
+func ParseYAML(data []byte) (*Set, error) {
+	set := new(Set)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(set); err != nil {
+		return nil, fmt.Errorf("profile: %v", err)
+	}
+	return set, set.Validate()
+}
+
+// Validate checks that every domain is assigned once and to a known profile.
+func (s *Set) Validate() error {
+	names := make(map[string]bool)
+	for i, a := range s.Domains {
+		name := strings.ToLower(a.Name)
+		switch {
+		case a.Name == "":
+			return fmt.Errorf("profile: domain %d: missing name", i+1)
+		case names[name]:
+			return fmt.Errorf("profile: domain %d (%s): assigned twice", i+1, a.Name)
+		case a.Profile == "":
+			return fmt.Errorf("profile: domain %d (%s): missing profile", i+1, a.Name)
+		}
+		if _, ok := s.Profiles[a.Profile]; !ok {
+			return fmt.Errorf("profile: domain %d (%s): unknown profile %q", i+1, a.Name, a.Profile)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// Effective returns the merged layers for an assignment: base, its profile
+// and its overrides. Unset of the result lists the keys removed by a layer
+// and not set again by a later one.
+func (s *Set) Effective(a Assignment) Layer {
+	effective := Layer{Configs: map[string]string{}}
+	for _, layer := range []Layer{s.Base, s.Profiles[a.Profile], a.Layer} {
+		effective.merge(layer)
+	}
+	sort.Strings(effective.Unset)
+	return effective
+}
+
+// merge applies layer on top of l.
+func (l *Layer) merge(layer Layer) {
+	if layer.Bundle != "" {
+		l.Bundle = layer.Bundle
+	}
+	if layer.Scheme != "" {
+		l.Scheme = layer.Scheme
+	}
+	if layer.DeepScan != "" {
+		l.DeepScan = layer.DeepScan
+	}
+	if layer.FastScans != nil {
+		l.FastScans = layer.FastScans
+	}
+	for _, key := range layer.Unset {
+		delete(l.Configs, key)
+		if !contains(l.Unset, key) {
+			l.Unset = append(l.Unset, key)
+		}
+	}
+	for key, value := range layer.Configs {
+		l.Configs[key] = value
+		l.Unset = remove(l.Unset, key)
+	}
+}
+
+// apply returns the domain with the settings of the layer. Relative urls of
+// the layer are resolved against the domain; fields the layer does not set
+// are kept as they are, even if they would not pass Domain.Validate.
+func (l Layer) apply(domain nimbusec.Domain) (nimbusec.Domain, error) {
+	if l.Bundle != "" {
+		domain.Bundle = l.Bundle
+	}
+
+	resolved := nimbusec.Domain{
+		Name:      domain.Name,
+		Scheme:    domain.Scheme,
+		DeepScan:  l.DeepScan,
+		FastScans: append([]string(nil), l.FastScans...),
+	}
+	if l.Scheme != "" {
+		resolved.Scheme = l.Scheme
+	}
+	if err := resolved.Normalize(); err != nil {
+		return domain, err
+	}
+
+	if l.Scheme != "" {
+		domain.Scheme = resolved.Scheme
+	}
+	if l.DeepScan != "" {
+		domain.DeepScan = resolved.DeepScan
+	}
+	if l.FastScans != nil {
+		domain.FastScans = resolved.FastScans
+	}
+	return domain, nil
+}
+
+// ProfileNames returns the names of all profiles, sorted.
+func (s *Set) ProfileNames() []string {
+	names := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// remove returns list without s.
+func remove(list []string, s string) []string {
+	kept := list[:0]
+	for _, item := range list {
+		if item != s {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
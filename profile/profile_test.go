@@ -0,0 +1,149 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+const profiles = `
+base:
+  bundle: basic
+  configs:
+    notify: "true"
+    depth: "1"
+profiles:
+  shop:
+    fastScans: [/, /shop/]
+    configs:
+      depth: "5"
+  static:
+    unset: [depth, notify]
+domains:
+  - name: shop.example.com
+    profile: shop
+    configs:
+      depth: "3"
+  - name: static.example.com
+    profile: static
+    configs:
+      notify: "false"
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (*Set, error)
+		data  string
+		err   bool
+	}{
+		{"yaml", ParseYAML, profiles, false},
+		{"json", ParseJSON, `{"profiles": {"a": {}}, "domains": [{"name": "example.com", "profile": "a"}]}`, false},
+		{"unknown field", ParseYAML, "base:\n  color: red\n", true},
+		{"unknown profile", ParseJSON, `{"domains": [{"name": "example.com", "profile": "a"}]}`, true},
+		{"missing profile", ParseJSON, `{"profiles": {"a": {}}, "domains": [{"name": "example.com"}]}`, true},
+		{"assigned twice", ParseYAML, "profiles: {a: {}}\ndomains: [{name: a.com, profile: a}, {name: A.com, profile: a}]\n", true},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.parse([]byte(tt.data))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"profiles.yml": profiles, "profiles.txt": profiles} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set, err := Load(filepath.Join(dir, "profiles.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := set.ProfileNames(); !reflect.DeepEqual(names, []string{"shop", "static"}) {
+		t.Errorf("profiles = %v, want [shop static]", names)
+	}
+
+	if _, err := Load(filepath.Join(dir, "profiles.txt")); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestEffective(t *testing.T) {
+	set, err := ParseYAML([]byte(profiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		assignment Assignment
+		want       Layer
+	}{
+		{"override", set.Domains[0], Layer{
+			Bundle:    "basic",
+			FastScans: []string{"/", "/shop/"},
+			Configs:   map[string]string{"notify": "true", "depth": "3"},
+		}},
+		{"unset", set.Domains[1], Layer{
+			Bundle:  "basic",
+			Configs: map[string]string{"notify": "false"},
+			Unset:   []string{"depth"},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := set.Effective(tt.assignment); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: effective = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLayerApply(t *testing.T) {
+	// a domain from before validation, with a relative deep scan and
+	// duplicate landing pages.
+	legacy := nimbusec.Domain{
+		Id:        1,
+		Name:      "example.com",
+		Scheme:    "https",
+		Bundle:    "basic",
+		DeepScan:  "/",
+		FastScans: []string{"https://example.com/", "https://example.com/"},
+	}
+
+	tests := []struct {
+		name  string
+		layer Layer
+		want  nimbusec.Domain
+		err   bool
+	}{
+		{"keeps unmanaged fields", Layer{Bundle: "pro"}, nimbusec.Domain{
+			Id: 1, Name: "example.com", Scheme: "https", Bundle: "pro", DeepScan: "/",
+			FastScans: []string{"https://example.com/", "https://example.com/"},
+		}, false},
+		{"resolves layer urls", Layer{Scheme: "HTTP", DeepScan: "/start", FastScans: []string{"/", "/shop/", "/"}}, nimbusec.Domain{
+			Id: 1, Name: "example.com", Scheme: "http", Bundle: "basic", DeepScan: "http://example.com/start",
+			FastScans: []string{"http://example.com/", "http://example.com/shop/"},
+		}, false},
+		{"invalid layer url", Layer{DeepScan: "http://[::1"}, nimbusec.Domain{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.layer.apply(legacy)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: domain = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package nimbusec
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit configures the client side rate limiter of the API client. The
+// limiter is a token bucket shared by all goroutines using the client.
+type RateLimit struct {
+	Rate  float64 // sustained number of requests per second
+	Burst int     // maximum number of requests sent at once
+
+	// WriteRate and WriteBurst configure a separate budget for POST, PUT and
+	// DELETE requests. If WriteRate is zero, writes share the budget with reads.
+	WriteRate  float64
+	WriteBurst int
+
+	// OnWait is called whenever a request had to wait for the limiter.
+	OnWait func(method string, wait time.Duration)
+}
+
+// SetRateLimit enables client side rate limiting. A nil limit disables rate
+// limiting, which is the default. SetRateLimit must not be called while
+// requests are in flight.
+func (a *API) SetRateLimit(limit *RateLimit) {
+	if limit == nil {
+		a.limiter = nil
+		return
+	}
+
+	l := &limiter{
+		reads:  newBucket(limit.Rate, limit.Burst),
+		onWait: limit.OnWait,
+	}
+
+	l.writes = l.reads
+	if limit.WriteRate > 0 {
+		l.writes = newBucket(limit.WriteRate, limit.WriteBurst)
+	}
+
+	a.limiter = l
+}
+
+// limiter holds the token buckets for read and write requests.
+type limiter struct {
+	reads  *bucket
+	writes *bucket
+	onWait func(method string, wait time.Duration)
+}
+
+// wait blocks until the request with the given method may be sent or ctx is done.
+func (l *limiter) wait(ctx context.Context, method string) error {
+	if l == nil {
+		return nil
+	}
+
+	b := l.reads
+	if method != "GET" && method != "HEAD" {
+		b = l.writes
+	}
+
+	wait, err := b.wait(ctx)
+	if wait > 0 && l.onWait != nil {
+		l.onWait(method, wait)
+	}
+
+	return err
+}
+
+// bucket is a token bucket refilled with rate tokens per second up to burst.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait takes a token from the bucket and blocks until it is available. The
+// token is returned if ctx is done before that.
+func (b *bucket) wait(ctx context.Context) (time.Duration, error) {
+	if b.rate <= 0 {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if wait == 0 {
+		return 0, nil
+	}
+
+	if err := sleep(ctx, wait); err != nil {
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return wait, err
+	}
+
+	return wait, nil
+}
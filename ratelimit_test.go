@@ -0,0 +1,83 @@
+package nimbusec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBucketWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    int
+		requests int
+		waits    int // number of requests that had to wait
+	}{
+		{"disabled", 0, 0, 10, 0},
+		{"within burst", 100, 5, 5, 0},
+		{"beyond burst", 100, 2, 4, 2},
+		{"burst of zero", 100, 0, 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBucket(tt.rate, tt.burst)
+
+			waits := 0
+			for i := 0; i < tt.requests; i++ {
+				wait, err := b.wait(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wait > 0 {
+					waits++
+				}
+			}
+
+			if waits != tt.waits {
+				t.Errorf("waits = %d, want %d", waits, tt.waits)
+			}
+		})
+	}
+}
+
+func TestBucketWaitCancelled(t *testing.T) {
+	b := newBucket(0.1, 1)
+	if _, err := b.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := b.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// the token of the cancelled request is returned to the bucket.
+	if b.tokens < -0.01 {
+		t.Errorf("tokens = %v, want about 0", b.tokens)
+	}
+}
+
+func TestLimiterSeparatesWrites(t *testing.T) {
+	a := &API{}
+	a.SetRateLimit(&RateLimit{Rate: 0.1, Burst: 1, WriteRate: 0.1, WriteBurst: 1})
+
+	ctx := context.Background()
+	if err := a.limiter.wait(ctx, "GET"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the write budget is untouched by the read before.
+	var waited time.Duration
+	a.limiter.onWait = func(method string, wait time.Duration) { waited = wait }
+	if err := a.limiter.wait(ctx, "PUT"); err != nil {
+		t.Fatal(err)
+	}
+	if waited != 0 {
+		t.Errorf("write waited %v, want no wait", waited)
+	}
+}
@@ -0,0 +1,143 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Action is the kind of change planned for a domain.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	ActionNoop   Action = "no-op"
+)
+
+// Change describes everything that has to be done for a single domain.
+type Change struct {
+	Action  Action
+	Name    string
+	Current *nimbusec.Domain // nil for created domains
+	Desired *nimbusec.Domain // nil for deleted domains
+	Fields  []string         // changed domain fields
+
+	SetConfigs    map[string]string // configs to create or change
+	DeleteConfigs []string          // configs to remove
+	Link          []string          // logins of users to link
+	Unlink        []string          // logins of users to unlink
+}
+
+// Plan is the list of changes to reconcile an account with a spec, ordered
+// by domain name.
+type Plan struct {
+	Changes []Change
+
+	users map[string]*linkedUser // users of the spec by login
+}
+
+// Empty reports whether the plan changes nothing.
+func (p *Plan) Empty() bool {
+	for _, c := range p.Changes {
+		if c.Action != ActionNoop {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns the number of changes with the given action.
+func (p *Plan) Count(action Action) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+var symbols = map[Action]string{
+	ActionCreate: "+",
+	ActionUpdate: "~",
+	ActionDelete: "-",
+	ActionNoop:   "=",
+}
+
+// WriteTo writes a human readable form of the plan to w.
+func (p *Plan) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, c := range p.Changes {
+		fmt.Fprintf(&b, "%s %s %s", symbols[c.Action], c.Action, c.Name)
+		if len(c.Fields) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(c.Fields, ", "))
+		}
+		b.WriteString("\n")
+
+		keys := make([]string, 0, len(c.SetConfigs))
+		for key := range c.SetConfigs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "    set config %s = %q\n", key, c.SetConfigs[key])
+		}
+		for _, key := range c.DeleteConfigs {
+			fmt.Fprintf(&b, "    delete config %s\n", key)
+		}
+		for _, login := range c.Link {
+			fmt.Fprintf(&b, "    link user %s\n", login)
+		}
+		for _, login := range c.Unlink {
+			fmt.Fprintf(&b, "    unlink user %s\n", login)
+		}
+	}
+
+	fmt.Fprintf(&b, "%d to create, %d to update, %d to delete, %d unchanged\n",
+		p.Count(ActionCreate), p.Count(ActionUpdate), p.Count(ActionDelete), p.Count(ActionNoop))
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// diffDomain returns the names of the fields that differ between the
+// current and desired domain.
+func diffDomain(current, desired nimbusec.Domain) []string {
+	fields := make([]string, 0)
+	if current.Scheme != desired.Scheme {
+		fields = append(fields, fmt.Sprintf("scheme %s -> %s", current.Scheme, desired.Scheme))
+	}
+	if current.Bundle != desired.Bundle {
+		fields = append(fields, fmt.Sprintf("bundle %s -> %s", current.Bundle, desired.Bundle))
+	}
+	if current.DeepScan != desired.DeepScan {
+		fields = append(fields, fmt.Sprintf("deepScan %s -> %s", current.DeepScan, desired.DeepScan))
+	}
+	if !sameSet(current.FastScans, desired.FastScans) {
+		fields = append(fields, "fastScans")
+	}
+	return fields
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	count := make(map[string]int, len(a))
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		count[s]--
+		if count[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
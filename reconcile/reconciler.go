@@ -0,0 +1,274 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// Reconciler compares a spec with the account and applies the differences.
+type Reconciler struct {
+	API     *nimbusec.API
+	Domains string    // filter selecting the managed domains, defaults to all
+	Prune   bool      // delete domains, configs and user links not in the spec
+	Clean   bool      // also delete the results of pruned domains
+	DryRun  bool      // only print the plan
+	Out     io.Writer // receives the plan, if set
+}
+
+// Run plans the changes for spec, writes the plan to Out and applies it
+// unless DryRun is set.
+func (r *Reconciler) Run(ctx context.Context, spec *Spec) (*Plan, error) {
+	plan, err := r.Plan(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Out != nil {
+		if _, err := plan.WriteTo(r.Out); err != nil {
+			return plan, err
+		}
+	}
+
+	if r.DryRun {
+		return plan, nil
+	}
+	return plan, r.Apply(ctx, plan)
+}
+
+// Plan computes the changes required to reconcile the account with spec.
+func (r *Reconciler) Plan(ctx context.Context, spec *Spec) (*Plan, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	domains, err := r.API.FindDomainsContext(ctx, r.Domains)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]*nimbusec.Domain, len(domains))
+	for i := range domains {
+		current[strings.ToLower(domains[i].Name)] = &domains[i]
+	}
+
+	users, err := r.users(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{users: users}
+	desired := make(map[string]bool, len(spec.Domains))
+	for _, d := range spec.Domains {
+		name := strings.ToLower(d.Name)
+		desired[name] = true
+
+		change, err := r.change(ctx, d, current[name], users)
+		if err != nil {
+			return nil, fmt.Errorf("reconcile: %s: %v", d.Name, err)
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if r.Prune {
+		for name, domain := range current {
+			if !desired[name] {
+				plan.Changes = append(plan.Changes, Change{
+					Action:  ActionDelete,
+					Name:    domain.Name,
+					Current: domain,
+				})
+			}
+		}
+	}
+
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Name < plan.Changes[j].Name
+	})
+	return plan, nil
+}
+
+// users resolves all logins of the spec and fetches their domain sets.
+func (r *Reconciler) users(ctx context.Context, spec *Spec) (map[string]*linkedUser, error) {
+	users := make(map[string]*linkedUser)
+	for _, d := range spec.Domains {
+		for _, login := range d.Users {
+			if _, ok := users[login]; ok {
+				continue
+			}
+
+			user, err := r.API.GetUserByLoginContext(ctx, login)
+			if err != nil {
+				return nil, fmt.Errorf("reconcile: user %s: %v", login, err)
+			}
+
+			set, err := r.API.GetDomainSetContext(ctx, user)
+			if err != nil {
+				return nil, fmt.Errorf("reconcile: user %s: %v", login, err)
+			}
+
+			linked := &linkedUser{User: user, domains: make(map[int]bool, len(set))}
+			for _, id := range set {
+				linked.domains[id] = true
+			}
+			users[login] = linked
+		}
+	}
+	return users, nil
+}
+
+// linkedUser is a user of the spec together with its linked domains.
+type linkedUser struct {
+	*nimbusec.User
+	domains map[int]bool
+}
+
+func (r *Reconciler) change(ctx context.Context, spec DomainSpec, current *nimbusec.Domain, users map[string]*linkedUser) (Change, error) {
+	desired, err := spec.validDomain(current)
+	if err != nil {
+		return Change{}, err
+	}
+
+	change := Change{
+		Name:       spec.Name,
+		Current:    current,
+		Desired:    &desired,
+		SetConfigs: map[string]string{},
+	}
+
+	if current == nil {
+		change.Action = ActionCreate
+		for key, value := range spec.Configs {
+			change.SetConfigs[key] = value
+		}
+		change.Link = append(change.Link, spec.Users...)
+		sort.Strings(change.Link)
+		return change, nil
+	}
+
+	change.Fields = diffDomain(*current, desired)
+
+	keys, err := r.API.ListDomainConfigsContext(ctx, current.Id)
+	if err != nil {
+		return change, err
+	}
+
+	existing := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		existing[key] = true
+		if _, ok := spec.Configs[key]; !ok && r.Prune {
+			change.DeleteConfigs = append(change.DeleteConfigs, key)
+		}
+	}
+	sort.Strings(change.DeleteConfigs)
+
+	for key, value := range spec.Configs {
+		if existing[key] {
+			currentValue, err := r.API.GetDomainConfigContext(ctx, current.Id, key)
+			if err != nil {
+				return change, err
+			}
+			if currentValue == value {
+				continue
+			}
+		}
+		change.SetConfigs[key] = value
+	}
+
+	wanted := make(map[string]bool, len(spec.Users))
+	for _, login := range spec.Users {
+		wanted[login] = true
+		if !users[login].domains[current.Id] {
+			change.Link = append(change.Link, login)
+		}
+	}
+	if r.Prune {
+		for login, user := range users {
+			if !wanted[login] && user.domains[current.Id] {
+				change.Unlink = append(change.Unlink, login)
+			}
+		}
+	}
+	sort.Strings(change.Link)
+	sort.Strings(change.Unlink)
+
+	change.Action = ActionNoop
+	if len(change.Fields) > 0 || len(change.SetConfigs) > 0 || len(change.DeleteConfigs) > 0 ||
+		len(change.Link) > 0 || len(change.Unlink) > 0 {
+		change.Action = ActionUpdate
+	}
+	return change, nil
+}
+
+// Apply executes the changes of the plan in order. It stops at the first
+// failing change.
+func (r *Reconciler) Apply(ctx context.Context, plan *Plan) error {
+	for _, change := range plan.Changes {
+		if err := r.apply(ctx, plan, change); err != nil {
+			return fmt.Errorf("reconcile: %s %s: %v", change.Action, change.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) apply(ctx context.Context, plan *Plan, change Change) error {
+	var id int
+	switch change.Action {
+	case ActionNoop:
+		return nil
+
+	case ActionDelete:
+		return r.API.DeleteDomainContext(ctx, change.Current, r.Clean)
+
+	case ActionCreate:
+		created, err := r.API.CreateDomainContext(ctx, change.Desired)
+		if err != nil {
+			return err
+		}
+		id = created.Id
+
+	case ActionUpdate:
+		id = change.Current.Id
+		if len(change.Fields) > 0 {
+			if _, err := r.API.UpdateDomainContext(ctx, change.Desired); err != nil {
+				return err
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(change.SetConfigs))
+	for key := range change.SetConfigs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, err := r.API.SetDomainConfigContext(ctx, id, key, change.SetConfigs[key]); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range change.DeleteConfigs {
+		if err := r.API.DeleteDomainConfigContext(ctx, id, key); err != nil {
+			return err
+		}
+	}
+
+	for _, login := range change.Link {
+		if err := r.API.LinkDomainContext(ctx, plan.users[login].User, id); err != nil {
+			return err
+		}
+	}
+
+	for _, login := range change.Unlink {
+		if err := r.API.UnlinkDomainContext(ctx, plan.users[login].User, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package reconcile_test
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+	"github.com/cumulodev/nimbusec/reconcile"
+)
+
+// account is the state of the fake server before reconciling.
+type account struct {
+	srv    *nimbusectest.Server
+	legacy nimbusec.Domain
+	gone   nimbusec.Domain
+	alice  nimbusec.User
+	bob    nimbusec.User
+}
+
+func newAccount(t *testing.T) *account {
+	srv := nimbusectest.NewServer()
+	t.Cleanup(srv.Close)
+	api := srv.API()
+
+	a := &account{srv: srv}
+	// legacy data does not pass Domain.Validate, but must still be managed.
+	a.legacy = srv.AddDomain(nimbusec.Domain{Name: "legacy.example.com", DeepScan: "/", FastScans: []string{"/", "/"}})
+	a.gone = srv.AddDomain(nimbusec.Domain{Name: "gone.example.com", Scheme: "https"})
+	a.alice = srv.AddUser(nimbusec.User{Login: "alice"})
+	a.bob = srv.AddUser(nimbusec.User{Login: "bob"})
+
+	for key, value := range map[string]string{"a": "1", "old": "x"} {
+		if _, err := api.SetDomainConfig(a.legacy.Id, key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := api.LinkDomain(&a.alice, a.legacy.Id); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+var spec = &reconcile.Spec{Domains: []reconcile.DomainSpec{
+	{Name: "legacy.example.com", Configs: map[string]string{"a": "2"}, Users: []string{"bob"}},
+	{Name: "new.example.com", Scheme: "https", Configs: map[string]string{"b": "3"}, Users: []string{"alice"}},
+}}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name    string
+		prune   bool
+		actions []reconcile.Action // by domain name
+		configs map[string]string  // of the legacy domain afterwards
+		users   []string           // linked to the legacy domain afterwards
+		domains int
+	}{
+		{
+			"without prune", false,
+			[]reconcile.Action{reconcile.ActionUpdate, reconcile.ActionCreate},
+			map[string]string{"a": "2", "old": "x"},
+			[]string{"alice", "bob"},
+			3,
+		},
+		{
+			"with prune", true,
+			[]reconcile.Action{reconcile.ActionDelete, reconcile.ActionUpdate, reconcile.ActionCreate},
+			map[string]string{"a": "2"},
+			[]string{"bob"},
+			2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAccount(t)
+
+			var out bytes.Buffer
+			r := &reconcile.Reconciler{API: a.srv.API(), Prune: tt.prune, Out: &out}
+			plan, err := r.Run(t.Context(), spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actions := make([]reconcile.Action, len(plan.Changes))
+			for i, c := range plan.Changes {
+				actions[i] = c.Action
+			}
+			if !reflect.DeepEqual(actions, tt.actions) {
+				t.Errorf("actions = %v, want %v\n%s", actions, tt.actions, out.String())
+			}
+			if !strings.Contains(out.String(), "set config a = \"2\"") {
+				t.Errorf("plan does not mention the config change:\n%s", out.String())
+			}
+
+			if n := len(a.srv.Domains()); n != tt.domains {
+				t.Errorf("account has %d domains, want %d", n, tt.domains)
+			}
+
+			if got := a.srv.DomainConfigs(a.legacy.Id); !reflect.DeepEqual(got, tt.configs) {
+				t.Errorf("configs = %v, want %v", got, tt.configs)
+			}
+
+			users := make([]string, 0)
+			for _, user := range []nimbusec.User{a.alice, a.bob} {
+				if slices.Contains(a.srv.UserDomains(user.Id), a.legacy.Id) {
+					users = append(users, user.Login)
+				}
+			}
+			if !reflect.DeepEqual(users, tt.users) {
+				t.Errorf("linked users = %v, want %v", users, tt.users)
+			}
+
+			for _, domain := range a.srv.Domains() {
+				if domain.Id == a.legacy.Id && domain.DeepScan != "/" {
+					t.Errorf("unmanaged deep scan changed to %q", domain.DeepScan)
+				}
+			}
+
+			// a second run has nothing left to do.
+			plan, err = r.Plan(t.Context(), spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !plan.Empty() {
+				var buf bytes.Buffer
+				plan.WriteTo(&buf)
+				t.Errorf("second plan is not empty:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	a := newAccount(t)
+
+	r := &reconcile.Reconciler{API: a.srv.API(), Prune: true, DryRun: true}
+	plan, err := r.Run(t.Context(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Empty() {
+		t.Fatal("plan is empty")
+	}
+
+	if n := len(a.srv.Domains()); n != 2 {
+		t.Errorf("dry run changed the domains, got %d", n)
+	}
+	if got := a.srv.DomainConfigs(a.legacy.Id); got["a"] != "1" {
+		t.Errorf("dry run changed the configs to %v", got)
+	}
+}
+
+func TestPlanNormalizesSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		domain  reconcile.DomainSpec
+		desired *nimbusec.Domain // nil if planning fails
+	}{
+		{
+			"relative urls",
+			reconcile.DomainSpec{Name: "shop.example.com", DeepScan: "/start", FastScans: []string{"/", "shop/", "/"}},
+			&nimbusec.Domain{Name: "shop.example.com", Scheme: "https", DeepScan: "https://shop.example.com/start",
+				FastScans: []string{"https://shop.example.com/", "https://shop.example.com/shop/"}},
+		},
+		{
+			"legacy urls without scheme",
+			reconcile.DomainSpec{Name: "legacy.example.com", FastScans: []string{"/"}},
+			nil,
+		},
+		{
+			"landing page of another host",
+			reconcile.DomainSpec{Name: "shop.example.com", FastScans: []string{"https://example.org/"}},
+			nil,
+		},
+		{
+			"new domain",
+			reconcile.DomainSpec{Name: "Bücher.example", Scheme: "https", FastScans: []string{"/"}},
+			&nimbusec.Domain{Name: "xn--bcher-kva.example", Scheme: "https", FastScans: []string{"https://xn--bcher-kva.example/"}},
+		},
+		{
+			"new domain without scheme",
+			reconcile.DomainSpec{Name: "new.example.com"},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := nimbusectest.NewServer()
+			defer srv.Close()
+			srv.AddDomain(nimbusec.Domain{Name: "legacy.example.com", DeepScan: "/"})
+			shop := srv.AddDomain(nimbusec.Domain{Name: "shop.example.com", Scheme: "https"})
+
+			r := &reconcile.Reconciler{API: srv.API()}
+			plan, err := r.Plan(t.Context(), &reconcile.Spec{Domains: []reconcile.DomainSpec{tt.domain}})
+			if tt.desired == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", plan.Changes[0].Desired)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			desired := *plan.Changes[0].Desired
+			if plan.Changes[0].Current != nil {
+				tt.desired.Id = shop.Id
+			}
+			if !reflect.DeepEqual(desired, *tt.desired) {
+				t.Errorf("desired = %+v, want %+v", desired, *tt.desired)
+			}
+		})
+	}
+}
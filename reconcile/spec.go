@@ -0,0 +1,180 @@
+// Package reconcile brings the domains of a nimbusec account in line with a
+// declarative inventory. The desired domains are loaded from YAML or JSON:
+//
+//	domains:
+//	  - name: www.example.com
+//	    scheme: https
+//	    bundle: 3a8f0c2d
+//	    deepScan: https://www.example.com/
+//	    fastScans:
+//	      - https://www.example.com/
+//	      - https://www.example.com/shop/
+//	    configs:
+//	      notifyBlacklist: "true"
+//	    users: [alice, bob]
+//
+// A Reconciler compares them with the domains, configs and user links of the
+// account, prints a plan and applies it. Without pruning, only missing or
+// differing settings are created or updated; with pruning, domains, configs
+// and user links that are not in the inventory are removed as well.
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+	"gopkg.in/yaml.v3"
+)
+
+// DomainSpec is the desired state of a single domain. Empty fields are not
+// managed and keep their current value.
+type DomainSpec struct {
+	Name      string            `json:"name" yaml:"name"`
+	Scheme    string            `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	Bundle    string            `json:"bundle,omitempty" yaml:"bundle,omitempty"`
+	DeepScan  string            `json:"deepScan,omitempty" yaml:"deepScan,omitempty"`
+	FastScans []string          `json:"fastScans,omitempty" yaml:"fastScans,omitempty"`
+	Configs   map[string]string `json:"configs,omitempty" yaml:"configs,omitempty"`
+	Users     []string          `json:"users,omitempty" yaml:"users,omitempty"` // logins of the linked users
+}
+
+// Spec is the desired set of domains.
+type Spec struct {
+	Domains []DomainSpec `json:"domains" yaml:"domains"`
+}
+
+// Load reads a spec from a YAML or JSON file, depending on its extension.
+func Load(filename string) (*Spec, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	}
+
+	return nil, fmt.Errorf("reconcile: unknown spec file format %q", filepath.Ext(filename))
+}
+
+// ParseJSON parses and validates a JSON spec.
+func ParseJSON(data []byte) (*Spec, error) {
+	spec := new(Spec)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("reconcile: %v", err)
+	}
+	return spec, spec.Validate()
+}
+
+// ParseYAML parses and validates a YAML spec.
+func ParseYAML(data []byte) (*Spec, error) {
+	spec := new(Spec)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("reconcile: %v", err)
+	}
+	return spec, spec.Validate()
+}
+
+// Validate checks that all domains are named uniquely and use a known
+// scheme.
+func (s *Spec) Validate() error {
+	names := make(map[string]bool)
+	for i, d := range s.Domains {
+		if err := d.Validate(); err != nil {
+			return fmt.Errorf("reconcile: domain %d (%s): %v", i+1, d.Name, err)
+		}
+
+		name := strings.ToLower(d.Name)
+		if names[name] {
+			return fmt.Errorf("reconcile: domain %d (%s): duplicate name", i+1, d.Name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// Validate checks the name and scheme of the domain.
+func (d DomainSpec) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if d.Scheme != "" && d.Scheme != "http" && d.Scheme != "https" {
+		return fmt.Errorf("invalid scheme %q", d.Scheme)
+	}
+	return nil
+}
+
+// domain returns the domain as it should be after applying the spec to
+// current, which may be nil for new domains.
+func (d DomainSpec) domain(current *nimbusec.Domain) nimbusec.Domain {
+	var domain nimbusec.Domain
+	if current != nil {
+		domain = *current
+	}
+
+	domain.Name = d.Name
+	if d.Scheme != "" {
+		domain.Scheme = d.Scheme
+	}
+	if d.Bundle != "" {
+		domain.Bundle = d.Bundle
+	}
+	if d.DeepScan != "" {
+		domain.DeepScan = d.DeepScan
+	}
+	if d.FastScans != nil {
+		domain.FastScans = d.FastScans
+	}
+	return domain
+}
+
+// validDomain is like domain, but normalizes the settings of the spec with
+// Domain.Normalize and checks them with Domain.Validate. New domains are
+// checked as a whole; for existing domains only the scheme and urls of the
+// spec are, so domains that predate validation can still be reconciled.
+func (d DomainSpec) validDomain(current *nimbusec.Domain) (nimbusec.Domain, error) {
+	desired := d.domain(current)
+	if current != nil && d.Scheme == "" && d.DeepScan == "" && d.FastScans == nil {
+		return desired, nil
+	}
+
+	managed := desired
+	if current != nil {
+		managed = nimbusec.Domain{
+			Name:      desired.Name,
+			Scheme:    desired.Scheme,
+			DeepScan:  d.DeepScan,
+			FastScans: append([]string(nil), d.FastScans...),
+		}
+	}
+	if err := managed.Normalize(); err != nil {
+		return desired, err
+	}
+	if err := managed.Validate(); err != nil {
+		return desired, err
+	}
+
+	if current == nil {
+		return managed, nil
+	}
+	desired.Scheme = managed.Scheme
+	if d.DeepScan != "" {
+		desired.DeepScan = managed.DeepScan
+	}
+	if d.FastScans != nil {
+		desired.FastScans = managed.FastScans
+	}
+	return desired, nil
+}
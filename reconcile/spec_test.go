@@ -0,0 +1,42 @@
+package reconcile_test
+
+import (
+	"testing"
+
+	"github.com/cumulodev/nimbusec/reconcile"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func([]byte) (*reconcile.Spec, error)
+		data    string
+		domains int
+		err     bool
+	}{
+		{"yaml", reconcile.ParseYAML, "domains:\n  - name: example.com\n    scheme: https\n    configs:\n      a: \"1\"\n    users: [alice]\n", 1, false},
+		{"json", reconcile.ParseJSON, `{"domains": [{"name": "example.com"}, {"name": "example.org", "fastScans": ["/"]}]}`, 2, false},
+		{"unknown field", reconcile.ParseYAML, "domains:\n  - name: example.com\n    shceme: https\n", 0, true},
+		{"missing name", reconcile.ParseJSON, `{"domains": [{"scheme": "https"}]}`, 0, true},
+		{"invalid scheme", reconcile.ParseJSON, `{"domains": [{"name": "example.com", "scheme": "ftp"}]}`, 0, true},
+		{"duplicate", reconcile.ParseYAML, "domains:\n  - name: example.com\n  - name: Example.com\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := tt.parse([]byte(tt.data))
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(spec.Domains) != tt.domains {
+				t.Errorf("got %d domains, want %d", len(spec.Domains), tt.domains)
+			}
+		})
+	}
+}
@@ -1,16 +1,128 @@
 package nimbusec
 
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// ResultStatus is the triage status of a result.
+type ResultStatus int
+
+const (
+	StatusPending       ResultStatus = 1 // result requires user action
+	StatusAcknowledged  ResultStatus = 2 // result was seen and accepted by the user
+	StatusFalsePositive ResultStatus = 3 // result was marked as false positive
+	StatusRemoved       ResultStatus = 4 // affected resource was removed
+)
+
+var statusNames = map[string]int{
+	"pending":       int(StatusPending),
+	"acknowledged":  int(StatusAcknowledged),
+	"falsepositive": int(StatusFalsePositive),
+	"removed":       int(StatusRemoved),
+}
+
+// Valid reports whether s is one of the known result states.
+func (s ResultStatus) Valid() bool {
+	return s >= StatusPending && s <= StatusRemoved
+}
+
+func (s ResultStatus) String() string {
+	for name, n := range statusNames {
+		if n == int(s) {
+			return name
+		}
+	}
+	return strconv.Itoa(int(s))
+}
+
+// MarshalJSON encodes the status as number, as expected by the nimbusec API.
+func (s ResultStatus) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Itoa(int(s))), nil
+}
+
+// UnmarshalJSON accepts the status as number or name.
+func (s *ResultStatus) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalEnum("result status", b, statusNames)
+	*s = ResultStatus(n)
+	return err
+}
+
+func (s ResultStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText accepts the status as number or name.
+func (s *ResultStatus) UnmarshalText(b []byte) error {
+	n, err := parseEnum("result status", string(b), statusNames)
+	*s = ResultStatus(n)
+	return err
+}
+
+// Severity is the severity level of a result.
+type Severity int
+
+const (
+	SeverityMedium Severity = 1
+	SeverityHigh   Severity = 2
+	SeveritySevere Severity = 3
+)
+
+var severityNames = map[string]int{
+	"medium": int(SeverityMedium),
+	"high":   int(SeverityHigh),
+	"severe": int(SeveritySevere),
+}
+
+// Valid reports whether s is one of the known severity levels.
+func (s Severity) Valid() bool {
+	return s >= SeverityMedium && s <= SeveritySevere
+}
+
+func (s Severity) String() string {
+	for name, n := range severityNames {
+		if n == int(s) {
+			return name
+		}
+	}
+	return strconv.Itoa(int(s))
+}
+
+// MarshalJSON encodes the severity as number, as expected by the nimbusec API.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Itoa(int(s))), nil
+}
+
+// UnmarshalJSON accepts the severity as number or name.
+func (s *Severity) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalEnum("severity", b, severityNames)
+	*s = Severity(n)
+	return err
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText accepts the severity as number or name.
+func (s *Severity) UnmarshalText(b []byte) error {
+	n, err := parseEnum("severity", string(b), severityNames)
+	*s = Severity(n)
+	return err
+}
+
 // Result represents a finding of the nimbusec service that requires user action.
 type Result struct {
-	Id           int     `json:"id,omitempty"` // unique identification of a result
-	Status       int     `json:"status"`       // status of the result (1 = pending, 2 = acknowledged, 3 = falsepositive, 4 = removed)
-	Event        string  `json:"event"`        // event type of result (e.g added file)
-	Category     string  `json:"category"`     // category of result
-	Severity     int     `json:"severity"`     // severity level of result (1 = medium to 3 = severe)
-	Probability  float64 `json:"probability"`  // probability the result is critical
-	SafeToDelete bool    `json:"safeToDelete"` // flag indicating if the file can be safely deleted without loosing user data
-	CreateDate   int     `json:"createDate"`   // timestamp (in ms) of the first occurrence
-	LastDate     int     `json:"lastDate"`     // timestamp (in ms) of the last occurrence
+	Id           int          `json:"id,omitempty"` // unique identification of a result
+	Status       ResultStatus `json:"status"`       // status of the result (1 = pending, 2 = acknowledged, 3 = falsepositive, 4 = removed)
+	Event        string       `json:"event"`        // event type of result (e.g added file)
+	Category     string       `json:"category"`     // category of result
+	Severity     Severity     `json:"severity"`     // severity level of result (1 = medium to 3 = severe)
+	Probability  float64      `json:"probability"`  // probability the result is critical
+	SafeToDelete bool         `json:"safeToDelete"` // flag indicating if the file can be safely deleted without loosing user data
+	CreateDate   Timestamp    `json:"createDate"`   // timestamp (in ms) of the first occurrence
+	LastDate     Timestamp    `json:"lastDate"`     // timestamp (in ms) of the last occurrence
 
 	// the following fields contain more details about the result. Not all fields
 	// must be filled or present.
@@ -26,16 +138,38 @@ type Result struct {
 	Reason     string `json:"reason"`     // reason why a domain/URL is blacklisted
 }
 
+// IsPending reports whether the result still requires user action.
+func (r Result) IsPending() bool { return r.Status == StatusPending }
+
+// IsAcknowledged reports whether the result was acknowledged.
+func (r Result) IsAcknowledged() bool { return r.Status == StatusAcknowledged }
+
+// IsFalsePositive reports whether the result was marked as false positive.
+func (r Result) IsFalsePositive() bool { return r.Status == StatusFalsePositive }
+
+// IsRemoved reports whether the affected resource was removed.
+func (r Result) IsRemoved() bool { return r.Status == StatusRemoved }
+
 // GetResult fetches a result by its ID.
 func (a *API) GetResult(domain, result int) (*Result, error) {
+	return a.GetResultContext(context.Background(), domain, result)
+}
+
+// GetResultContext is like GetResult but with a context.
+func (a *API) GetResultContext(ctx context.Context, domain, result int) (*Result, error) {
 	dst := new(Result)
 	url := a.BuildURL("/v2/domain/%d/result/%d", domain, result)
-	err := a.Get(url, Params{}, dst)
+	err := a.GetContext(ctx, url, Params{}, dst)
 	return dst, err
 }
 
 // FindResults searches for results that match the given filter criteria.
 func (a *API) FindResults(domain int, filter string) ([]Result, error) {
+	return a.FindResultsContext(context.Background(), domain, filter)
+}
+
+// FindResultsContext is like FindResults but with a context.
+func (a *API) FindResultsContext(ctx context.Context, domain int, filter string) ([]Result, error) {
 	params := make(map[string]string)
 	if filter != EmptyFilter {
 		params["q"] = filter
@@ -43,15 +177,24 @@ func (a *API) FindResults(domain int, filter string) ([]Result, error) {
 
 	dst := make([]Result, 0)
 	url := a.BuildURL("/v2/domain/%d/result", domain)
-	err := a.Get(url, params, &dst)
+	err := a.GetContext(ctx, url, params, &dst)
 	return dst, err
 }
 
 // UpdateResult issues the nimbusec API to update a result, all fields except
 // status will be ignored.
 func (a *API) UpdateResult(domain int, result *Result) (*Result, error) {
+	return a.UpdateResultContext(context.Background(), domain, result)
+}
+
+// UpdateResultContext is like UpdateResult but with a context.
+func (a *API) UpdateResultContext(ctx context.Context, domain int, result *Result) (*Result, error) {
+	if !result.Status.Valid() {
+		return nil, fmt.Errorf("nimbusec: invalid result status %d", result.Status)
+	}
+
 	dst := new(Result)
 	url := a.BuildURL("/v2/domain/%d/result/%d", domain, result.Id)
-	err := a.Put(url, Params{}, result, dst)
+	err := a.PutContext(ctx, url, Params{}, result, dst)
 	return dst, err
 }
@@ -0,0 +1,150 @@
+package nimbusec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures how requests failing with a transient error are
+// retried. Transient errors are timeouts, refused or reset connections and the
+// status codes 429, 502, 503 and 504. Other errors, e.g. TLS or certificate
+// errors, are permanent and never retried.
+type RetryPolicy struct {
+	MaxAttempts int           // total number of attempts, including the first one
+	MinBackoff  time.Duration // wait time before the first retry
+	MaxBackoff  time.Duration // upper bound for the exponential backoff and Retry-After
+
+	// RetryNonIdempotent enables retries for POST requests. Only set this if
+	// creating an entity twice is acceptable (e.g. when using upsert).
+	RetryNonIdempotent bool
+
+	// OnRetry is called before waiting for the next attempt.
+	OnRetry func(RetryAttempt)
+}
+
+// RetryAttempt describes a failed attempt that is about to be retried.
+type RetryAttempt struct {
+	Attempt int           // number of the failed attempt, starting with 1
+	Method  string        // HTTP method of the request
+	URL     string        // url of the request
+	Err     error         // error of the failed attempt
+	Wait    time.Duration // time to wait before the next attempt
+}
+
+// DefaultRetryPolicy is a sensible retry policy for batch jobs.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+}
+
+// SetRetryPolicy sets the retry policy of the client. A nil policy disables
+// retries, which is the default. SetRetryPolicy must not be called while
+// requests are in flight.
+func (a *API) SetRetryPolicy(policy *RetryPolicy) {
+	a.retry = policy
+}
+
+// backoff decides whether a failed attempt should be retried and how long to
+// wait before doing so.
+func (p *RetryPolicy) backoff(ctx context.Context, method string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if p == nil || err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	if method == "POST" && !p.RetryNonIdempotent {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return 0, false
+		}
+
+		if wait, ok := retryAfter(resp); ok {
+			if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+				wait = p.MaxBackoff
+			}
+			return wait, true
+		}
+	} else if !temporary(err) {
+		return 0, false
+	}
+
+	wait := p.MinBackoff << uint(attempt-1)
+	if wait <= 0 || (p.MaxBackoff > 0 && wait > p.MaxBackoff) {
+		wait = p.MaxBackoff
+	}
+
+	// jitter the wait time between wait/2 and wait, so that concurrent
+	// clients do not retry in lockstep.
+	if half := int64(wait / 2); half > 0 {
+		wait = time.Duration(half + rand.Int63n(half))
+	}
+
+	return wait, true
+}
+
+// temporary reports whether err is a network error that may go away when
+// the request is sent again.
+func temporary(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// retryAfter parses the Retry-After header, which contains either a number of
+// seconds or a HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// sleep waits for the given duration or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,106 @@
+package nimbusec
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	retryAfter := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{value}}}
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		attempt int
+		resp    *http.Response
+		err     error
+		retry   bool
+		wait    time.Duration // exact wait, if not zero
+	}{
+		{"success", "GET", 1, nil, nil, false, 0},
+		{"service unavailable", "GET", 1, nil, &APIError{StatusCode: 503}, true, 0},
+		{"not found", "GET", 1, nil, &APIError{StatusCode: 404}, false, 0},
+		{"last attempt", "GET", 3, nil, &APIError{StatusCode: 503}, false, 0},
+		{"post", "POST", 1, nil, &APIError{StatusCode: 503}, false, 0},
+		{"retry after", "GET", 1, retryAfter("3"), &APIError{StatusCode: 429}, true, 3 * time.Second},
+		{"retry after capped", "GET", 1, retryAfter("7200"), &APIError{StatusCode: 429}, true, 10 * time.Second},
+		{"timeout", "GET", 1, nil, &url.Error{Op: "Get", URL: "/", Err: timeoutError{}}, true, 0},
+		{"connection refused", "GET", 1, nil, &url.Error{Op: "Get", URL: "/", Err: syscall.ECONNREFUSED}, true, 0},
+		{"certificate", "GET", 1, nil, &url.Error{Op: "Get", URL: "/", Err: x509.UnknownAuthorityError{}}, false, 0},
+		{"other", "GET", 1, nil, errors.New("boom"), false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := policy.backoff(context.Background(), tt.method, tt.attempt, tt.resp, tt.err)
+			if ok != tt.retry {
+				t.Fatalf("retry = %v, want %v", ok, tt.retry)
+			}
+			if !ok {
+				return
+			}
+			if tt.wait != 0 && wait != tt.wait {
+				t.Errorf("wait = %v, want %v", wait, tt.wait)
+			}
+			if wait <= 0 || wait > policy.MaxBackoff {
+				t.Errorf("wait = %v, want within (0, %v]", wait, policy.MaxBackoff)
+			}
+		})
+	}
+}
+
+func TestRetryCancelledKeepsLastError(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "60")
+		w.Header().Set("x-nimbusec-error", "maintenance")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	api, err := NewAPIWithOptions(srv.URL, "key", "secret", WithRetryPolicy(&RetryPolicy{
+		MaxAttempts: 5,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  time.Hour,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = api.GetContext(ctx, api.BuildURL("/v2/domain"), Params{}, nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "maintenance" {
+		t.Errorf("error = %v, want the last APIError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,120 @@
+package nimbusec
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamp is a point in time as used by the nimbusec API, which transmits
+// timestamps as milliseconds since the Unix epoch. The zero Timestamp is
+// transmitted as null.
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp returns the Timestamp of t, truncated to milliseconds.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{t.Truncate(time.Millisecond)}
+}
+
+// TimestampFromMillis returns the Timestamp of the given milliseconds since
+// the Unix epoch.
+func TimestampFromMillis(ms int64) Timestamp {
+	return Timestamp{time.UnixMilli(ms)}
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return []byte(strconv.FormatInt(t.UnixMilli(), 10)), nil
+}
+
+// UnmarshalJSON accepts milliseconds as json number or string, RFC 3339
+// strings and null.
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*t = Timestamp{}
+		return nil
+	}
+
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		return t.UnmarshalText([]byte(s))
+	}
+
+	return t.parse(string(b))
+}
+
+// MarshalText encodes the timestamp as RFC 3339 string with milliseconds.
+// The zero Timestamp is encoded as empty string.
+func (t Timestamp) MarshalText() ([]byte, error) {
+	if t.IsZero() {
+		return []byte{}, nil
+	}
+
+	return []byte(t.UTC().Format("2006-01-02T15:04:05.000Z07:00")), nil
+}
+
+// UnmarshalText accepts RFC 3339 strings, milliseconds and the empty string.
+func (t *Timestamp) UnmarshalText(b []byte) error {
+	s := string(b)
+	if s == "" {
+		*t = Timestamp{}
+		return nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		*t = NewTimestamp(parsed)
+		return nil
+	}
+
+	return t.parse(s)
+}
+
+// parse parses milliseconds since the Unix epoch.
+func (t *Timestamp) parse(s string) error {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("nimbusec: invalid timestamp %q", s)
+	}
+
+	*t = TimestampFromMillis(ms)
+	return nil
+}
+
+// Scan implements sql.Scanner. It accepts time values, milliseconds and the
+// text formats of UnmarshalText.
+func (t *Timestamp) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = Timestamp{}
+	case time.Time:
+		*t = NewTimestamp(v)
+	case int64:
+		*t = TimestampFromMillis(v)
+	case []byte:
+		return t.UnmarshalText(v)
+	case string:
+		return t.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("nimbusec: can not scan %T into Timestamp", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer. The zero Timestamp is stored as NULL.
+func (t Timestamp) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.UTC(), nil
+}
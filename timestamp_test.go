@@ -0,0 +1,75 @@
+package nimbusec
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampUnmarshalJSON(t *testing.T) {
+	want := time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC)
+
+	tests := []struct {
+		input string
+		want  time.Time
+		err   bool
+	}{
+		{`1704164645006`, want, false},
+		{`"1704164645006"`, want, false},
+		{`"2024-01-02T03:04:05.006Z"`, want, false},
+		{`"2024-01-02T04:04:05.006+01:00"`, want, false},
+		{`null`, time.Time{}, false},
+		{`""`, time.Time{}, false},
+		{`"yesterday"`, time.Time{}, true},
+		{`1.5`, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var ts Timestamp
+			err := json.Unmarshal([]byte(tt.input), &ts)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if !ts.Equal(tt.want) {
+				t.Errorf("Unmarshal = %v, want %v", ts.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	tests := []Timestamp{
+		{},
+		TimestampFromMillis(0),
+		TimestampFromMillis(1704164645006),
+		NewTimestamp(time.Date(2024, 1, 2, 3, 4, 5, 6789999, time.UTC)),
+	}
+
+	for _, ts := range tests {
+		data, err := json.Marshal(ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Timestamp
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(ts.Time) || got.IsZero() != ts.IsZero() {
+			t.Errorf("%s: round trip = %v, want %v", data, got.Time, ts.Time)
+		}
+
+		text, err := ts.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := got.UnmarshalText(text); err != nil || !got.Equal(ts.Time) {
+			t.Errorf("%q: text round trip = %v, %v, want %v", text, got.Time, err, ts.Time)
+		}
+	}
+
+	if data, _ := json.Marshal(Timestamp{}); string(data) != "null" {
+		t.Errorf("zero Timestamp = %s, want null", data)
+	}
+}
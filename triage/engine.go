@@ -0,0 +1,161 @@
+package triage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/filter"
+)
+
+// EscalateFunc handles results matched by a rule with the escalate action,
+// e.g. by opening a ticket.
+type EscalateFunc func(ctx context.Context, domain nimbusec.Domain, result nimbusec.Result, rule *Rule) error
+
+// Engine applies a ruleset to the pending results of all domains.
+type Engine struct {
+	API      *nimbusec.API
+	Rules    *Ruleset
+	DryRun   bool         // only report what would change
+	Plan     io.Writer    // receives a human readable line per decision, if set
+	Audit    io.Writer    // receives a JSON line per applied decision, if set
+	Escalate EscalateFunc // called for the escalate action
+}
+
+// Decision is the outcome of the rules for a single result.
+type Decision struct {
+	Domain nimbusec.Domain
+	Result nimbusec.Result
+	Rule   *Rule
+	Err    error // error while applying the action
+}
+
+// AuditEntry is written to the audit log for every decision.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	DryRun     bool      `json:"dryRun"`
+	Rule       string    `json:"rule"`
+	Action     Action    `json:"action"`
+	Domain     string    `json:"domain"`
+	DomainID   int       `json:"domainId"`
+	ResultID   int       `json:"resultId"`
+	Category   string    `json:"category"`
+	Threatname string    `json:"threatname"`
+	Resource   string    `json:"resource"`
+	MD5        string    `json:"md5"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Run evaluates the rules against the pending results of all domains and
+// applies the matching actions, unless DryRun is set. It returns all
+// decisions; failed actions are reported in Decision.Err.
+func (e *Engine) Run(ctx context.Context) ([]Decision, error) {
+	domains, err := e.API.FindDomainsContext(ctx, nimbusec.EmptyFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := make([]Decision, 0)
+	for _, domain := range domains {
+		d, err := e.RunDomain(ctx, domain)
+		decisions = append(decisions, d...)
+		if err != nil {
+			return decisions, err
+		}
+	}
+
+	return decisions, nil
+}
+
+// RunDomain is like Run but only triages the results of a single domain.
+func (e *Engine) RunDomain(ctx context.Context, domain nimbusec.Domain) ([]Decision, error) {
+	pending := filter.Eq("status", nimbusec.StatusPending).String()
+	results, err := e.API.FindResultsContext(ctx, domain.Id, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := make([]Decision, 0)
+	for _, result := range results {
+		rule := e.Rules.Match(domain, result)
+		if rule == nil {
+			continue
+		}
+
+		decision := Decision{Domain: domain, Result: result, Rule: rule}
+		if !e.DryRun {
+			decision.Err = e.apply(ctx, decision)
+		}
+
+		e.record(decision)
+		decisions = append(decisions, decision)
+
+		if err := ctx.Err(); err != nil {
+			return decisions, err
+		}
+	}
+
+	return decisions, nil
+}
+
+func (e *Engine) apply(ctx context.Context, d Decision) error {
+	switch d.Rule.Action {
+	case ActionAcknowledge, ActionFalsePositive:
+		status := nimbusec.StatusAcknowledged
+		if d.Rule.Action == ActionFalsePositive {
+			status = nimbusec.StatusFalsePositive
+		}
+		_, err := e.API.UpdateResultContext(ctx, d.Domain.Id, &nimbusec.Result{Id: d.Result.Id, Status: status})
+		return err
+
+	case ActionEscalate:
+		if e.Escalate == nil {
+			return fmt.Errorf("triage: no escalation handler configured")
+		}
+		return e.Escalate(ctx, d.Domain, d.Result, d.Rule)
+	}
+
+	return fmt.Errorf("triage: invalid action %q", d.Rule.Action)
+}
+
+// record writes the decision to the plan and the audit log.
+func (e *Engine) record(d Decision) {
+	if e.Plan != nil {
+		prefix := ""
+		if e.DryRun {
+			prefix = "would "
+		}
+
+		status := ""
+		if d.Err != nil {
+			status = fmt.Sprintf(" (failed: %v)", d.Err)
+		}
+
+		fmt.Fprintf(e.Plan, "%s%s result %d on %s [%s %s %s] by rule %q%s\n",
+			prefix, d.Rule.Action, d.Result.Id, d.Domain.Name,
+			d.Result.Category, d.Result.Threatname, d.Result.Resource, d.Rule.Name, status)
+	}
+
+	if e.Audit != nil {
+		entry := AuditEntry{
+			Time:       time.Now().UTC(),
+			DryRun:     e.DryRun,
+			Rule:       d.Rule.Name,
+			Action:     d.Rule.Action,
+			Domain:     d.Domain.Name,
+			DomainID:   d.Domain.Id,
+			ResultID:   d.Result.Id,
+			Category:   d.Result.Category,
+			Threatname: d.Result.Threatname,
+			Resource:   d.Result.Resource,
+			MD5:        d.Result.MD5,
+		}
+		if d.Err != nil {
+			entry.Error = d.Err.Error()
+		}
+		json.NewEncoder(e.Audit).Encode(entry)
+	}
+}
@@ -0,0 +1,114 @@
+package triage_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+	"github.com/cumulodev/nimbusec/triage"
+)
+
+func TestEngineRun(t *testing.T) {
+	rules := &triage.Ruleset{Rules: []triage.Rule{
+		{Name: "cache", Resource: "/var/www/**/cache/*", Action: triage.ActionFalsePositive},
+		{Name: "webshell", Category: "webshell", Action: triage.ActionEscalate},
+	}}
+
+	tests := []struct {
+		name      string
+		dryRun    bool
+		escalate  bool
+		decisions int
+		failed    int
+		status    nimbusec.ResultStatus // status of the cache result afterwards
+	}{
+		{"dry run", true, true, 2, 0, nimbusec.StatusPending},
+		{"apply", false, true, 2, 0, nimbusec.StatusFalsePositive},
+		{"no escalation handler", false, false, 2, 1, nimbusec.StatusFalsePositive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := nimbusectest.NewServer()
+			defer srv.Close()
+
+			domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+			cache := srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending, Resource: "/var/www/site/wp-content/cache/a.php"})
+			srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "webshell", Resource: "/var/www/shell.php"})
+			srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending, Category: "malware"})
+			srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusAcknowledged, Resource: "/var/www/cache/b.php"})
+
+			var plan, audit bytes.Buffer
+			escalated := 0
+			engine := &triage.Engine{
+				API:    srv.API(),
+				Rules:  rules,
+				DryRun: tt.dryRun,
+				Plan:   &plan,
+				Audit:  &audit,
+			}
+			if tt.escalate {
+				engine.Escalate = func(ctx context.Context, domain nimbusec.Domain, result nimbusec.Result, rule *triage.Rule) error {
+					escalated++
+					return nil
+				}
+			}
+
+			decisions, err := engine.Run(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(decisions) != tt.decisions {
+				t.Fatalf("got %d decisions, want %d", len(decisions), tt.decisions)
+			}
+
+			failed := 0
+			for _, d := range decisions {
+				if d.Err != nil {
+					failed++
+				}
+			}
+			if failed != tt.failed {
+				t.Errorf("failed = %d, want %d", failed, tt.failed)
+			}
+
+			if tt.dryRun && escalated != 0 {
+				t.Errorf("escalated %d results in a dry run", escalated)
+			}
+
+			for _, result := range srv.Results(domain.Id) {
+				if result.Id == cache.Id && result.Status != tt.status {
+					t.Errorf("status = %v, want %v", result.Status, tt.status)
+				}
+			}
+
+			if n := strings.Count(plan.String(), "\n"); n != tt.decisions {
+				t.Errorf("plan has %d lines, want %d", n, tt.decisions)
+			}
+			if tt.dryRun && !strings.HasPrefix(plan.String(), "would ") {
+				t.Errorf("plan = %q, want dry run wording", plan.String())
+			}
+
+			entries := 0
+			decoder := json.NewDecoder(&audit)
+			for decoder.More() {
+				var entry triage.AuditEntry
+				if err := decoder.Decode(&entry); err != nil {
+					t.Fatal(err)
+				}
+				if entry.DryRun != tt.dryRun {
+					t.Errorf("audit dryRun = %v, want %v", entry.DryRun, tt.dryRun)
+				}
+				entries++
+			}
+			if entries != tt.decisions {
+				t.Errorf("audit has %d entries, want %d", entries, tt.decisions)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package triage applies declarative rules to pending nimbusec results, e.g.
+// to mark recurring false positives automatically.
+//
+// Rules are loaded from YAML or JSON:
+//
+//	rules:
+//	  - name: wordpress cache files
+//	    domains: ["*.example.com"]
+//	    category: webshell
+//	    resource: "/var/www/*/wp-content/cache/**"
+//	    maxSeverity: medium
+//	    action: falsepositive
+//
+// Patterns use the syntax of path.Match, so * does not match a slash. In
+// addition, ** as a whole path segment matches any number of segments. The
+// first matching rule decides the action for a result.
+package triage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cumulodev/nimbusec"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is the triage action of a rule.
+type Action string
+
+const (
+	ActionAcknowledge   Action = "acknowledge"   // set the result status to acknowledged
+	ActionFalsePositive Action = "falsepositive" // set the result status to false positive
+	ActionEscalate      Action = "escalate"      // hand the result to the escalation hook
+)
+
+// Valid reports whether a is a known action.
+func (a Action) Valid() bool {
+	return a == ActionAcknowledge || a == ActionFalsePositive || a == ActionEscalate
+}
+
+// Rule matches results and specifies the action to take. All given criteria
+// must match; empty criteria match everything.
+type Rule struct {
+	Name           string            `json:"name" yaml:"name"`
+	Domains        []string          `json:"domains,omitempty" yaml:"domains,omitempty"`         // glob patterns for the domain name
+	Category       string            `json:"category,omitempty" yaml:"category,omitempty"`       // exact category
+	Threatname     string            `json:"threatname,omitempty" yaml:"threatname,omitempty"`   // glob pattern for the threat name
+	Resource       string            `json:"resource,omitempty" yaml:"resource,omitempty"`       // glob pattern for the affected resource
+	MD5            []string          `json:"md5,omitempty" yaml:"md5,omitempty"`                 // MD5 hash sums of the affected file
+	MinSeverity    nimbusec.Severity `json:"minSeverity,omitempty" yaml:"minSeverity,omitempty"` // lowest matching severity
+	MaxSeverity    nimbusec.Severity `json:"maxSeverity,omitempty" yaml:"maxSeverity,omitempty"` // highest matching severity
+	MinProbability *float64          `json:"minProbability,omitempty" yaml:"minProbability,omitempty"`
+	MaxProbability *float64          `json:"maxProbability,omitempty" yaml:"maxProbability,omitempty"`
+	Action         Action            `json:"action" yaml:"action"`
+}
+
+// Ruleset is an ordered list of rules.
+type Ruleset struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Load reads a ruleset from a YAML or JSON file, depending on its extension.
+func Load(filename string) (*Ruleset, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	}
+
+	return nil, fmt.Errorf("triage: unknown rule file format %q", filepath.Ext(filename))
+}
+
+// ParseJSON parses and validates a JSON ruleset.
+func ParseJSON(data []byte) (*Ruleset, error) {
+	rs := new(Ruleset)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(rs); err != nil {
+		return nil, fmt.Errorf("triage: %v", err)
+	}
+	return rs, rs.Validate()
+}
+
+// ParseYAML parses and validates a YAML ruleset.
+func ParseYAML(data []byte) (*Ruleset, error) {
+	rs := new(Ruleset)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(rs); err != nil {
+		return nil, fmt.Errorf("triage: %v", err)
+	}
+	return rs, rs.Validate()
+}
+
+// Validate checks all rules for valid actions and patterns.
+func (rs *Ruleset) Validate() error {
+	for i, rule := range rs.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("triage: rule %d (%s): %v", i+1, rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the action and the glob patterns of the rule.
+func (r Rule) Validate() error {
+	if !r.Action.Valid() {
+		return fmt.Errorf("invalid action %q", r.Action)
+	}
+
+	patterns := append([]string{r.Threatname, r.Resource}, r.Domains...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+
+	if r.MinSeverity != 0 && r.MaxSeverity != 0 && r.MinSeverity > r.MaxSeverity {
+		return fmt.Errorf("minSeverity is greater than maxSeverity")
+	}
+
+	return nil
+}
+
+// Match reports whether the rule matches the result of the given domain.
+func (r Rule) Match(domain nimbusec.Domain, result nimbusec.Result) bool {
+	if len(r.Domains) > 0 && !matchAny(r.Domains, domain.Name) {
+		return false
+	}
+
+	if r.Category != "" && r.Category != result.Category {
+		return false
+	}
+
+	if r.Threatname != "" && !glob(r.Threatname, result.Threatname) {
+		return false
+	}
+
+	if r.Resource != "" && !glob(r.Resource, result.Resource) {
+		return false
+	}
+
+	if len(r.MD5) > 0 && !containsFold(r.MD5, result.MD5) {
+		return false
+	}
+
+	if r.MinSeverity != 0 && result.Severity < r.MinSeverity {
+		return false
+	}
+
+	if r.MaxSeverity != 0 && result.Severity > r.MaxSeverity {
+		return false
+	}
+
+	if r.MinProbability != nil && result.Probability < *r.MinProbability {
+		return false
+	}
+
+	if r.MaxProbability != nil && result.Probability > *r.MaxProbability {
+		return false
+	}
+
+	return true
+}
+
+// Match returns the first rule matching the result or nil.
+func (rs *Ruleset) Match(domain nimbusec.Domain, result nimbusec.Result) *Rule {
+	for i := range rs.Rules {
+		if rs.Rules[i].Match(domain, result) {
+			return &rs.Rules[i]
+		}
+	}
+	return nil
+}
+
+// glob matches s against pattern like path.Match, except that a ** segment
+// matches zero or more path segments.
+func glob(pattern, s string) bool {
+	if !strings.Contains(pattern, "**") {
+		ok, _ := path.Match(pattern, s)
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(s, "/"))
+}
+
+func matchSegments(pattern, s []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(s); i++ {
+				if matchSegments(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(s) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], s[0]); !ok {
+			return false
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if glob(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
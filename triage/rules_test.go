@@ -0,0 +1,132 @@
+package triage
+
+import (
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"/var/www/*/cache/*", "/var/www/site/cache/a.php", true},
+		{"/var/www/*/cache/*", "/var/www/site/cache/sub/a.php", false},
+		{"/var/www/*/cache/**", "/var/www/site/cache/sub/a.php", true},
+		{"/var/www/*/cache/**", "/var/www/site/cache", true},
+		{"/var/www/**/a.php", "/var/www/a.php", true},
+		{"/var/www/**/a.php", "/var/www/x/y/a.php", true},
+		{"/var/www/**/a.php", "/var/www/x/y/b.php", false},
+		{"**", "/any/path", true},
+		{"[", "[", false},
+	}
+
+	for _, tt := range tests {
+		if got := glob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("glob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	low, high := 0.2, 0.8
+	domain := nimbusec.Domain{Name: "www.example.com"}
+	result := nimbusec.Result{
+		Category:    "webshell",
+		Threatname:  "PHP.Backdoor.Generic",
+		Resource:    "/var/www/site/wp-content/cache/x.php",
+		MD5:         "D41D8CD98F00B204E9800998ECF8427E",
+		Severity:    nimbusec.SeverityMedium,
+		Probability: 0.5,
+	}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{"empty", Rule{}, true},
+		{"domain", Rule{Domains: []string{"other.com", "*.example.com"}}, true},
+		{"other domain", Rule{Domains: []string{"*.example.org"}}, false},
+		{"category", Rule{Category: "webshell"}, true},
+		{"other category", Rule{Category: "malware"}, false},
+		{"threatname", Rule{Threatname: "PHP.*"}, true},
+		{"resource", Rule{Resource: "/var/www/**/cache/*"}, true},
+		{"other resource", Rule{Resource: "/var/www/*.php"}, false},
+		{"md5 ignores case", Rule{MD5: []string{"d41d8cd98f00b204e9800998ecf8427e"}}, true},
+		{"min severity", Rule{MinSeverity: nimbusec.SeveritySevere}, false},
+		{"max severity", Rule{MaxSeverity: nimbusec.SeverityMedium}, true},
+		{"probability range", Rule{MinProbability: &low, MaxProbability: &high}, true},
+		{"min probability", Rule{MinProbability: &high}, false},
+		{"max probability", Rule{MaxProbability: &low}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Match(domain, result); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (*Ruleset, error)
+		data  string
+		rules int
+		err   bool
+	}{
+		{"yaml", ParseYAML, "rules:\n  - name: cache\n    resource: /var/www/**\n    maxSeverity: medium\n    action: falsepositive\n", 1, false},
+		{"json", ParseJSON, `{"rules": [{"name": "a", "action": "acknowledge"}, {"name": "b", "action": "escalate"}]}`, 2, false},
+		{"unknown field", ParseYAML, "rules:\n  - name: a\n    actoin: acknowledge\n", 0, true},
+		{"invalid action", ParseJSON, `{"rules": [{"name": "a", "action": "delete"}]}`, 0, true},
+		{"invalid pattern", ParseJSON, `{"rules": [{"name": "a", "resource": "[", "action": "acknowledge"}]}`, 0, true},
+		{"severity range", ParseYAML, "rules:\n  - name: a\n    minSeverity: severe\n    maxSeverity: medium\n    action: acknowledge\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := tt.parse([]byte(tt.data))
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rs.Rules) != tt.rules {
+				t.Errorf("got %d rules, want %d", len(rs.Rules), tt.rules)
+			}
+		})
+	}
+}
+
+func TestRulesetMatchFirst(t *testing.T) {
+	rs := &Ruleset{Rules: []Rule{
+		{Name: "first", Category: "webshell", Action: ActionEscalate},
+		{Name: "second", Action: ActionAcknowledge},
+	}}
+
+	tests := []struct {
+		category string
+		want     string
+	}{
+		{"webshell", "first"},
+		{"malware", "second"},
+	}
+
+	for _, tt := range tests {
+		rule := rs.Match(nimbusec.Domain{}, nimbusec.Result{Category: tt.category})
+		if rule == nil || rule.Name != tt.want {
+			t.Errorf("Match(%q) = %v, want rule %q", tt.category, rule, tt.want)
+		}
+	}
+}
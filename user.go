@@ -1,6 +1,12 @@
 package nimbusec
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cumulodev/nimbusec/filter"
+)
 
 const (
 	// RoleUser is the restricted role for an user
@@ -10,6 +16,30 @@ const (
 	RoleAdministrator = "administrator"
 )
 
+// Transport is the channel over which notifications are sent.
+type Transport string
+
+const (
+	TransportMail Transport = "mail"
+	TransportSMS  Transport = "sms"
+)
+
+// Valid reports whether t is one of the known transports.
+func (t Transport) Valid() bool {
+	return t == TransportMail || t == TransportSMS
+}
+
+func (t Transport) String() string {
+	return string(t)
+}
+
+// UnmarshalText accepts the transport name in any case. Unknown transports
+// are kept, so that newer API versions do not break decoding.
+func (t *Transport) UnmarshalText(b []byte) error {
+	*t = Transport(strings.ToLower(string(b)))
+	return nil
+}
+
 // User represents an human user able to login and receive notifications.
 type User struct {
 	Id           int    `json:"id,omitempty"`           // unique identification of user
@@ -27,19 +57,47 @@ type User struct {
 
 // Notification represents an notification entry for a user and domain.
 type Notification struct {
-	Id         int    `json:"id,omitempty"` // unique identification of notification
-	Domain     int    `json:"domain"`       // domain for which notifications should be sent
-	Transport  string `json:"transport"`    // transport over which notifications are sent (mail, sms)
-	ServerSide int    `json:"serverside"`   // minimum severity of serverside results before a notification is sent
-	Content    int    `json:"content"`      // minimum severity of content results before a notification is sent
-	Blacklist  int    `json:"blacklist"`    // minimum severity of backlist results before a notification is sent
+	Id         int       `json:"id,omitempty"` // unique identification of notification
+	Domain     int       `json:"domain"`       // domain for which notifications should be sent
+	Transport  Transport `json:"transport"`    // transport over which notifications are sent (mail, sms)
+	ServerSide Severity  `json:"serverside"`   // minimum severity of serverside results before a notification is sent
+	Content    Severity  `json:"content"`      // minimum severity of content results before a notification is sent
+	Blacklist  Severity  `json:"blacklist"`    // minimum severity of backlist results before a notification is sent
+}
+
+// validate checks the transport and severity thresholds before the
+// notification is sent to the API.
+func (n *Notification) validate() error {
+	if !n.Transport.Valid() {
+		return fmt.Errorf("nimbusec: invalid transport %q", n.Transport)
+	}
+
+	thresholds := []struct {
+		name     string
+		severity Severity
+	}{
+		{"serverside", n.ServerSide},
+		{"content", n.Content},
+		{"blacklist", n.Blacklist},
+	}
+	for _, t := range thresholds {
+		if !t.severity.Valid() {
+			return fmt.Errorf("nimbusec: invalid %s severity %d", t.name, t.severity)
+		}
+	}
+	return nil
 }
 
 // CreateUser issues the nimbusec API to create the given user.
 func (a *API) CreateUser(user *User) (*User, error) {
+	return a.CreateUserContext(context.Background(), user)
+}
+
+// CreateUserContext is like CreateUser but with a context.
+func (a *API) CreateUserContext(ctx context.Context, user *User) (*User, error) {
 	dst := new(User)
 	url := a.BuildURL("/v2/user")
-	err := a.Post(url, Params{}, user, dst)
+	err := a.PostContext(ctx, url, Params{}, user, dst)
 	return dst, err
 }
 
@@ -47,9 +105,14 @@ func (a *API) CreateUser(user *User) (*User, error) {
 // failing when attempting to create a duplicate user, this method will update the
 // remote user instead.
 func (a *API) CreateOrUpdateUser(user *User) (*User, error) {
+	return a.CreateOrUpdateUserContext(context.Background(), user)
+}
+
+// CreateOrUpdateUserContext is like CreateOrUpdateUser but with a context.
+func (a *API) CreateOrUpdateUserContext(ctx context.Context, user *User) (*User, error) {
 	dst := new(User)
 	url := a.BuildURL("/v2/user")
-	err := a.Post(url, Params{"upsert": "true"}, user, dst)
+	err := a.PostContext(ctx, url, Params{"upsert": "true"}, user, dst)
 	return dst, err
 }
 
@@ -57,23 +120,38 @@ func (a *API) CreateOrUpdateUser(user *User) (*User, error) {
 // failing when attempting to create a duplicate user, this method will fetch the
 // remote user instead.
 func (a *API) CreateOrGetUser(user *User) (*User, error) {
+	return a.CreateOrGetUserContext(context.Background(), user)
+}
+
+// CreateOrGetUserContext is like CreateOrGetUser but with a context.
+func (a *API) CreateOrGetUserContext(ctx context.Context, user *User) (*User, error) {
 	dst := new(User)
 	url := a.BuildURL("/v2/user")
-	err := a.Post(url, Params{"upsert": "false"}, user, dst)
+	err := a.PostContext(ctx, url, Params{"upsert": "false"}, user, dst)
 	return dst, err
 }
 
 // GetUser fetches an user by its ID.
 func (a *API) GetUser(user int) (*User, error) {
+	return a.GetUserContext(context.Background(), user)
+}
+
+// GetUserContext is like GetUser but with a context.
+func (a *API) GetUserContext(ctx context.Context, user int) (*User, error) {
 	dst := new(User)
 	url := a.BuildURL("/v2/user/%d", user)
-	err := a.Get(url, Params{}, dst)
+	err := a.GetContext(ctx, url, Params{}, dst)
 	return dst, err
 }
 
 // GetUserByLogin fetches an user by its login name.
 func (a *API) GetUserByLogin(login string) (*User, error) {
-	users, err := a.FindUsers(fmt.Sprintf("login eq \"%s\"", login))
+	return a.GetUserByLoginContext(context.Background(), login)
+}
+
+// GetUserByLoginContext is like GetUserByLogin but with a context.
+func (a *API) GetUserByLoginContext(ctx context.Context, login string) (*User, error) {
+	users, err := a.FindUsersContext(ctx, filter.Eq("login", login).String())
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +169,11 @@ func (a *API) GetUserByLogin(login string) (*User, error) {
 
 // FindUsers searches for users that match the given filter criteria.
 func (a *API) FindUsers(filter string) ([]User, error) {
+	return a.FindUsersContext(context.Background(), filter)
+}
+
+// FindUsersContext is like FindUsers but with a context.
+func (a *API) FindUsersContext(ctx context.Context, filter string) ([]User, error) {
 	params := Params{}
 	if filter != EmptyFilter {
 		params["q"] = filter
@@ -98,95 +181,155 @@ func (a *API) FindUsers(filter string) ([]User, error) {
 
 	dst := make([]User, 0)
 	url := a.BuildURL("/v2/user")
-	err := a.Get(url, params, &dst)
+	err := a.GetContext(ctx, url, params, &dst)
 	return dst, err
 }
 
 // UpdateUser issues the nimbusec API to update an user.
 func (a *API) UpdateUser(user *User) (*User, error) {
+	return a.UpdateUserContext(context.Background(), user)
+}
+
+// UpdateUserContext is like UpdateUser but with a context.
+func (a *API) UpdateUserContext(ctx context.Context, user *User) (*User, error) {
 	dst := new(User)
 	url := a.BuildURL("/v2/user/%d", user.Id)
-	err := a.Put(url, Params{}, user, dst)
+	err := a.PutContext(ctx, url, Params{}, user, dst)
 	return dst, err
 }
 
 // DeleteUser issues the nimbusec API to delete an user. The root user or tennant
 // can not be deleted via this method.
 func (a *API) DeleteUser(user *User) error {
+	return a.DeleteUserContext(context.Background(), user)
+}
+
+// DeleteUserContext is like DeleteUser but with a context.
+func (a *API) DeleteUserContext(ctx context.Context, user *User) error {
 	url := a.BuildURL("/v2/user/%d", user.Id)
-	return a.Delete(url, Params{})
+	return a.DeleteContext(ctx, url, Params{})
 }
 
 // GetDomainSet fetches the set of allowed domains for an restricted user.
 func (a *API) GetDomainSet(user *User) ([]int, error) {
+	return a.GetDomainSetContext(context.Background(), user)
+}
+
+// GetDomainSetContext is like GetDomainSet but with a context.
+func (a *API) GetDomainSetContext(ctx context.Context, user *User) ([]int, error) {
 	dst := make([]int, 0)
 	url := a.BuildURL("/v2/user/%d/domains", user.Id)
-	err := a.Get(url, Params{}, &dst)
+	err := a.GetContext(ctx, url, Params{}, &dst)
 	return dst, err
 }
 
 // UpdateDomainSet updates the set of allowed domains of an restricted user.
 func (a *API) UpdateDomainSet(user *User, domains []int) ([]int, error) {
+	return a.UpdateDomainSetContext(context.Background(), user, domains)
+}
+
+// UpdateDomainSetContext is like UpdateDomainSet but with a context.
+func (a *API) UpdateDomainSetContext(ctx context.Context, user *User, domains []int) ([]int, error) {
 	dst := make([]int, 0)
 	url := a.BuildURL("/v2/user/%d/domains", user.Id)
-	err := a.Put(url, Params{}, domains, &dst)
+	err := a.PutContext(ctx, url, Params{}, domains, &dst)
 	return dst, err
 }
 
 // LinkDomain links the given domain id to the given user and adds the priviledges for
 // the user to view the domain.
 func (a *API) LinkDomain(user *User, domain int) error {
+	return a.LinkDomainContext(context.Background(), user, domain)
+}
+
+// LinkDomainContext is like LinkDomain but with a context.
+func (a *API) LinkDomainContext(ctx context.Context, user *User, domain int) error {
 	url := a.BuildURL("/v2/user/%d/domains", user.Id)
-	return a.Post(url, Params{}, domain, nil)
+	return a.PostContext(ctx, url, Params{}, domain, nil)
 }
 
 // UnlinkDomain unlinks the given domain id to the given user and removes the priviledges
 // from the user to view the domain.
 func (a *API) UnlinkDomain(user *User, domain int) error {
+	return a.UnlinkDomainContext(context.Background(), user, domain)
+}
+
+// UnlinkDomainContext is like UnlinkDomain but with a context.
+func (a *API) UnlinkDomainContext(ctx context.Context, user *User, domain int) error {
 	url := a.BuildURL("/v2/user/%d/domains/%d", user.Id, domain)
-	return a.Delete(url, Params{})
+	return a.DeleteContext(ctx, url, Params{})
 }
 
 // ListuserConfigs fetches the list of all available configuration keys for the
 // given domain.
 func (a *API) ListUserConfigs(user int) ([]string, error) {
+	return a.ListUserConfigsContext(context.Background(), user)
+}
+
+// ListUserConfigsContext is like ListUserConfigs but with a context.
+func (a *API) ListUserConfigsContext(ctx context.Context, user int) ([]string, error) {
 	dst := make([]string, 0)
 	url := a.BuildURL("/v2/user/%d/config", user)
-	err := a.Get(url, Params{}, &dst)
+	err := a.GetContext(ctx, url, Params{}, &dst)
 	return dst, err
 }
 
 // GetUserConfig fetches the requested user configuration.
 func (a *API) GetUserConfig(user int, key string) (string, error) {
+	return a.GetUserConfigContext(context.Background(), user, key)
+}
+
+// GetUserConfigContext is like GetUserConfig but with a context.
+func (a *API) GetUserConfigContext(ctx context.Context, user int, key string) (string, error) {
 	url := a.BuildURL("/v2/user/%d/config/%s/", user, key)
-	return a.getTextPlain(url, Params{})
+	return a.getTextPlain(ctx, url, Params{})
 }
 
 // SetUserConfig sets the user configuration `key` to the requested value.
 // This method will create the user configuration if it does not exist yet.
 func (a *API) SetUserConfig(user int, key string, value string) (string, error) {
+	return a.SetUserConfigContext(context.Background(), user, key, value)
+}
+
+// SetUserConfigContext is like SetUserConfig but with a context.
+func (a *API) SetUserConfigContext(ctx context.Context, user int, key string, value string) (string, error) {
 	url := a.BuildURL("/v2/user/%d/config/%s/", user, key)
-	return a.putTextPlain(url, Params{}, value)
+	return a.putTextPlain(ctx, url, Params{}, value)
 }
 
 // DeleteUserConfig issues the API to delete the user configuration with
 // the provided key.
 func (a *API) DeleteUserConfig(user int, key string) error {
+	return a.DeleteUserConfigContext(context.Background(), user, key)
+}
+
+// DeleteUserConfigContext is like DeleteUserConfig but with a context.
+func (a *API) DeleteUserConfigContext(ctx context.Context, user int, key string) error {
 	url := a.BuildURL("/v2/user/%d/config/%s/", user, key)
-	return a.Delete(url, Params{})
+	return a.DeleteContext(ctx, url, Params{})
 }
 
 // GetNotification fetches a notification by its ID.
 func (a *API) GetNotification(user int, id int) (*Notification, error) {
+	return a.GetNotificationContext(context.Background(), user, id)
+}
+
+// GetNotificationContext is like GetNotification but with a context.
+func (a *API) GetNotificationContext(ctx context.Context, user int, id int) (*Notification, error) {
 	dst := new(Notification)
 	url := a.BuildURL("/v2/user/%d/notification/%d", user, id)
-	err := a.Get(url, Params{}, dst)
+	err := a.GetContext(ctx, url, Params{}, dst)
 	return dst, err
 }
 
 // FindNotifications fetches all notifications for the given user that match the
 // filter criteria.
 func (a *API) FindNotifications(user int, filter string) ([]Notification, error) {
+	return a.FindNotificationsContext(context.Background(), user, filter)
+}
+
+// FindNotificationsContext is like FindNotifications but with a context.
+func (a *API) FindNotificationsContext(ctx context.Context, user int, filter string) ([]Notification, error) {
 	params := Params{}
 	if filter != EmptyFilter {
 		params["q"] = filter
@@ -194,15 +337,24 @@ func (a *API) FindNotifications(user int, filter string) ([]Notification, error)
 
 	dst := make([]Notification, 0)
 	url := a.BuildURL("/v2/user/%d/notification", user)
-	err := a.Get(url, params, &dst)
+	err := a.GetContext(ctx, url, params, &dst)
 	return dst, err
 }
 
 // CreateNotification creates the notification for the given user.
 func (a *API) CreateNotification(user int, notification *Notification) (*Notification, error) {
+	return a.CreateNotificationContext(context.Background(), user, notification)
+}
+
+// CreateNotificationContext is like CreateNotification but with a context.
+func (a *API) CreateNotificationContext(ctx context.Context, user int, notification *Notification) (*Notification, error) {
+	if err := notification.validate(); err != nil {
+		return nil, err
+	}
+
 	dst := new(Notification)
 	url := a.BuildURL("/v2/user/%d/notification", user)
-	err := a.Post(url, Params{}, notification, dst)
+	err := a.PostContext(ctx, url, Params{}, notification, dst)
 	return dst, err
 }
 
@@ -210,9 +362,18 @@ func (a *API) CreateNotification(user int, notification *Notification) (*Notific
 // failing when attempting to create a duplicate notification, this method will update the
 // remote notification instead.
 func (a *API) CreateOrUpdateNotification(user int, notification *Notification) (*Notification, error) {
+	return a.CreateOrUpdateNotificationContext(context.Background(), user, notification)
+}
+
+// CreateOrUpdateNotificationContext is like CreateOrUpdateNotification but with a context.
+func (a *API) CreateOrUpdateNotificationContext(ctx context.Context, user int, notification *Notification) (*Notification, error) {
+	if err := notification.validate(); err != nil {
+		return nil, err
+	}
+
 	dst := new(Notification)
 	url := a.BuildURL("/v2/user/%d/notification", user)
-	err := a.Post(url, Params{"upsert": "true"}, notification, dst)
+	err := a.PostContext(ctx, url, Params{"upsert": "true"}, notification, dst)
 	return dst, err
 }
 
@@ -220,22 +381,45 @@ func (a *API) CreateOrUpdateNotification(user int, notification *Notification) (
 // failing when attempting to create a duplicate notification, this method will fetch the
 // remote notification instead.
 func (a *API) CreateOrGetNotification(user int, notification *Notification) (*Notification, error) {
+	return a.CreateOrGetNotificationContext(context.Background(), user, notification)
+}
+
+// CreateOrGetNotificationContext is like CreateOrGetNotification but with a context.
+func (a *API) CreateOrGetNotificationContext(ctx context.Context, user int, notification *Notification) (*Notification, error) {
+	if err := notification.validate(); err != nil {
+		return nil, err
+	}
+
 	dst := new(Notification)
 	url := a.BuildURL("/v2/user/%d/notification", user)
-	err := a.Post(url, Params{"upsert": "false"}, notification, dst)
+	err := a.PostContext(ctx, url, Params{"upsert": "false"}, notification, dst)
 	return dst, err
 }
 
 // UpdateNotification updates the notification for the given user.
 func (a *API) UpdateNotification(user int, notification *Notification) (*Notification, error) {
+	return a.UpdateNotificationContext(context.Background(), user, notification)
+}
+
+// UpdateNotificationContext is like UpdateNotification but with a context.
+func (a *API) UpdateNotificationContext(ctx context.Context, user int, notification *Notification) (*Notification, error) {
+	if err := notification.validate(); err != nil {
+		return nil, err
+	}
+
 	dst := new(Notification)
 	url := a.BuildURL("/v2/user/%d/notification/%d", user, notification.Id)
-	err := a.Put(url, Params{}, notification, dst)
+	err := a.PutContext(ctx, url, Params{}, notification, dst)
 	return dst, err
 }
 
 // DeleteNotification deletes the given notification.
 func (a *API) DeleteNotification(user int, notification *Notification) error {
+	return a.DeleteNotificationContext(context.Background(), user, notification)
+}
+
+// DeleteNotificationContext is like DeleteNotification but with a context.
+func (a *API) DeleteNotificationContext(ctx context.Context, user int, notification *Notification) error {
 	url := a.BuildURL("/v2/user/%d/notification/%d", user, notification.Id)
-	return a.Delete(url, Params{})
+	return a.DeleteContext(ctx, url, Params{})
 }
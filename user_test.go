@@ -0,0 +1,70 @@
+package nimbusec_test
+
+import (
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+)
+
+func TestCreateNotificationValidates(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com", Scheme: "https"})
+	user := srv.AddUser(nimbusec.User{Login: "alice"})
+	api := srv.API()
+
+	valid := nimbusec.Notification{
+		Domain:     domain.Id,
+		Transport:  nimbusec.TransportMail,
+		ServerSide: nimbusec.SeverityMedium,
+		Content:    nimbusec.SeverityHigh,
+		Blacklist:  nimbusec.SeveritySevere,
+	}
+
+	tests := []struct {
+		name   string
+		change func(n *nimbusec.Notification)
+		err    bool
+	}{
+		{"valid", func(n *nimbusec.Notification) {}, false},
+		{"transport", func(n *nimbusec.Notification) { n.Transport = "pigeon" }, true},
+		{"serverside", func(n *nimbusec.Notification) { n.ServerSide = 0 }, true},
+		{"content", func(n *nimbusec.Notification) { n.Content = 4 }, true},
+		{"blacklist", func(n *nimbusec.Notification) { n.Blacklist = -1 }, true},
+	}
+
+	// plain create comes first, as the others accept an existing notification.
+	create := []struct {
+		method string
+		fn     func(*nimbusec.Notification) (*nimbusec.Notification, error)
+	}{
+		{"create", func(n *nimbusec.Notification) (*nimbusec.Notification, error) {
+			return api.CreateNotification(user.Id, n)
+		}},
+		{"create or update", func(n *nimbusec.Notification) (*nimbusec.Notification, error) {
+			return api.CreateOrUpdateNotification(user.Id, n)
+		}},
+		{"create or get", func(n *nimbusec.Notification) (*nimbusec.Notification, error) {
+			return api.CreateOrGetNotification(user.Id, n)
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, c := range create {
+			n := valid
+			tt.change(&n)
+
+			_, err := c.fn(&n)
+			if (err != nil) != tt.err {
+				t.Errorf("%s %s: error = %v, want error %v", c.method, tt.name, err, tt.err)
+			}
+		}
+	}
+
+	// invalid notifications are rejected before a request is sent.
+	if n := len(srv.Notifications(user.Id)); n != 1 {
+		t.Errorf("server has %d notifications, want 1", n)
+	}
+}
@@ -0,0 +1,203 @@
+package nimbusec
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Normalize rewrites the domain into its canonical form:
+//
+//   - the name is trimmed, mapped with the IDNA lookup profile (UTS #46:
+//     lower case, NFC) and converted to punycode; a scheme, port, path or
+//     trailing dot is removed and the scheme taken over if none is set
+//   - the scheme is lower cased or, if missing, derived from the deep scan
+//     or first landing page
+//   - relative deep scan and landing page urls are resolved against the
+//     domain, urls of the domain use its scheme and duplicate landing
+//     pages are removed
+//
+// Normalize only fails for values it can not parse; use Validate to check
+// the result.
+func (d *Domain) Normalize() error {
+	var errs ValidationErrors
+
+	name := strings.TrimSpace(d.Name)
+	if strings.Contains(name, "://") {
+		if u, err := url.Parse(name); err == nil {
+			if d.Scheme == "" {
+				d.Scheme = u.Scheme
+			}
+			name = u.Host
+		}
+	}
+	name = strings.TrimRight(name, "/")
+	if i := strings.IndexAny(name, "/?#"); i >= 0 {
+		name = name[:i]
+	}
+	if host, _, ok := strings.Cut(name, ":"); ok {
+		name = host
+	}
+	name = strings.TrimSuffix(name, ".")
+
+	if ascii, err := idna.Lookup.ToASCII(name); err != nil {
+		errs.add("name", d.Name, "%v", err)
+	} else {
+		d.Name = ascii
+	}
+
+	d.Scheme = strings.ToLower(strings.TrimSpace(d.Scheme))
+	if d.Scheme == "" {
+		candidates := append([]string{d.DeepScan}, d.FastScans...)
+		for _, raw := range candidates {
+			if u, err := url.Parse(raw); err == nil && u.IsAbs() {
+				d.Scheme = strings.ToLower(u.Scheme)
+				break
+			}
+		}
+	}
+
+	base := &url.URL{Scheme: d.Scheme, Host: d.Name, Path: "/"}
+
+	if d.DeepScan != "" {
+		if u, err := d.normalizeURL(base, d.DeepScan); err != nil {
+			errs.add("deepScan", d.DeepScan, "%v", err)
+		} else {
+			d.DeepScan = u
+		}
+	}
+
+	fastScans := make([]string, 0, len(d.FastScans))
+	seen := make(map[string]bool, len(d.FastScans))
+	for i, raw := range d.FastScans {
+		u, err := d.normalizeURL(base, raw)
+		if err != nil {
+			errs.add(fieldIndex("fastScans", i), raw, "%v", err)
+			u = raw
+		}
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		fastScans = append(fastScans, u)
+	}
+	if d.FastScans != nil {
+		d.FastScans = fastScans
+	}
+
+	return errs.err()
+}
+
+// normalizeURL resolves raw against base and converts its host to punycode.
+// Urls on the domain get the scheme of the domain.
+func (d *Domain) normalizeURL(base *url.URL, raw string) (string, error) {
+	ref, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+
+	u := base.ResolveReference(ref)
+	u.Fragment = ""
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, err := idna.Lookup.ToASCII(strings.TrimSuffix(u.Hostname(), "."))
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if u.Host == d.Name && d.Scheme != "" {
+		u.Scheme = d.Scheme
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String(), nil
+}
+
+// Validate checks the domain before it is sent to the API: the name must be
+// a lower case host name in punycode, the scheme http or https, and the
+// deep scan and all landing pages absolute urls on the domain with the same
+// scheme. Landing pages must not repeat. The returned error is of type
+// ValidationErrors listing all problems.
+func (d *Domain) Validate() error {
+	return d.validate(0)
+}
+
+// ValidateFor is like Validate but also checks that the number of landing
+// pages does not exceed the fast scans included in the bundle.
+func (d *Domain) ValidateFor(bundle *Bundle) error {
+	return d.validate(bundle.Fast)
+}
+
+func (d *Domain) validate(maxFastScans int) error {
+	var errs ValidationErrors
+
+	switch ascii, err := idna.Lookup.ToASCII(d.Name); {
+	case d.Name == "":
+		errs.add("name", d.Name, "must not be empty")
+	case strings.ContainsAny(d.Name, ":/?#@ "):
+		errs.add("name", d.Name, "must be a host name without scheme, port or path")
+	case strings.HasSuffix(d.Name, "."):
+		errs.add("name", d.Name, "must not end with a dot")
+	case err != nil:
+		errs.add("name", d.Name, "%v", err)
+	case ascii != d.Name:
+		errs.add("name", d.Name, "must be lower case punycode (%s)", ascii)
+	}
+
+	if d.Scheme != "http" && d.Scheme != "https" {
+		errs.add("scheme", d.Scheme, "must be http or https")
+	}
+
+	if d.DeepScan != "" {
+		if msg := d.checkURL(d.DeepScan); msg != "" {
+			errs.add("deepScan", d.DeepScan, "%s", msg)
+		}
+	}
+
+	seen := make(map[string]bool, len(d.FastScans))
+	for i, page := range d.FastScans {
+		field := fieldIndex("fastScans", i)
+		if msg := d.checkURL(page); msg != "" {
+			errs.add(field, page, "%s", msg)
+		}
+		if seen[page] {
+			errs.add(field, page, "duplicate landing page")
+		}
+		seen[page] = true
+	}
+
+	if maxFastScans > 0 && len(d.FastScans) > maxFastScans {
+		errs.add("fastScans", strings.Join(d.FastScans, " "), "%d landing pages exceed the limit of %d", len(d.FastScans), maxFastScans)
+	}
+
+	return errs.err()
+}
+
+// checkURL returns why raw is no valid url of the domain or "".
+func (d *Domain) checkURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err.Error()
+	}
+
+	switch {
+	case !u.IsAbs() || u.Host == "":
+		return "must be an absolute url"
+	case u.Scheme != d.Scheme:
+		return "scheme does not match the domain scheme " + d.Scheme
+	case u.Hostname() != d.Name:
+		return "host does not match the domain " + d.Name
+	}
+	return ""
+}
+
+func fieldIndex(field string, i int) string {
+	return field + "[" + strconv.Itoa(i) + "]"
+}
@@ -0,0 +1,142 @@
+package nimbusec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDomainNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain Domain
+		want   Domain
+		err    bool
+	}{
+		{
+			"canonical",
+			Domain{Name: "example.com", Scheme: "https"},
+			Domain{Name: "example.com", Scheme: "https"},
+			false,
+		},
+		{
+			"url as name",
+			Domain{Name: " HTTPS://Example.com:8443/path/ "},
+			Domain{Name: "example.com", Scheme: "https"},
+			false,
+		},
+		{
+			"idn",
+			Domain{Name: "Bücher.example.", Scheme: "HTTP"},
+			Domain{Name: "xn--bcher-kva.example", Scheme: "http"},
+			false,
+		},
+		{
+			"idn decomposed",
+			Domain{Name: "Bu\u0308cher.example", Scheme: "https"},
+			Domain{Name: "xn--bcher-kva.example", Scheme: "https"},
+			false,
+		},
+		{
+			"idn mapped",
+			Domain{Name: "ＥＸＡＭＰＬＥ.ドメイン名例.jp", Scheme: "https"},
+			Domain{Name: "example.xn--eckwd4c7cu47r2wf.jp", Scheme: "https"},
+			false,
+		},
+		{
+			"scheme from deep scan",
+			Domain{Name: "example.com", DeepScan: "https://example.com"},
+			Domain{Name: "example.com", Scheme: "https", DeepScan: "https://example.com/"},
+			false,
+		},
+		{
+			"landing pages",
+			Domain{Name: "example.com", Scheme: "https", FastScans: []string{"/", "about#team", "http://example.com/", "https://other.com/a"}},
+			Domain{Name: "example.com", Scheme: "https", FastScans: []string{"https://example.com/", "https://example.com/about", "https://other.com/a"}},
+			false,
+		},
+		{
+			"invalid name",
+			Domain{Name: "exa mple.com", Scheme: "https"},
+			Domain{Name: "exa mple.com", Scheme: "https"},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.domain
+			err := d.Normalize()
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(d, tt.want) {
+				t.Errorf("Normalize = %+v, want %+v", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainValidate(t *testing.T) {
+	valid := Domain{
+		Name:      "example.com",
+		Scheme:    "https",
+		DeepScan:  "https://example.com/",
+		FastScans: []string{"https://example.com/", "https://example.com/shop"},
+	}
+
+	with := func(change func(d *Domain)) Domain {
+		d := valid
+		d.FastScans = append([]string(nil), valid.FastScans...)
+		change(&d)
+		return d
+	}
+
+	tests := []struct {
+		name   string
+		domain Domain
+		bundle *Bundle
+		fields []string // invalid fields
+	}{
+		{"valid", valid, nil, nil},
+		{"empty name", with(func(d *Domain) { d.Name = "" }), nil, []string{"name", "deepScan", "fastScans[0]", "fastScans[1]"}},
+		{"upper case", with(func(d *Domain) { d.Name = "Example.com" }), nil, []string{"name", "deepScan", "fastScans[0]", "fastScans[1]"}},
+		{"unicode", with(func(d *Domain) { d.Name = "bücher.example"; d.DeepScan = ""; d.FastScans = nil }), nil, []string{"name"}},
+		{"scheme", with(func(d *Domain) { d.Scheme = "ftp" }), nil, []string{"scheme", "deepScan", "fastScans[0]", "fastScans[1]"}},
+		{"relative deep scan", with(func(d *Domain) { d.DeepScan = "/" }), nil, []string{"deepScan"}},
+		{"other host", with(func(d *Domain) { d.FastScans[1] = "https://example.org/" }), nil, []string{"fastScans[1]"}},
+		{"duplicate", with(func(d *Domain) { d.FastScans[1] = d.FastScans[0] }), nil, []string{"fastScans[1]"}},
+		{"within bundle", valid, &Bundle{Fast: 2}, nil},
+		{"exceeds bundle", valid, &Bundle{Fast: 1}, []string{"fastScans"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.bundle != nil {
+				err = tt.domain.ValidateFor(tt.bundle)
+			} else {
+				err = tt.domain.Validate()
+			}
+
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("error = %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("error = %v, want ValidationErrors", err)
+			}
+			fields := make([]string, len(errs))
+			for i, e := range errs {
+				fields[i] = e.Field
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package watch_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cumulodev/nimbusec"
+	"github.com/cumulodev/nimbusec/nimbusectest"
+	"github.com/cumulodev/nimbusec/watch"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	srv := nimbusectest.NewServer()
+	defer srv.Close()
+	api := srv.API()
+
+	domain := srv.AddDomain(nimbusec.Domain{Name: "example.com"})
+	first := srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending})
+	srv.AddResult(domain.Id, nimbusec.Result{Status: nimbusec.StatusPending})
+
+	state := filepath.Join(t.TempDir(), "watch.json")
+
+	steps := []struct {
+		name    string
+		prepare func()
+		want    []watch.EventType
+	}{
+		{"initial", func() {}, []watch.EventType{watch.Created, watch.Created}},
+		{"unchanged", func() {}, []watch.EventType{}},
+		{"acknowledged", func() {
+			if _, err := api.UpdateResult(domain.Id, &nimbusec.Result{Id: first.Id, Status: nimbusec.StatusAcknowledged}); err != nil {
+				t.Fatal(err)
+			}
+		}, []watch.EventType{watch.StatusChanged}},
+		{"domain deleted", func() {
+			if err := api.DeleteDomain(&domain, true); err != nil {
+				t.Fatal(err)
+			}
+		}, []watch.EventType{watch.Removed}},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			step.prepare()
+
+			// every step starts from the saved state.
+			w := &watch.Watcher{API: api, Results: "status eq 1", State: state}
+
+			got := make([]watch.EventType, 0)
+			err := w.Poll(t.Context(), func(e watch.Event) error {
+				got = append(got, e.Type)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, step.want) {
+				t.Errorf("events = %v, want %v", got, step.want)
+			}
+		})
+	}
+}
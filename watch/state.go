@@ -0,0 +1,75 @@
+package watch
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// snapshot is the persisted state of a watcher: the last seen results of
+// every domain, keyed by id.
+type snapshot struct {
+	Domains map[string]*domainState `json:"domains"`
+}
+
+type domainState struct {
+	Domain  nimbusec.Domain            `json:"domain"`
+	Results map[string]nimbusec.Result `json:"results"`
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{Domains: map[string]*domainState{}}
+}
+
+// load reads the saved snapshot. fresh reports whether there was none.
+func (w *Watcher) load() (state *snapshot, fresh bool, err error) {
+	if w.State == "" {
+		return newSnapshot(), true, nil
+	}
+
+	data, err := os.ReadFile(w.State)
+	if os.IsNotExist(err) {
+		return newSnapshot(), true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	state = newSnapshot()
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, false, err
+	}
+	if state.Domains == nil {
+		state.Domains = map[string]*domainState{}
+	}
+	return state, false, nil
+}
+
+// save atomically replaces the state file with the current snapshot.
+func (w *Watcher) save() error {
+	if w.State == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(w.state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.State), filepath.Base(w.State)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), w.State)
+}
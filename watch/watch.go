@@ -0,0 +1,308 @@
+// Package watch turns periodic polls of the nimbusec API into a feed of
+// typed result events. A Watcher compares every poll with the previous
+// snapshot and reports new, changed, reoccurring and removed results:
+//
+//	w := &watch.Watcher{API: api, Interval: 5 * time.Minute, State: "watch.json"}
+//	err := w.Run(ctx, func(e watch.Event) error {
+//		log.Printf("%s: %s %s", e.Type, e.Domain.Name, e.Result.Threatname)
+//		return nil
+//	})
+//
+// With State set, the snapshot is persisted after every poll, so a restarted
+// watcher only reports what changed while it was down. Events are delivered
+// at least once: if the process stops before the snapshot is saved, the
+// events of the interrupted poll are reported again.
+package watch
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+// EventType describes how a result changed between two polls.
+type EventType int
+
+const (
+	Created       EventType = iota + 1 // result was not seen before
+	StatusChanged                      // status changed, e.g. acknowledged
+	Reoccurred                         // LastDate advanced, the finding was detected again
+	Removed                            // result is gone or has status removed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Created:
+		return "created"
+	case StatusChanged:
+		return "statusChanged"
+	case Reoccurred:
+		return "reoccurred"
+	case Removed:
+		return "removed"
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Event is a change of a single result.
+type Event struct {
+	Type     EventType
+	Domain   nimbusec.Domain
+	Result   nimbusec.Result  // current result, or the last known for Removed
+	Previous *nimbusec.Result // result of the previous poll, nil for Created
+}
+
+// Handler is called for every event. Returning an error stops the watcher
+// without saving the snapshot of the current poll.
+type Handler func(e Event) error
+
+// Watcher polls the results of all domains and reports their changes.
+type Watcher struct {
+	API      *nimbusec.API
+	Interval time.Duration // time between polls of Run
+	Domains  string        // filter selecting the domains, defaults to all
+	Results  string        // filter selecting the results, defaults to all; results leaving it are looked up once
+	Infected bool          // only poll domains returned by FindInfected
+	State    string        // path of the state file; empty keeps state in memory
+	Baseline bool          // a first poll without saved state records the snapshot without events
+
+	state *snapshot
+	err   error
+}
+
+// Run polls every Interval and calls fn for every event until ctx is done or
+// an error occurs.
+func (w *Watcher) Run(ctx context.Context, fn Handler) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Poll(ctx, fn); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Watch is like Run but delivers the events on a channel. The channel is
+// closed when the watcher stops; Err reports the reason afterwards.
+func (w *Watcher) Watch(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		w.err = w.Run(ctx, func(e Event) error {
+			select {
+			case events <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return events
+}
+
+// Err returns the error that stopped the watcher once the channel returned
+// by Watch is closed.
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+// Poll fetches the current results once, calls fn for every change since
+// the last poll and saves the new snapshot.
+func (w *Watcher) Poll(ctx context.Context, fn Handler) error {
+	baseline := false
+	if w.state == nil {
+		state, fresh, err := w.load()
+		if err != nil {
+			return err
+		}
+		w.state = state
+		baseline = fresh && w.Baseline
+	}
+
+	domains, err := w.domains(ctx)
+	if err != nil {
+		return err
+	}
+
+	next := newSnapshot()
+	for _, domain := range domains {
+		key := strconv.Itoa(domain.Id)
+		prev := w.state.Domains[key]
+
+		lookup := w.lookup(ctx, domain)
+		current, err := w.results(ctx, domain)
+		if nimbusec.IsNotFound(err) {
+			// the domain was deleted, all its results are gone.
+			current, err, lookup = map[string]nimbusec.Result{}, nil, nil
+			if prev != nil {
+				domain = prev.Domain
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if !baseline {
+			var old map[string]nimbusec.Result
+			if prev != nil {
+				old = prev.Results
+			}
+
+			events, err := diff(domain, old, current, lookup)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				if err := fn(e); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(current) > 0 {
+			next.Domains[key] = &domainState{Domain: domain, Results: current}
+		}
+	}
+
+	w.state = next
+	return w.save()
+}
+
+// domains returns the domains to poll: the listed ones and those of the
+// previous snapshot, so changes of domains that left the listing are seen.
+func (w *Watcher) domains(ctx context.Context) ([]nimbusec.Domain, error) {
+	var err error
+	var listed []nimbusec.Domain
+	if w.Infected {
+		listed, err = w.API.FindInfectedContext(ctx, w.Results)
+	} else {
+		listed, err = w.API.FindDomainsContext(ctx, w.Domains)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]bool)
+	for _, domain := range listed {
+		seen[domain.Id] = true
+	}
+	for _, state := range w.state.Domains {
+		if !seen[state.Domain.Id] {
+			listed = append(listed, state.Domain)
+		}
+	}
+
+	sort.Slice(listed, func(i, j int) bool {
+		return listed[i].Id < listed[j].Id
+	})
+	return listed, nil
+}
+
+func (w *Watcher) results(ctx context.Context, domain nimbusec.Domain) (map[string]nimbusec.Result, error) {
+	current := make(map[string]nimbusec.Result)
+	for result, err := range w.API.IterResults(ctx, domain.Id, w.Results) {
+		if err != nil {
+			return nil, err
+		}
+
+		// diffs may be large and are not needed to detect changes.
+		result.Diff = ""
+		current[strconv.Itoa(result.Id)] = result
+	}
+	return current, nil
+}
+
+// lookup returns a function fetching a single result of the domain, or nil
+// if all results are polled. With a result filter, a result missing from
+// the listing may still exist and only no longer match, e.g. because it was
+// acknowledged.
+func (w *Watcher) lookup(ctx context.Context, domain nimbusec.Domain) func(id int) (*nimbusec.Result, error) {
+	if w.Results == nimbusec.EmptyFilter {
+		return nil
+	}
+
+	return func(id int) (*nimbusec.Result, error) {
+		result, err := w.API.GetResultContext(ctx, domain.Id, id)
+		if nimbusec.IsNotFound(err) {
+			return nil, nil
+		}
+		return result, err
+	}
+}
+
+// diff compares two snapshots of the results of a domain. Results missing
+// from current are fetched with lookup, if given, and reported as removed if
+// they do not exist anymore. Events are ordered by result id.
+func diff(domain nimbusec.Domain, prev, current map[string]nimbusec.Result, lookup func(id int) (*nimbusec.Result, error)) ([]Event, error) {
+	events := make([]Event, 0)
+	for key, result := range current {
+		old, ok := prev[key]
+		if !ok {
+			if !result.IsRemoved() {
+				events = append(events, Event{Type: Created, Domain: domain, Result: result})
+			}
+			continue
+		}
+
+		events = append(events, changes(domain, old, result)...)
+	}
+
+	for key, old := range prev {
+		if _, ok := current[key]; ok || old.IsRemoved() {
+			continue
+		}
+
+		if lookup != nil {
+			result, err := lookup(old.Id)
+			if err != nil {
+				return nil, err
+			}
+			if result != nil {
+				result.Diff = ""
+				events = append(events, changes(domain, old, *result)...)
+				continue
+			}
+		}
+
+		events = append(events, Event{Type: Removed, Domain: domain, Result: old, Previous: &old})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Result.Id < events[j].Result.Id
+	})
+	return events, nil
+}
+
+// changes returns the events between two versions of a result. A result that
+// was detected again and changed its status gets both events.
+func changes(domain nimbusec.Domain, old, result nimbusec.Result) []Event {
+	var types []EventType
+	switch {
+	case old.IsRemoved() && result.IsRemoved():
+	case result.IsRemoved():
+		types = append(types, Removed)
+	default:
+		if result.LastDate.After(old.LastDate.Time) {
+			types = append(types, Reoccurred)
+		}
+		if result.Status != old.Status {
+			types = append(types, StatusChanged)
+		}
+	}
+
+	events := make([]Event, len(types))
+	for i, t := range types {
+		events[i] = Event{Type: t, Domain: domain, Result: result, Previous: &old}
+	}
+	return events
+}
@@ -0,0 +1,86 @@
+package watch
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cumulodev/nimbusec"
+)
+
+func TestDiff(t *testing.T) {
+	at := func(minutes int) nimbusec.Timestamp {
+		return nimbusec.Timestamp{Time: time.Date(2024, 3, 1, 12, minutes, 0, 0, time.UTC)}
+	}
+	result := func(id int, status nimbusec.ResultStatus, last int) nimbusec.Result {
+		return nimbusec.Result{Id: id, Status: status, LastDate: at(last)}
+	}
+	results := func(results ...nimbusec.Result) map[string]nimbusec.Result {
+		m := map[string]nimbusec.Result{}
+		for _, r := range results {
+			m[strconv.Itoa(r.Id)] = r
+		}
+		return m
+	}
+
+	pending, acknowledged, removed := nimbusec.StatusPending, nimbusec.StatusAcknowledged, nimbusec.StatusRemoved
+
+	tests := []struct {
+		name    string
+		prev    map[string]nimbusec.Result
+		current map[string]nimbusec.Result
+		lookup  map[int]nimbusec.Result // results found by lookup, nil disables lookups
+		want    []EventType
+	}{
+		{"unchanged", results(result(1, pending, 0)), results(result(1, pending, 0)), nil, []EventType{}},
+		{"created", nil, results(result(1, pending, 0), result(2, removed, 0)), nil, []EventType{Created}},
+		{"status changed", results(result(1, pending, 0)), results(result(1, acknowledged, 0)), nil, []EventType{StatusChanged}},
+		{"reoccurred", results(result(1, pending, 0)), results(result(1, pending, 5)), nil, []EventType{Reoccurred}},
+		{"reoccurred and status changed", results(result(1, acknowledged, 0)), results(result(1, pending, 5)), nil, []EventType{Reoccurred, StatusChanged}},
+		{"status removed", results(result(1, pending, 0)), results(result(1, removed, 0)), nil, []EventType{Removed}},
+		{"still removed", results(result(1, removed, 0)), results(result(1, removed, 5)), nil, []EventType{}},
+		{"vanished", results(result(1, pending, 0)), results(), nil, []EventType{Removed}},
+		{"vanished but gone", results(result(1, pending, 0)), results(), map[int]nimbusec.Result{}, []EventType{Removed}},
+		{"left the filter", results(result(1, pending, 0)), results(), map[int]nimbusec.Result{1: result(1, acknowledged, 0)}, []EventType{StatusChanged}},
+		{"ordered by id", results(result(2, pending, 0)), results(result(1, pending, 0), result(2, acknowledged, 0), result(3, pending, 0)), nil, []EventType{Created, StatusChanged, Created}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookup func(id int) (*nimbusec.Result, error)
+			if tt.lookup != nil {
+				lookup = func(id int) (*nimbusec.Result, error) {
+					if r, ok := tt.lookup[id]; ok {
+						return &r, nil
+					}
+					return nil, nil
+				}
+			}
+
+			events, err := diff(nimbusec.Domain{Id: 1}, tt.prev, tt.current, lookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]EventType, len(events))
+			for i, e := range events {
+				got[i] = e.Type
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLookupError(t *testing.T) {
+	boom := errors.New("boom")
+	prev := map[string]nimbusec.Result{"1": {Id: 1, Status: nimbusec.StatusPending}}
+
+	_, err := diff(nimbusec.Domain{}, prev, nil, func(int) (*nimbusec.Result, error) { return nil, boom })
+	if !errors.Is(err, boom) {
+		t.Errorf("error = %v, want %v", err, boom)
+	}
+}